@@ -1,16 +1,36 @@
 // This is the entry point of the Application
 //
-// It creates a new server instance and starts the server
+// It creates a new server instance and starts the server.
+// Administrative subcommands, such as "clients" and "migrate", run instead of the server when given.
 
 
 package main
 
 import (
 	"fmt"
+	"log"
+	"os"
+	_ "time/tzdata" // Embed the IANA time zone database for restaurant time zones
+	"waitress-backend/internal/cli"
 	"waitress-backend/internal/server"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "clients" {
+		db, err := server.OpenDatabase()
+		if err != nil {
+			log.Fatalf("failed to connect to database: %v", err)
+		}
+		os.Exit(cli.RunClients(db, os.Args[2:], os.Stdout))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		db, err := server.OpenDatabase()
+		if err != nil {
+			log.Fatalf("failed to connect to database: %v", err)
+		}
+		os.Exit(cli.RunMigrate(db, os.Args[2:], os.Stdout))
+	}
+
 	serverInstance := server.NewServer() // Renamed to avoid shadowing the package name
 
 	err := serverInstance.ListenAndServe()
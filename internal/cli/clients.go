@@ -0,0 +1,134 @@
+// The cli package contains the administrative subcommands of the server binary.
+//
+// The commands here are as follows:
+// - RunClients
+// - RunMigrate
+
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"gorm.io/gorm"
+)
+
+const clientsUsage = `usage: api clients <command> [arguments]
+
+commands:
+  list                              list clients and when they were last used
+  create -name NAME -type TYPE      register a client and print its secret
+  rotate CLIENT_ID                  issue a new secret, keeping the old one for the grace period
+  revoke CLIENT_ID                  revoke a client's access
+`
+
+// RunClients runs the "clients" subcommand and returns the process exit code
+func RunClients(db *gorm.DB, args []string, out io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(out, clientsUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "list":
+		err = listClients(db, out)
+	case "create":
+		err = createClient(db, args[1:], out)
+	case "rotate":
+		err = withClientID(args[1:], func(id uint) error { return rotateClient(db, id, out) })
+	case "revoke":
+		err = withClientID(args[1:], func(id uint) error { return revokeClient(db, id, out) })
+	default:
+		fmt.Fprint(out, clientsUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(out, "error:", err)
+		return 1
+	}
+	return 0
+}
+
+// withClientID parses the single CLIENT_ID argument of a command
+func withClientID(args []string, run func(uint) error) error {
+	if len(args) != 1 {
+		return fmt.Errorf("expected exactly one CLIENT_ID")
+	}
+	id, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid CLIENT_ID %q", args[0])
+	}
+	return run(uint(id))
+}
+
+// formatTime prints an optional timestamp
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func listClients(db *gorm.DB, out io.Writer) error {
+	clients, err := utilities.ListAPIClients(db)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPUBLIC UID\tNAME\tTYPE\tREVOKED\tLAST ROTATION\tLAST USED")
+	for _, client := range clients {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", client.ID, client.PublicUID, client.Name, client.ClientType,
+			formatTime(client.AccessRevoked), formatTime(client.LastSecretRotation), formatTime(client.LastUsedAt))
+	}
+	return w.Flush()
+}
+
+func createClient(db *gorm.DB, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("clients create", flag.ContinueOnError)
+	flags.SetOutput(out)
+	name := flags.String("name", "", "client name")
+	clientType := flags.String("type", "", "client type, e.g. iOS or web_first_party")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := utilities.CreateAPIClient(db, *name, *clientType)
+	if err != nil {
+		return err
+	}
+	printSecret(out, *client, client.Secret)
+	return nil
+}
+
+func rotateClient(db *gorm.DB, id uint, out io.Writer) error {
+	client, secret, err := utilities.RotateAPIClientSecret(db, id, time.Now())
+	if err != nil {
+		return err
+	}
+	printSecret(out, *client, secret)
+	graceEnds := client.LastSecretRotation.Add(utilities.ClientSecretGracePeriod)
+	fmt.Fprintf(out, "The previous secret is accepted until %s\n", formatTime(&graceEnds))
+	return nil
+}
+
+func revokeClient(db *gorm.DB, id uint, out io.Writer) error {
+	client, err := utilities.RevokeAPIClient(db, id, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Client %d (%s) revoked at %s\n", client.ID, client.Name, formatTime(client.AccessRevoked))
+	return nil
+}
+
+// printSecret shows a newly issued secret, which cannot be retrieved again
+func printSecret(out io.Writer, client models.APIClient, secret string) {
+	fmt.Fprintf(out, "Client ID:  %d\nPublic UID: %s\nSecret:     %s\n", client.ID, client.PublicUID, secret)
+	fmt.Fprintln(out, "Store the secret now; it will not be shown again.")
+}
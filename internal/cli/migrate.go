@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"waitress-backend/internal/database"
+
+	"gorm.io/gorm"
+)
+
+// RunMigrate runs the "migrate" subcommand and returns the process exit code. It migrates a database
+// that has no users yet, whom the migration endpoints would need to authenticate.
+func RunMigrate(db *gorm.DB, args []string, out io.Writer) int {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	seed := flags.Bool("seed", false, "seed the database with development data after migrating")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := database.Migrate(db); err != nil {
+		fmt.Fprintln(out, "error:", err)
+		return 1
+	}
+	fmt.Fprintln(out, "All tables migrated successfully")
+	if *seed {
+		if err := database.Seeder.Seed(&database.UserSeeder{}, db); err != nil {
+			fmt.Fprintln(out, "error: failed to seed database:", err)
+			return 1
+		}
+		fmt.Fprintln(out, "Database seeded successfully")
+	}
+	return 0
+}
@@ -0,0 +1,119 @@
+// This file contains the migration of every table of the application
+//
+// The functions here are as follows:
+// - Migrate
+
+package database
+
+import (
+	"fmt"
+	"waitress-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Migrate creates or updates every table, in the order their foreign keys need, and moves the data of
+// earlier versions into the columns that replaced theirs
+func Migrate(db *gorm.DB) error {
+	// Temporarily disable foreign key checks for the migration
+	db.Exec("SET FOREIGN_KEY_CHECKS = 0")
+	defer db.Exec("SET FOREIGN_KEY_CHECKS = 1")
+
+	// First migrate independent tables or base tables
+	if err := db.AutoMigrate(&models.Entity{}); err != nil {
+		return fmt.Errorf("failed to migrate Entity table: %v", err)
+	}
+
+	// Accounts from before email verification keep working as if verified
+	if err := MigrateEmailVerification(db); err != nil {
+		return fmt.Errorf("failed to migrate email verification: %v", err)
+	}
+
+	// Then migrate User which depends on Entity
+	if err := db.AutoMigrate(&models.User{}); err != nil {
+		return fmt.Errorf("failed to migrate User table: %v", err)
+	}
+
+	// Migrate third-level tables that depend on User
+	if err := autoMigrateEach(db,
+		&models.APIClient{},
+		&models.Restaurant{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.IdempotencyKey{},
+		&models.ClientNonce{},
+		&models.LoginLimit{},
+		&models.UserToken{},
+		&models.TwoFactor{},
+		&models.RecoveryCode{},
+	); err != nil {
+		return err
+	}
+
+	// Give reservations from earlier versions an end time before it becomes required
+	if err := MigrateReservationEndTimes(db); err != nil {
+		return fmt.Errorf("failed to migrate reservation end times: %v", err)
+	}
+
+	// Migrate fourth-level tables
+	if err := autoMigrateEach(db,
+		&models.Rating{},
+		&models.Reservation{},
+		&models.Table{},
+		&models.Receipt{},
+		&models.Category{},
+		&models.MenuSection{},
+		&models.ModifierGroup{},
+		&models.Staff{},
+	); err != nil {
+		return err
+	}
+
+	// Earlier versions put the foreign key between refunds and payments on the payment table, the wrong way round
+	if db.Migrator().HasConstraint(&models.Payment{}, "fk_refund_payment") {
+		if err := db.Migrator().DropConstraint(&models.Payment{}, "fk_refund_payment"); err != nil {
+			return fmt.Errorf("failed to drop the misplaced refund constraint: %v", err)
+		}
+	}
+
+	// Migrate fifth-level tables
+	if err := autoMigrateEach(db,
+		&models.MenuItem{},
+		&models.MenuItemTag{},
+		&models.Modifier{},
+		&models.Order{},
+		&models.OrderItem{},
+		&models.OrderItemModifier{},
+		&models.Payment{},
+		&models.PaymentEvent{},
+		&models.Refund{},
+		&models.OpeningHours{},
+		&models.ServicePeriod{},
+		&models.HoursException{},
+		&models.KitchenEvent{},
+	); err != nil {
+		return err
+	}
+
+	// Move amounts from the old float columns into the money columns
+	if err := MigrateMoneyColumns(db); err != nil {
+		return fmt.Errorf("failed to migrate money columns: %v", err)
+	}
+
+	// Migrate final tables including relationship tables
+	return autoMigrateEach(db,
+		&models.Customer{},
+		&models.UserLogin{},
+		&models.Favorite{},
+	)
+}
+
+// autoMigrateEach migrates the models one at a time, naming the one that failed
+func autoMigrateEach(db *gorm.DB, models ...interface{}) error {
+	for _, model := range models {
+		if err := db.AutoMigrate(model); err != nil {
+			return fmt.Errorf("failed to migrate %T: %v", model, err)
+		}
+	}
+	return nil
+}
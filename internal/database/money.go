@@ -0,0 +1,99 @@
+// This file contains the migration of amounts stored as floats to money columns
+//
+// The functions here are as follows:
+// - MigrateMoneyColumns
+// - copyFloatMoneyColumn
+// - dropColumn
+
+package database
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"waitress-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// floatMoneyColumn is an amount that was stored as a float and is now a models.Money
+type floatMoneyColumn struct {
+	Table          string
+	Column         string // The float column, which is dropped once copied
+	Prefix         string // Prefix of the <prefix>minor and <prefix>currency columns
+	CurrencyColumn string // Column holding the currency; empty when it was always models.DefaultCurrency
+}
+
+var floatMoneyColumns = []floatMoneyColumn{
+	{Table: "menu_item", Column: "price", Prefix: "price_"},
+	{Table: "modifier", Column: "price_delta", Prefix: "price_delta_"},
+	{Table: "order", Column: "total", Prefix: "total_"},
+	{Table: "order_item", Column: "unit_price", Prefix: "unit_price_"},
+	{Table: "order_item_modifier", Column: "price_delta", Prefix: "price_delta_"},
+	{Table: "payment", Column: "amount", Prefix: "amount_", CurrencyColumn: "currency"},
+}
+
+// MigrateMoneyColumns copies amounts from the float columns of earlier versions into the money columns
+// AutoMigrate adds, rounding each to the nearest minor unit, and drops the float columns. Run it after
+// AutoMigrate. MySQL commits each DROP COLUMN on its own, so rather than running in a transaction every
+// step can be run again: rows whose <prefix>minor is filled are not copied twice, and a table whose float
+// column is gone only has its currency column dropped, if that is left. Migrated tables are skipped.
+func MigrateMoneyColumns(db *gorm.DB) error {
+	migrator := db.Migrator()
+	for _, col := range floatMoneyColumns {
+		if !migrator.HasTable(col.Table) {
+			continue
+		}
+		if migrator.HasColumn(col.Table, col.Column) {
+			if err := copyFloatMoneyColumn(db, col); err != nil {
+				return fmt.Errorf("migrating %s.%s: %w", col.Table, col.Column, err)
+			}
+			if err := dropColumn(db, col.Table, col.Column); err != nil {
+				return err
+			}
+		}
+		if col.CurrencyColumn != "" && migrator.HasColumn(col.Table, col.CurrencyColumn) {
+			if err := dropColumn(db, col.Table, col.CurrencyColumn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// copyFloatMoneyColumn fills in the money columns of the rows whose <prefix>minor is still empty
+func copyFloatMoneyColumn(db *gorm.DB, col floatMoneyColumn) error {
+	currencies := []string{models.DefaultCurrency}
+	if col.CurrencyColumn != "" {
+		currencies = nil
+		if err := db.Table(col.Table).Distinct().Pluck(col.CurrencyColumn, &currencies).Error; err != nil {
+			return err
+		}
+	}
+	for _, stored := range currencies {
+		// Earlier versions stored currencies as given, e.g. "usd"
+		currency := strings.ToUpper(strings.TrimSpace(stored))
+		exponent, ok := models.CurrencyExponent(currency)
+		if !ok {
+			return fmt.Errorf("unsupported currency %q", stored)
+		}
+		query := db.Table(col.Table).Where(col.Column + " IS NOT NULL AND " + col.Prefix + "minor IS NULL")
+		if col.CurrencyColumn != "" {
+			query = query.Where(col.CurrencyColumn+" = ?", stored)
+		}
+		err := query.Updates(map[string]interface{}{
+			col.Prefix + "minor":    gorm.Expr("ROUND(? * ?)", gorm.Expr(col.Column), math.Pow10(exponent)),
+			col.Prefix + "currency": currency,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropColumn drops a column of a table that has no model
+func dropColumn(db *gorm.DB, table, column string) error {
+	return db.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table}, clause.Column{Name: column}).Error
+}
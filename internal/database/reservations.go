@@ -0,0 +1,44 @@
+// This file contains the migration of reservations made before they stored their end time
+//
+// The functions here are as follows:
+// - MigrateReservationEndTimes
+
+package database
+
+import (
+	"fmt"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MigrateReservationEndTimes fills in the end time of reservations that have none, from their start and
+// duration, so that they block their table in conflict and availability checks. Run it before AutoMigrate
+// of models.Reservation, which then makes the column NOT NULL; it does nothing once every row has one.
+func MigrateReservationEndTimes(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Reservation{}) {
+		return nil
+	}
+	table := clause.Table{Name: utilities.TableName(db, &models.Reservation{})}
+
+	if !migrator.HasColumn(&models.Reservation{}, "EndTime") {
+		// Added as nullable so existing rows can be filled in before the column becomes NOT NULL
+		if err := db.Exec("ALTER TABLE ? ADD end_time DATETIME(3) NULL", table).Error; err != nil {
+			return fmt.Errorf("adding reservation end times: %w", err)
+		}
+	}
+
+	// Reservations from before durations were stored took the default duration
+	duration := clause.Expr{SQL: "?", Vars: []interface{}{int(utilities.DefaultReservationDuration.Minutes())}}
+	if migrator.HasColumn(&models.Reservation{}, "DurationMinutes") {
+		duration = clause.Expr{SQL: "duration_minutes"}
+	}
+	err := db.Exec("UPDATE ? SET end_time = DATE_ADD(time, INTERVAL ? MINUTE) WHERE end_time IS NULL", table, duration).Error
+	if err != nil {
+		return fmt.Errorf("filling in reservation end times: %w", err)
+	}
+	return nil
+}
@@ -62,15 +62,16 @@ func (us *UserSeeder) Seed(db *gorm.DB) error {
 	cities := map[string]struct {
 		BaseLat  float64
 		BaseLong float64
+		TimeZone string
 	}{
-		"New York":    {40.730610, -73.935242},
-		"Los Angeles": {34.052235, -118.243683},
-		"Chicago":     {41.878113, -87.629799},
-		"Houston":     {29.760427, -95.369804},
-		"Miami":       {25.761681, -80.191788},
-		"Atlanta":     {33.7490, -84.3880},     // Added Atlanta
-		"Cincinnati":  {39.1031, -84.5120},     // Added Cincinnati
-		"Toronto":     {43.651070, -79.347015}, // Added Toronto
+		"New York":    {40.730610, -73.935242, "America/New_York"},
+		"Los Angeles": {34.052235, -118.243683, "America/Los_Angeles"},
+		"Chicago":     {41.878113, -87.629799, "America/Chicago"},
+		"Houston":     {29.760427, -95.369804, "America/Chicago"},
+		"Miami":       {25.761681, -80.191788, "America/New_York"},
+		"Atlanta":     {33.7490, -84.3880, "America/New_York"},    // Added Atlanta
+		"Cincinnati":  {39.1031, -84.5120, "America/New_York"},    // Added Cincinnati
+		"Toronto":     {43.651070, -79.347015, "America/Toronto"}, // Added Toronto
 	}
 	baseLat, baseLong := 40.730610, -73.935242 // Central coordinates for Manhattan
 	variance := 0.01
@@ -82,9 +83,9 @@ func (us *UserSeeder) Seed(db *gorm.DB) error {
 		Time         time.Time
 	}{
 		// Assuming UserID and RestaurantID are correct and exist in the database
-		{UserID: 1, RestaurantID: 1, TableID: 1, Time: time.Now()},
-		{UserID: 2, RestaurantID: 2, TableID: 2, Time: time.Now().Add(24 * time.Hour)}, // next day
-		{UserID: 3, RestaurantID: 3, TableID: 3, Time: time.Now().Add(48 * time.Hour)}, // in two days
+		{UserID: 1, RestaurantID: 1, TableID: 1, Time: time.Now().UTC()},
+		{UserID: 2, RestaurantID: 2, TableID: 2, Time: time.Now().UTC().Add(24 * time.Hour)}, // next day
+		{UserID: 3, RestaurantID: 3, TableID: 3, Time: time.Now().UTC().Add(48 * time.Hour)}, // in two days
 	}
 	tables := []struct {
 		RestaurantID        uint
@@ -126,47 +127,47 @@ func (us *UserSeeder) Seed(db *gorm.DB) error {
 	var mockMenuItems = map[string][]struct {
 		RestaurantID uint
 		NameOfItem   string
-		Price        float64
+		Price        string // Decimal amount in models.DefaultCurrency
 		Category     string
 		ImageURL     *string
 		IsAvailable  bool
 		Description  string
 	}{
 		"Appetizers": {
-			{RestaurantID: 1, NameOfItem: "Bruschetta", Price: 6.99, Category: "Appetizers", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1668095398193-58a63a440464?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Grilled bread topped with tomatoes, olive oil, and basil."},
-			{RestaurantID: 1, NameOfItem: "Spring Rolls", Price: 5.99, Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1695712641569-05eee7b37b6d?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Crispy rolls filled with vegetables and served with a dipping sauce."},
-			{RestaurantID: 1, NameOfItem: "Garlic Bread", Price: 4.99, Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1676976198546-18595f0796f0?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Toasted bread with garlic butter and herbs."},
-			{RestaurantID: 1, NameOfItem: "Caprese Salad", Price: 7.99, Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1622637103261-ae624e188bd0?q=80&w=2660&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Fresh mozzarella, tomatoes, and basil drizzled with balsamic glaze."},
-			{RestaurantID: 1, NameOfItem: "Mozzarella Sticks", Price: 7.99, Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1623653387945-2fd25214f8fc?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Fried cheese sticks served with marinara sauce."},
-			{RestaurantID: 1, NameOfItem: "Nachos", Price: 8.99, Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1582169296194-e4d644c48063?q=80&w=2600&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Tortilla chips topped with cheese, jalapeños, and sour cream."},
-			{RestaurantID: 1, NameOfItem: "Guacamole", Price: 6.99, Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1680992071073-cb1696ba8d3e?q=80&w=2674&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Creamy avocado dip with tomatoes, onions, and lime."},
-			{RestaurantID: 1, NameOfItem: "Edamame", Price: 5.99, Category: "Appetizers", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1666318300285-d97528868ff4?q=80&w=2574&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Steamed young soybeans sprinkled with sea salt."},
-			{RestaurantID: 1, NameOfItem: "Miso Soup", Price: 3.99, Category: "Appetizers", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1664391950572-bc4b1bdd1268?q=80&w=2592&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Traditional Japanese soup with tofu, seaweed, and scallions."},
-			{RestaurantID: 1, NameOfItem: "Garlic Knots", Price: 5.99, Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1629321962567-e15cd77bb5ec?q=80&w=2674&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Soft bread knots coated in garlic butter and Parmesan."},
+			{RestaurantID: 1, NameOfItem: "Bruschetta", Price: "6.99", Category: "Appetizers", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1668095398193-58a63a440464?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Grilled bread topped with tomatoes, olive oil, and basil."},
+			{RestaurantID: 1, NameOfItem: "Spring Rolls", Price: "5.99", Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1695712641569-05eee7b37b6d?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Crispy rolls filled with vegetables and served with a dipping sauce."},
+			{RestaurantID: 1, NameOfItem: "Garlic Bread", Price: "4.99", Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1676976198546-18595f0796f0?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Toasted bread with garlic butter and herbs."},
+			{RestaurantID: 1, NameOfItem: "Caprese Salad", Price: "7.99", Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1622637103261-ae624e188bd0?q=80&w=2660&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Fresh mozzarella, tomatoes, and basil drizzled with balsamic glaze."},
+			{RestaurantID: 1, NameOfItem: "Mozzarella Sticks", Price: "7.99", Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1623653387945-2fd25214f8fc?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Fried cheese sticks served with marinara sauce."},
+			{RestaurantID: 1, NameOfItem: "Nachos", Price: "8.99", Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1582169296194-e4d644c48063?q=80&w=2600&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Tortilla chips topped with cheese, jalapeños, and sour cream."},
+			{RestaurantID: 1, NameOfItem: "Guacamole", Price: "6.99", Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1680992071073-cb1696ba8d3e?q=80&w=2674&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Creamy avocado dip with tomatoes, onions, and lime."},
+			{RestaurantID: 1, NameOfItem: "Edamame", Price: "5.99", Category: "Appetizers", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1666318300285-d97528868ff4?q=80&w=2574&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Steamed young soybeans sprinkled with sea salt."},
+			{RestaurantID: 1, NameOfItem: "Miso Soup", Price: "3.99", Category: "Appetizers", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1664391950572-bc4b1bdd1268?q=80&w=2592&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Traditional Japanese soup with tofu, seaweed, and scallions."},
+			{RestaurantID: 1, NameOfItem: "Garlic Knots", Price: "5.99", Category: "Appetizers", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1629321962567-e15cd77bb5ec?q=80&w=2674&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Soft bread knots coated in garlic butter and Parmesan."},
 		},
 		"Mains": {
-			{RestaurantID: 1, NameOfItem: "Spaghetti Carbonara", Price: 12.99, Category: "Mains", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1705409892694-39677f828078?q=80&w=2706&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Pasta with creamy egg sauce, pancetta, and Parmesan."},
-			{RestaurantID: 1, NameOfItem: "Sweet and Sour Chicken", Price: 10.99, Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1705596704813-b39b95549cd2?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Fried chicken pieces in a sweet and tangy sauce with pineapple."},
-			{RestaurantID: 1, NameOfItem: "Butter Chicken", Price: 11.99, Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1603894584373-5ac82b2ae398?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Chicken cooked in a rich and creamy tomato sauce."},
-			{RestaurantID: 1, NameOfItem: "Lasagna", Price: 13.99, Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1709429790175-b02bb1b19207?q=80&w=2664&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Layered pasta with beef, ricotta, mozzarella, and marinara sauce."},
-			{RestaurantID: 1, NameOfItem: "Margherita Pizza", Price: 9.99, Category: "Mains", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1672198597143-45a4b5f064c9?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Classic pizza with fresh tomatoes, mozzarella, and basil."},
-			{RestaurantID: 1, NameOfItem: "Sushi Platter", Price: 19.99, Category: "Mains", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1668146927669-f2edf6e86f6f?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Assorted sushi rolls and nigiri with soy sauce and wasabi."},
-			{RestaurantID: 1, NameOfItem: "Tempura Udon", Price: 14.99, Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1629127524579-269c62b90a96?q=80&w=2574&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Udon noodles in broth with tempura shrimp and vegetables."},
-			{RestaurantID: 1, NameOfItem: "Taco Platter", Price: 11.99, Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1599974579688-8dbdd335c77f?q=80&w=2694&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Assorted tacos with beef, chicken, and vegetarian options."},
-			{RestaurantID: 1, NameOfItem: "Burrito", Price: 9.99, Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1662116765994-1e4200c43589?q=80&w=2664&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Flour tortilla filled with rice, beans, meat, and toppings."},
-			{RestaurantID: 1, NameOfItem: "Pepperoni Pizza", Price: 11.99, Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1628840042765-356cda07504e?q=80&w=2680&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Classic pizza with pepperoni slices and mozzarella cheese."},
+			{RestaurantID: 1, NameOfItem: "Spaghetti Carbonara", Price: "12.99", Category: "Mains", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1705409892694-39677f828078?q=80&w=2706&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Pasta with creamy egg sauce, pancetta, and Parmesan."},
+			{RestaurantID: 1, NameOfItem: "Sweet and Sour Chicken", Price: "10.99", Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1705596704813-b39b95549cd2?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Fried chicken pieces in a sweet and tangy sauce with pineapple."},
+			{RestaurantID: 1, NameOfItem: "Butter Chicken", Price: "11.99", Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1603894584373-5ac82b2ae398?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Chicken cooked in a rich and creamy tomato sauce."},
+			{RestaurantID: 1, NameOfItem: "Lasagna", Price: "13.99", Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1709429790175-b02bb1b19207?q=80&w=2664&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Layered pasta with beef, ricotta, mozzarella, and marinara sauce."},
+			{RestaurantID: 1, NameOfItem: "Margherita Pizza", Price: "9.99", Category: "Mains", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1672198597143-45a4b5f064c9?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Classic pizza with fresh tomatoes, mozzarella, and basil."},
+			{RestaurantID: 1, NameOfItem: "Sushi Platter", Price: "19.99", Category: "Mains", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1668146927669-f2edf6e86f6f?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Assorted sushi rolls and nigiri with soy sauce and wasabi."},
+			{RestaurantID: 1, NameOfItem: "Tempura Udon", Price: "14.99", Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1629127524579-269c62b90a96?q=80&w=2574&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Udon noodles in broth with tempura shrimp and vegetables."},
+			{RestaurantID: 1, NameOfItem: "Taco Platter", Price: "11.99", Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1599974579688-8dbdd335c77f?q=80&w=2694&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Assorted tacos with beef, chicken, and vegetarian options."},
+			{RestaurantID: 1, NameOfItem: "Burrito", Price: "9.99", Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1662116765994-1e4200c43589?q=80&w=2664&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Flour tortilla filled with rice, beans, meat, and toppings."},
+			{RestaurantID: 1, NameOfItem: "Pepperoni Pizza", Price: "11.99", Category: "Mains", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1628840042765-356cda07504e?q=80&w=2680&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Classic pizza with pepperoni slices and mozzarella cheese."},
 		},
 		"Desserts": {
-			{RestaurantID: 1, NameOfItem: "Tiramisu", Price: 6.99, Category: "Desserts", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1712262582533-dcf8deba14a3?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Italian dessert with layers of coffee-soaked ladyfingers and mascarpone."},
-			{RestaurantID: 1, NameOfItem: "Mango Sticky Rice", Price: 5.99, Category: "Desserts", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1711161988375-da7eff032e45?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Sweet sticky rice served with ripe mango slices and coconut milk."},
-			{RestaurantID: 1, NameOfItem: "Panna Cotta", Price: 7.99, Category: "Desserts", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1613505411792-208b15f862b0?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Creamy Italian dessert topped with berry compote."},
-			{RestaurantID: 1, NameOfItem: "Gelato", Price: 4.99, Category: "Desserts", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1675279010969-e85bfbd402dc?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Rich and creamy Italian ice cream available in various flavors."},
-			{RestaurantID: 1, NameOfItem: "Mochi Ice Cream", Price: 5.99, Category: "Desserts", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1701104845244-1748f70ca895?q=80&w=2671&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Japanese rice cake filled with ice cream."},
-			{RestaurantID: 1, NameOfItem: "Green Tea Cake", Price: 6.99, Category: "Desserts", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1716647126905-3acaec3fc2e7?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Moist cake infused with green tea flavor and topped with frosting."},
-			{RestaurantID: 1, NameOfItem: "Churros", Price: 4.99, Category: "Desserts", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1713962962200-e33e90cb2c60?q=80&w=2669&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Fried dough pastries dusted with cinnamon sugar."},
-			{RestaurantID: 1, NameOfItem: "Flan", Price: 5.99, Category: "Desserts", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1679959350482-9585bf3e72fd?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Creamy caramel custard dessert."},
-			{RestaurantID: 1, NameOfItem: "Cannoli", Price: 6.99, Category: "Desserts", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1555234557-062e321607cf?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Crispy pastry shells filled with sweet ricotta cream."},
-			{RestaurantID: 1, NameOfItem: "Tartufo", Price: 7.99, Category: "Desserts", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1668434344247-5daf7c7aff63?q=80&w=2680&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Chocolate-coated ice cream with a cherry and almond center."},
+			{RestaurantID: 1, NameOfItem: "Tiramisu", Price: "6.99", Category: "Desserts", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1712262582533-dcf8deba14a3?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Italian dessert with layers of coffee-soaked ladyfingers and mascarpone."},
+			{RestaurantID: 1, NameOfItem: "Mango Sticky Rice", Price: "5.99", Category: "Desserts", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1711161988375-da7eff032e45?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Sweet sticky rice served with ripe mango slices and coconut milk."},
+			{RestaurantID: 1, NameOfItem: "Panna Cotta", Price: "7.99", Category: "Desserts", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1613505411792-208b15f862b0?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Creamy Italian dessert topped with berry compote."},
+			{RestaurantID: 1, NameOfItem: "Gelato", Price: "4.99", Category: "Desserts", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1675279010969-e85bfbd402dc?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Rich and creamy Italian ice cream available in various flavors."},
+			{RestaurantID: 1, NameOfItem: "Mochi Ice Cream", Price: "5.99", Category: "Desserts", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1701104845244-1748f70ca895?q=80&w=2671&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Japanese rice cake filled with ice cream."},
+			{RestaurantID: 1, NameOfItem: "Green Tea Cake", Price: "6.99", Category: "Desserts", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1716647126905-3acaec3fc2e7?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Moist cake infused with green tea flavor and topped with frosting."},
+			{RestaurantID: 1, NameOfItem: "Churros", Price: "4.99", Category: "Desserts", ImageURL: utilities.StringPtr("https://plus.unsplash.com/premium_photo-1713962962200-e33e90cb2c60?q=80&w=2669&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Fried dough pastries dusted with cinnamon sugar."},
+			{RestaurantID: 1, NameOfItem: "Flan", Price: "5.99", Category: "Desserts", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1679959350482-9585bf3e72fd?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Creamy caramel custard dessert."},
+			{RestaurantID: 1, NameOfItem: "Cannoli", Price: "6.99", Category: "Desserts", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1555234557-062e321607cf?q=80&w=2670&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Crispy pastry shells filled with sweet ricotta cream."},
+			{RestaurantID: 1, NameOfItem: "Tartufo", Price: "7.99", Category: "Desserts", ImageURL: utilities.StringPtr("https://images.unsplash.com/photo-1668434344247-5daf7c7aff63?q=80&w=2680&auto=format&fit=crop&ixlib=rb-4.0.3&ixid=M3wxMjA3fDB8MHxwaG90by1wYWdlfHx8fGVufDB8fHx8fA%3D%3D"), IsAvailable: true, Description: "Chocolate-coated ice cream with a cherry and almond center."},
 		},
 	}
 	users := []struct {
@@ -395,6 +396,8 @@ func (us *UserSeeder) Seed(db *gorm.DB) error {
 		{"Good pizza, poor service", 3, 5, 10},
 	}
 	emailToUserID := make(map[string]uint)
+	// Seeded accounts are ready to order with, as if their addresses had been confirmed
+	verifiedAt := time.Now()
 
 	for _, data := range users {
 		// Hash the password with bcrypt
@@ -411,12 +414,13 @@ func (us *UserSeeder) Seed(db *gorm.DB) error {
 				LastName:  data.LastName,
 				Type:      data.AuthType,
 			},
-			Email:        data.Email,
-			PasswordHash: hashedPassword, // store the hashed password as a string
-			AuthType:     data.AuthType,
-			Latitude:     lat,
-			Longitude:    long,
-			Address:      &data.Address,
+			Email:           data.Email,
+			PasswordHash:    hashedPassword, // store the hashed password as a string
+			EmailVerifiedAt: &verifiedAt,
+			AuthType:        data.AuthType,
+			Latitude:        lat,
+			Longitude:       long,
+			Address:         &data.Address,
 		}
 
 		if err := tx.Create(&user).Error; err != nil {
@@ -492,6 +496,7 @@ func (us *UserSeeder) Seed(db *gorm.DB) error {
 			NumberOfTables: &data.NumOfTables,
 			Latitude:       &lat,
 			Longitude:      &long,
+			TimeZone:       cityCoords.TimeZone,
 			ImageURL:       &data.ImageURL,
 			Categories:     categories,
 		}
@@ -500,6 +505,37 @@ func (us *UserSeeder) Seed(db *gorm.DB) error {
 			tx.Rollback()
 			return fmt.Errorf("failed to create restaurant with email %s: %v", data.Email, err)
 		}
+
+		owner := models.Staff{UserID: ownerID, RestaurantID: restaurant.RestaurantId, Role: models.StaffRoleOwner, IsActive: true}
+		if err := tx.Create(&owner).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to create owner membership for restaurant %s: %v", data.Name, err)
+		}
+	}
+
+	// Give the seeded staff users a role at the first restaurant
+	staffRoles := []struct {
+		Email string
+		Role  string
+	}{
+		{"milesbennett2024@example.com", models.StaffRoleManager},
+		{"oliviagreenwood2024@example.com", models.StaffRoleHost},
+		{"nathanfrost2024@example.com", models.StaffRoleServer},
+		{"ellahunt2024@example.com", models.StaffRoleServer},
+		{"lucaswright2024@example.com", models.StaffRoleKitchen},
+		{"mayaspencer2024@example.com", models.StaffRoleKitchen},
+		{"leonicholson2024@example.com", models.StaffRoleHost},
+	}
+	for _, data := range staffRoles {
+		userID, exists := emailToUserID[data.Email]
+		if !exists {
+			return fmt.Errorf("no user ID found for email: %s", data.Email)
+		}
+		staff := models.Staff{UserID: userID, RestaurantID: 1, Role: data.Role, IsActive: true}
+		if err := tx.Create(&staff).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to create staff membership for %s: %v", data.Email, err)
+		}
 	}
 
 	for _, data := range tables {
@@ -527,19 +563,34 @@ func (us *UserSeeder) Seed(db *gorm.DB) error {
 		data.ReservationID = table.TableID
 	}
 	for restaurantID := 1; restaurantID <= 80; restaurantID++ {
+		sortOrder := 0
 		for category, items := range mockMenuItems {
-			for _, item := range items {
+			// Each category becomes a section of the restaurant's menu
+			section := models.MenuSection{
+				RestaurantID: uint(restaurantID),
+				Name:         category,
+				SortOrder:    sortOrder,
+			}
+			if err := tx.Create(&section).Error; err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to create menu section for restaurant %d: %v", restaurantID, err)
+			}
+			sortOrder++
+			for position, item := range items {
 				// Adjust RestaurantID for each restaurant dynamically
 				item.RestaurantID = uint(restaurantID)
 
+				price := models.MustParseMoney(item.Price, models.DefaultCurrency)
 				menuItem := models.MenuItem{
-					RestaurantID: item.RestaurantID,
-					NameOfItem:   &item.NameOfItem,
-					Price:        &item.Price,
-					Category:     &category,
-					IsAvailable:  item.IsAvailable,
-					ImageURL:     item.ImageURL,
-					Description:  &item.Description,
+					RestaurantID:  item.RestaurantID,
+					MenuSectionID: &section.MenuSectionID,
+					NameOfItem:    &item.NameOfItem,
+					Price:         &price,
+					Category:      &category,
+					IsAvailable:   item.IsAvailable,
+					ImageURL:      item.ImageURL,
+					Description:   &item.Description,
+					SortOrder:     position,
 				}
 				if err := tx.Create(&menuItem).Error; err != nil {
 					tx.Rollback()
@@ -568,10 +619,14 @@ func (us *UserSeeder) Seed(db *gorm.DB) error {
 
 	for _, r := range reservations {
 		reservation := models.Reservation{
-			UserID:       r.UserID,
-			RestaurantID: r.RestaurantID,
-			TableID:      r.TableID,
-			Time:         r.Time,
+			UserID:          r.UserID,
+			RestaurantID:    r.RestaurantID,
+			TableID:         r.TableID,
+			Time:            r.Time,
+			EndTime:         r.Time.Add(utilities.DefaultReservationDuration),
+			PartySize:       2,
+			DurationMinutes: uint(utilities.DefaultReservationDuration.Minutes()),
+			Status:          models.ReservationStatusBooked,
 		}
 		if err := tx.Create(&reservation).Error; err != nil {
 			tx.Rollback()
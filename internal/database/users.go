@@ -0,0 +1,34 @@
+// This file contains the migration of users created before email addresses were verified
+//
+// The functions here are as follows:
+// - MigrateEmailVerification
+
+package database
+
+import (
+	"fmt"
+	"waitress-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// MigrateEmailVerification adds the email verification column to an existing users table and counts every
+// user already in it as verified from when they signed up, so ordering and paying keep working for them.
+// Run it before AutoMigrate of models.User; once the column exists it does nothing, so users who sign up
+// later have to verify their address.
+func MigrateEmailVerification(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.User{}) || migrator.HasColumn(&models.User{}, "EmailVerifiedAt") {
+		return nil
+	}
+	if err := migrator.AddColumn(&models.User{}, "EmailVerifiedAt"); err != nil {
+		return fmt.Errorf("adding email verification: %w", err)
+	}
+	err := db.Unscoped().Model(&models.User{}).
+		Where("email_verified_at IS NULL").
+		UpdateColumn("email_verified_at", gorm.Expr("created_at")).Error
+	if err != nil {
+		return fmt.Errorf("verifying existing users: %w", err)
+	}
+	return nil
+}
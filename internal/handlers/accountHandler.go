@@ -0,0 +1,240 @@
+// This file contains the handlers for verifying a user's email address and resetting their password
+//
+// The handlers here are as follows:
+// - RequestEmailVerification
+// - VerifyEmail
+// - sendPasswordReset
+// - RequestPasswordReset
+// - ResetPassword
+// - sendEmailVerification
+// - respondUserTokenError
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	"waitress-backend/internal/mail"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// VerifyEmailRequest is the request body for redeeming an email verification token
+type VerifyEmailRequest struct {
+	Token string `json:"token" form:"token" binding:"required"`
+}
+
+// PasswordResetRequest is the request body for asking for a password reset email
+type PasswordResetRequest struct {
+	Email string `json:"email" form:"email" binding:"required"`
+}
+
+// ResetPasswordRequest is the request body for choosing a new password with a reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" form:"token" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required,min=8"`
+}
+
+// sendEmailVerification mails the user a link to verify their email address
+func sendEmailVerification(c *gin.Context, db *gorm.DB, user models.User) error {
+	token, err := utilities.IssueUserToken(db, user.UserID, models.UserTokenPurposeEmailVerification, time.Now())
+	if err != nil {
+		return err
+	}
+	link := utilities.AppURL("/verify-email", url.Values{"token": {token}})
+	return mail.Default().Send(c.Request.Context(), mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Welcome to Waitress!\n\nConfirm your email address by opening this link:\n%s\n\n"+
+			"The link expires in %s. If you did not create an account, you can ignore this email.\n",
+			link, utilities.EmailVerificationTokenTTL),
+	})
+}
+
+// respondUserTokenError maps errors of redeeming a mailed token to an HTTP response
+func respondUserTokenError(c *gin.Context, err error, action string) {
+	var reqErr *requestError
+	switch {
+	case errors.Is(err, utilities.ErrUserTokenInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This link is invalid or has already been used"})
+	case errors.Is(err, utilities.ErrUserTokenExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "This link has expired; please request a new one"})
+	case errors.As(err, &reqErr):
+		c.JSON(reqErr.Status, gin.H{"error": reqErr.Message})
+	default:
+		fmt.Printf("Error %s: %v\n", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action})
+	}
+}
+
+// RequestEmailVerification is a handler for sending the caller a new email verification link
+func RequestEmailVerification(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := utilities.GetAuthenticatedUserID(c)
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+			return
+		}
+		if user.EmailVerifiedAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email address is already verified"})
+			return
+		}
+
+		if err := sendEmailVerification(c, db, user); err != nil {
+			fmt.Println("Error sending verification email:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error sending verification email"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "Verification email sent"})
+	}
+}
+
+// VerifyEmail is a handler for redeeming an email verification link
+func VerifyEmail(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req VerifyEmailRequest
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			now := time.Now()
+			token, err := utilities.RedeemUserToken(tx, req.Token, models.UserTokenPurposeEmailVerification, now)
+			if err != nil {
+				return err
+			}
+			return tx.Model(&models.User{}).
+				Where("user_id = ? AND email_verified_at IS NULL", token.UserID).
+				Update("email_verified_at", now).Error
+		})
+		if err != nil {
+			respondUserTokenError(c, err, "verifying email")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Email address verified"})
+	}
+}
+
+// How long mailing a password reset link may take once the request has been answered
+const passwordResetMailTimeout = time.Minute
+
+// sendPasswordReset mails a password reset link to the user with the email, if there is one who may sign in
+func sendPasswordReset(ctx context.Context, db *gorm.DB, email string) error {
+	var user models.User
+	err := db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil || user.AccessRevoked {
+		return err
+	}
+	token, err := utilities.IssueUserToken(db.WithContext(ctx), user.UserID, models.UserTokenPurposePasswordReset, time.Now())
+	if err != nil {
+		return err
+	}
+	link := utilities.AppURL("/reset-password", url.Values{"token": {token}})
+	return mail.Default().Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your Waitress account.\n\n"+
+			"Choose a new password by opening this link:\n%s\n\n"+
+			"The link expires in %s. If you did not ask for this, you can ignore this email.\n",
+			link, utilities.PasswordResetTokenTTL),
+	})
+}
+
+// RequestPasswordReset is a handler for mailing a password reset link. It responds the same, and as
+// quickly, whether or not an account uses the email: the link is looked up and mailed after responding,
+// so it cannot be used to find out who has an account. Requests are limited per email and per address.
+func RequestPasswordReset(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req PasswordResetRequest
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+			return
+		}
+
+		wait, err := utilities.LoginLimiter.PasswordReset(req.Email, c.ClientIP(), time.Now())
+		if err != nil {
+			fmt.Println("Error throttling password reset:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error requesting password reset"})
+			return
+		}
+		if wait > 0 {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many password reset requests; please try again later", "retryAfter": retryAfter})
+			return
+		}
+
+		go func(email string) {
+			ctx, cancel := context.WithTimeout(context.Background(), passwordResetMailTimeout)
+			defer cancel()
+			if err := sendPasswordReset(ctx, db, email); err != nil {
+				fmt.Println("Error sending password reset email:", err)
+			}
+		}(req.Email)
+		c.JSON(http.StatusAccepted, gin.H{"message": "If an account uses this email, a password reset link has been sent to it"})
+	}
+}
+
+// ResetPassword is a handler for choosing a new password with a reset link. The user is signed out on
+// every device and any login lockout is lifted.
+func ResetPassword(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ResetPasswordRequest
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token and a password of at least 8 characters are required"})
+			return
+		}
+		hash, err := utilities.HashPassword(req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error hashing password"})
+			return
+		}
+
+		var user models.User
+		err = db.Transaction(func(tx *gorm.DB) error {
+			now := time.Now()
+			token, err := utilities.RedeemUserToken(tx, req.Token, models.UserTokenPurposePasswordReset, now)
+			if err != nil {
+				return err
+			}
+			if err := tx.First(&user, token.UserID).Error; err != nil {
+				return err
+			}
+			if user.AccessRevoked {
+				return &requestError{http.StatusForbidden, "Access has been revoked for this user"}
+			}
+			updates := map[string]interface{}{"password_hash": hash}
+			// Receiving the link proves the user controls the address
+			if user.EmailVerifiedAt == nil {
+				updates["email_verified_at"] = now
+			}
+			return tx.Model(&user).Updates(updates).Error
+		})
+		if err != nil {
+			respondUserTokenError(c, err, "resetting password")
+			return
+		}
+
+		if err := utilities.RevokeUserTokens(db, user.UserID); err != nil {
+			fmt.Println("Error signing out after password reset:", err)
+		}
+		if err := utilities.LoginLimiter.Unlock(user.Email); err != nil {
+			fmt.Println("Error unlocking login after password reset:", err)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Password has been reset; please log in again"})
+	}
+}
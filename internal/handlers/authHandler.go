@@ -3,7 +3,14 @@
 // The handlers here are as follows:
 // - Login
 // - Logout
+// - RefreshToken
+// - JWKS
+// - UnlockLogin
 // - createToken
+// - issueTokenPair
+// - completeLogin
+// - respondLoginFailed
+// - respondLoginThrottled
 // - verifyToken
 package handlers
 
@@ -12,19 +19,21 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"strconv"
 	"time"
 
 	// "fmt"
 	"net/http"
-	"os"
+	"strings"
 	"waitress-backend/internal/models"
 
 	"waitress-backend/internal/utilities"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // LoginRequest is the struct that represents the request body for the login endpoint
@@ -34,54 +43,220 @@ type LoginRequest struct {
 	UserAgent string `json:"userAgent" form:"userAgent"`
 }
 
-// Here is how you can access the JWT_SECRET environment variable
-var secretKey = []byte(os.Getenv("JWT_SECRET"))
-
-// Function to create an authentication token for a user
-func createToken(user models.User) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		jwt.MapClaims{
-			"userID":   user.UserID,
-			"email":    user.Email,
-			"authType": user.AuthType,
-			"exp":      time.Now().Add(time.Hour * 24).Unix(),
-		})
+// RefreshTokenRequest is the request body for exchanging a refresh token
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refreshToken" form:"refreshToken" binding:"required"`
+}
 
-	tokenString, err := token.SignedString(secretKey)
-	if err != nil {
-		return "", err
-	}
+// LogoutRequest is the optional request body for logging out a device
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken" form:"refreshToken"`
+}
+
+// errRefreshTokenReused is returned when an already exchanged or revoked refresh token is presented again
+var errRefreshTokenReused = errors.New("refresh token reused")
 
-	return tokenString, nil
+// Function to create a short-lived access token for a user, tied to a refresh token family
+func createToken(user models.User, familyID string) (string, error) {
+	return utilities.NewAccessToken(user, familyID)
 }
 
-// Function to verify the authenticity of a token
-func verifyToken(tokenString string) error {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return secretKey, nil
-	})
+// issueTokenPair creates an access token and a stored refresh token for a device. An empty familyID
+// starts a new family, as on login; rotation passes the family of the exchanged token.
+func issueTokenPair(db *gorm.DB, user models.User, familyID, deviceName string) (string, string, error) {
+	if familyID == "" {
+		var err error
+		if familyID, err = utilities.NewTokenFamilyID(); err != nil {
+			return "", "", err
+		}
+	}
 
+	refreshToken, hash, err := utilities.NewRefreshToken()
 	if err != nil {
-		return err
+		return "", "", err
+	}
+	stored := models.RefreshToken{
+		UserID:     user.UserID,
+		FamilyID:   familyID,
+		TokenHash:  hash,
+		DeviceName: deviceName,
+		ExpiresAt:  time.Now().Add(utilities.RefreshTokenTTL),
+	}
+	if err := db.Omit("User").Create(&stored).Error; err != nil {
+		return "", "", err
 	}
 
-	if !token.Valid {
-		return fmt.Errorf("invalid token")
+	accessToken, err := createToken(user, familyID)
+	if err != nil {
+		return "", "", err
 	}
+	return accessToken, refreshToken, nil
+}
+
+// JWKS publishes the public keys that verify access tokens, including retired keys still in their grace period
+func JWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, gin.H{"keys": utilities.SigningKeys().JWKS()})
+	}
+}
 
-	return nil
+// Function to verify the authenticity of a token
+func verifyToken(tokenString string) error {
+	_, err := utilities.ParseAccessToken(tokenString)
+	return err
 }
 
-// Logout function to clear the session
+// Logout function to clear the session and revoke the device's tokens
 func Logout(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		var req LogoutRequest
+		_ = c.ShouldBind(&req) // The body is optional
+
 		session := sessions.Default(c)
+		accessToken := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if accessToken == "" {
+			accessToken, _ = session.Get("apiToken").(string)
+		}
+		if err := utilities.RevokeAccessToken(db, accessToken); err != nil {
+			fmt.Println("Error revoking access token:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error logging out"})
+			return
+		}
+		if req.RefreshToken != "" {
+			var stored models.RefreshToken
+			err := db.Where("token_hash = ?", utilities.HashRefreshToken(req.RefreshToken)).First(&stored).Error
+			if err == nil {
+				err = utilities.RevokeTokenFamily(db, stored.FamilyID)
+			}
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				fmt.Println("Error revoking refresh token:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error logging out"})
+				return
+			}
+		}
+
 		session.Clear()
 		session.Save()
 		c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
 	}
 }
 
+// RefreshToken exchanges a refresh token for a new access token and refresh token. Each refresh token
+// can be exchanged once; presenting it again means it was stolen, so its whole family is revoked.
+func RefreshToken(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RefreshTokenRequest
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "refreshToken is required"})
+			return
+		}
+
+		var accessToken, refreshToken, reusedFamily string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var stored models.RefreshToken
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("token_hash = ?", utilities.HashRefreshToken(req.RefreshToken)).
+				First(&stored).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &requestError{http.StatusUnauthorized, "Invalid refresh token"}
+			}
+			if err != nil {
+				return err
+			}
+			if stored.UsedAt != nil || stored.RevokedAt != nil {
+				reusedFamily = stored.FamilyID
+				return errRefreshTokenReused
+			}
+			now := time.Now()
+			if now.After(stored.ExpiresAt) {
+				return &requestError{http.StatusUnauthorized, "Refresh token has expired"}
+			}
+
+			var user models.User
+			if err := tx.First(&user, stored.UserID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return &requestError{http.StatusUnauthorized, "Invalid refresh token"}
+				}
+				return err
+			}
+			if user.AccessRevoked {
+				return &requestError{http.StatusForbidden, "Access has been revoked for this user"}
+			}
+
+			if err := tx.Model(&stored).Update("used_at", now).Error; err != nil {
+				return err
+			}
+			accessToken, refreshToken, err = issueTokenPair(tx, user, stored.FamilyID, stored.DeviceName)
+			return err
+		})
+
+		var reqErr *requestError
+		switch {
+		case err == nil:
+		case errors.Is(err, errRefreshTokenReused):
+			if err := utilities.RevokeTokenFamily(db, reusedFamily); err != nil {
+				fmt.Println("Error revoking token family:", err)
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token has already been used; please log in again"})
+			return
+		case errors.As(err, &reqErr):
+			c.JSON(reqErr.Status, gin.H{"error": reqErr.Message})
+			return
+		default:
+			fmt.Println("Error refreshing token:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error refreshing token"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"token":        accessToken,
+			"refreshToken": refreshToken,
+			"expiresIn":    int(utilities.AccessTokenTTL.Seconds()),
+		})
+	}
+}
+
+// respondLoginFailed counts a failed login and gives the same response whether or not the account exists
+func respondLoginFailed(c *gin.Context, email, address string, now time.Time) {
+	if err := utilities.LoginLimiter.Failure(email, address, now); err != nil {
+		fmt.Println("Error recording failed login:", err)
+	}
+	c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "Invalid email or password"})
+}
+
+// respondLoginThrottled tells the caller when they may try logging in again
+func respondLoginThrottled(c *gin.Context, wait time.Duration) {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.IndentedJSON(http.StatusTooManyRequests, gin.H{"message": "Too many failed login attempts; please try again later", "retryAfter": retryAfter})
+}
+
+// UnlockLogin is a handler for lifting a user's login lockout
+func UnlockLogin(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := parseIDParam(c, "userId")
+		if !ok {
+			return
+		}
+		var user models.User
+		if err := db.Select("user_id", "email").First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+			return
+		}
+		if err := utilities.LoginLimiter.Unlock(user.Email); err != nil {
+			fmt.Println("Error unlocking login:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error unlocking login"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Login unlocked"})
+	}
+}
+
 // Login function to authenticate a user
 func Login(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -96,80 +271,142 @@ func Login(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
 		email := req.Email
 		password := req.Password
 		userAgent := req.UserAgent
-		// log out the email password and userAgent
-		fmt.Printf("Received: email=%s, password=%s, userAgent=%s\n", email, password, userAgent)
-		var client models.APIClient
-		userClient := db.Find(&client, "client_type = ?", userAgent)
-		fmt.Println(userClient)
+		// Signed requests name the client they come from; unsigned ones fall back to the reported user agent
+		if client, ok := utilities.GetAuthenticatedClient(c); ok {
+			userAgent = client.ClientType
+		}
 		if email == "" {
 			c.IndentedJSON(http.StatusBadRequest, gin.H{"message": "The email and/or password is incorrect"})
 			return
 		}
 
+		// Throttle guessing against the account and from the address before looking anything up
+		now := time.Now()
+		address := c.ClientIP()
+		wait, err := utilities.LoginLimiter.Check(email, address, now)
+		if err != nil {
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Database error"})
+			return
+		}
+		if wait > 0 {
+			recordLogin(db, c, loginAttempt{Email: email, FailureReason: loginFailureThrottled})
+			respondLoginThrottled(c, wait)
+			return
+		}
+
+		// Unknown emails and wrong passwords get the same response, so the endpoint doesn't reveal who has an account
 		var foundUser models.User
 		result := db.Preload("Entity").Where("email = ?", email).First(&foundUser)
+		if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error"})
+			return
+		}
 		if result.Error != nil {
-
-			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-				c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error"})
-			}
+			utilities.CheckPasswordHashOfNoUser(password)
+			recordLogin(db, c, loginAttempt{Email: email, FailureReason: loginFailureUnknownEmail})
+			respondLoginFailed(c, email, address, now)
 			return
 		}
 
 		// Assume foundUser.Salt and foundUser.PasswordHash store the salt and hashed password
 		if !utilities.CheckPasswordHash(password, foundUser.PasswordHash) {
-			c.IndentedJSON(http.StatusUnauthorized, gin.H{"message": "Invalid login credentials"})
+			recordLogin(db, c, loginAttempt{UserID: &foundUser.UserID, Email: email, FailureReason: loginFailureInvalidPassword})
+			respondLoginFailed(c, email, address, now)
 			return
 		}
-		token, err := createToken((foundUser))
+		// Users with two-factor authentication get a challenge to redeem with a code instead of tokens
+		twoFactor, err := utilities.GetTwoFactor(db, foundUser.UserID)
 		if err != nil {
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Error creating token"})
+			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Database error"})
 			return
 		}
-		// If the password is correct, proceed with session handling
-		session := sessions.Default(c)
-		// Need to set onyl the user in the session, as well as the api token.
-		// Otherwise, the sessions are too large and will not be saved.
-		session.Set("userID", foundUser.UserID)
-		session.Set("apiToken", token)
-		session.Set("authType", foundUser.AuthType)
-		session.Set("clientType", userAgent)
-		// session.Set("user", foundUser)
-		session.Set("loggedIn", true)
-		if err := session.Save(); err != nil {
-			log.Printf("Failed to save session: %v", err)
-			c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Error saving session"})
-			return
-		}
-		type CustomUserResponse struct {
-			UserID    uint      `json:"userId"`
-			FirstName string    `json:"firstName"`
-			LastName  string    `json:"lastName"`
-			Email     string    `json:"email"`
-			AuthType  string    `json:"authType"`
-			Latitude  float64   `json:"latitude"`
-			Longitude float64   `json:"longitude"`
-			Address   *string   `json:"address"`
-			CreatedAt time.Time `json:"createdAt"`
-		}
-		// Custom response; modify as needed.
-		response := CustomUserResponse{
-			UserID:    foundUser.UserID,
-			FirstName: foundUser.Entity.FirstName,
-			LastName:  foundUser.Entity.LastName,
-			Email:     foundUser.Email,
-			AuthType:  foundUser.AuthType,
-			Latitude:  foundUser.Latitude,
-			Address:   foundUser.Address,
-			Longitude: foundUser.Longitude,
-			CreatedAt: foundUser.Entity.CreatedAt,
-		}
-
-		c.IndentedJSON(http.StatusOK, gin.H{
-			"user":  response,
-			"token": token,
-		})
+		enrolled := twoFactor != nil && twoFactor.EnabledAt != nil
+		if enrolled || utilities.TwoFactorRequired(foundUser.AuthType) {
+			challenge, err := utilities.IssueUserToken(db, foundUser.UserID, models.UserTokenPurposeLoginChallenge, now)
+			if err != nil {
+				c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Error creating token"})
+				return
+			}
+			c.IndentedJSON(http.StatusOK, gin.H{
+				"twoFactorRequired": true,
+				"setupRequired":     !enrolled,
+				"challengeToken":    challenge,
+				"expiresIn":         int(utilities.LoginChallengeTokenTTL.Seconds()),
+			})
+			return
+		}
+
+		if err := utilities.LoginLimiter.Success(email); err != nil {
+			fmt.Println("Error clearing failed logins:", err)
+		}
+		completeLogin(db, c, foundUser, userAgent, nil)
+	}
+}
+
+// completeLogin issues a token pair and session for a user who has proven who they are, and responds
+// with them and the user. Extra fields are added to the response.
+func completeLogin(db *gorm.DB, c *gin.Context, user models.User, userAgent string, extra gin.H) {
+	familyID, err := utilities.NewTokenFamilyID()
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Error creating token"})
+		return
+	}
+	token, refreshToken, err := issueTokenPair(db, user, familyID, userAgent)
+	if err != nil {
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Error creating token"})
+		return
+	}
+	recordLogin(db, c, loginAttempt{UserID: &user.UserID, Email: user.Email, Succeeded: true, FamilyID: familyID})
+	// The user is authenticated, proceed with session handling
+	session := sessions.Default(c)
+	// Need to set onyl the user in the session, as well as the api token.
+	// Otherwise, the sessions are too large and will not be saved.
+	session.Set("userID", user.UserID)
+	session.Set("apiToken", token)
+	session.Set("authType", user.AuthType)
+	session.Set("clientType", userAgent)
+	// session.Set("user", user)
+	session.Set("loggedIn", true)
+	session.Set("loggedInAt", time.Now().Unix())
+	if err := session.Save(); err != nil {
+		log.Printf("Failed to save session: %v", err)
+		c.IndentedJSON(http.StatusInternalServerError, gin.H{"message": "Error saving session"})
+		return
+	}
+	type CustomUserResponse struct {
+		UserID        uint      `json:"userId"`
+		FirstName     string    `json:"firstName"`
+		LastName      string    `json:"lastName"`
+		Email         string    `json:"email"`
+		AuthType      string    `json:"authType"`
+		EmailVerified bool      `json:"emailVerified"`
+		Latitude      float64   `json:"latitude"`
+		Longitude     float64   `json:"longitude"`
+		Address       *string   `json:"address"`
+		CreatedAt     time.Time `json:"createdAt"`
+	}
+	// Custom response; modify as needed.
+	response := CustomUserResponse{
+		UserID:        user.UserID,
+		FirstName:     user.Entity.FirstName,
+		LastName:      user.Entity.LastName,
+		Email:         user.Email,
+		AuthType:      user.AuthType,
+		EmailVerified: user.EmailVerifiedAt != nil,
+		Latitude:      user.Latitude,
+		Address:       user.Address,
+		Longitude:     user.Longitude,
+		CreatedAt:     user.Entity.CreatedAt,
+	}
+
+	result := gin.H{
+		"user":         response,
+		"token":        token,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(utilities.AccessTokenTTL.Seconds()),
+	}
+	for key, value := range extra {
+		result[key] = value
 	}
+	c.IndentedJSON(http.StatusOK, result)
 }
@@ -0,0 +1,154 @@
+// This file contains the handlers for managing API clients
+//
+// The handlers here are as follows:
+// - GetAPIClients
+// - GetCurrentAPIClient
+// - CreateAPIClient
+// - RotateAPIClientSecret
+// - RevokeAPIClient
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// CreateAPIClientRequest is the request body for registering an API client
+type CreateAPIClientRequest struct {
+	Name       string `json:"name" binding:"required"`
+	ClientType string `json:"clientType" binding:"required"`
+}
+
+// APIClientResponse describes an API client without its secrets
+type APIClientResponse struct {
+	ClientID           uint       `json:"clientId"`
+	PublicUID          string     `json:"publicUid"`
+	Name               string     `json:"name"`
+	ClientType         string     `json:"clientType"`
+	AccessRevoked      *time.Time `json:"accessRevoked"`
+	LastSecretRotation *time.Time `json:"lastSecretRotation"`
+	LastUsedAt         *time.Time `json:"lastUsedAt"`
+	CreatedAt          time.Time  `json:"createdAt"`
+}
+
+// NewAPIClientResponse hides a client's secrets
+func NewAPIClientResponse(client models.APIClient) APIClientResponse {
+	return APIClientResponse{
+		ClientID:           client.ID,
+		PublicUID:          client.PublicUID,
+		Name:               client.Name,
+		ClientType:         client.ClientType,
+		AccessRevoked:      client.AccessRevoked,
+		LastSecretRotation: client.LastSecretRotation,
+		LastUsedAt:         client.LastUsedAt,
+		CreatedAt:          client.CreatedAt,
+	}
+}
+
+// respondAPIClientError maps client management errors to an HTTP response
+func respondAPIClientError(c *gin.Context, err error, action string) {
+	if errors.Is(err, utilities.ErrClientNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API client not found"})
+		return
+	}
+	fmt.Printf("Error %s API client: %v\n", action, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + " API client"})
+}
+
+// GetCurrentAPIClient is a handler for an API client to check its own signed credentials, e.g. after a
+// secret rotation
+func GetCurrentAPIClient(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, ok := utilities.GetAuthenticatedClient(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Client authentication required"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"client": NewAPIClientResponse(*client)})
+	}
+}
+
+// GetAPIClients is a handler for listing API clients and when they were last used
+func GetAPIClients(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clients, err := utilities.ListAPIClients(db)
+		if err != nil {
+			respondAPIClientError(c, err, "listing")
+			return
+		}
+		response := make([]APIClientResponse, 0, len(clients))
+		for _, client := range clients {
+			response = append(response, NewAPIClientResponse(client))
+		}
+		c.JSON(http.StatusOK, gin.H{"clients": response})
+	}
+}
+
+// CreateAPIClient is a handler for registering an API client. The secret is only returned here.
+func CreateAPIClient(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateAPIClientRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if err := utilities.ValidateAPIClient(req.Name, req.ClientType); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		client, err := utilities.CreateAPIClient(db, req.Name, req.ClientType)
+		if err != nil {
+			respondAPIClientError(c, err, "creating")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"client": NewAPIClientResponse(*client), "secret": client.Secret})
+	}
+}
+
+// RotateAPIClientSecret is a handler for giving a client a new secret. The new secret is only returned
+// here; the previous one keeps working for the rotation grace period.
+func RotateAPIClientSecret(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseIDParam(c, "clientId")
+		if !ok {
+			return
+		}
+
+		client, secret, err := utilities.RotateAPIClientSecret(db, clientID, time.Now())
+		if err != nil {
+			respondAPIClientError(c, err, "rotating")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"client":                 NewAPIClientResponse(*client),
+			"secret":                 secret,
+			"previousSecretValidFor": int(utilities.ClientSecretGracePeriod.Seconds()),
+		})
+	}
+}
+
+// RevokeAPIClient is a handler for revoking a client's access
+func RevokeAPIClient(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseIDParam(c, "clientId")
+		if !ok {
+			return
+		}
+
+		client, err := utilities.RevokeAPIClient(db, clientID, time.Now())
+		if err != nil {
+			respondAPIClientError(c, err, "revoking")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"client": NewAPIClientResponse(*client)})
+	}
+}
@@ -0,0 +1,380 @@
+// This file contains the handlers for restaurant opening hours
+//
+// The handlers here are as follows:
+// - GetRestaurantHours
+// - ReplaceOpeningHours
+// - CreateServicePeriod
+// - UpdateServicePeriod
+// - DeleteServicePeriod
+// - CreateHoursException
+// - UpdateHoursException
+// - DeleteHoursException
+
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// OpeningHoursRequest describes one open interval of the regular week
+type OpeningHoursRequest struct {
+	Weekday  *uint  `json:"weekday" binding:"required,max=6"`
+	OpensAt  string `json:"opensAt" binding:"required"`
+	ClosesAt string `json:"closesAt" binding:"required"`
+}
+
+// ReplaceOpeningHoursRequest replaces the whole regular week of a restaurant
+type ReplaceOpeningHoursRequest struct {
+	Hours []OpeningHoursRequest `json:"hours" binding:"dive"`
+}
+
+// ServicePeriodRequest is the request body for creating or replacing a service period
+type ServicePeriodRequest struct {
+	Name                string `json:"name" binding:"required"`
+	Weekday             *uint  `json:"weekday" binding:"required,max=6"`
+	StartsAt            string `json:"startsAt" binding:"required"`
+	EndsAt              string `json:"endsAt" binding:"required"`
+	AcceptsReservations *bool  `json:"acceptsReservations"` // Defaults to true
+}
+
+// HoursExceptionRequest is the request body for creating or replacing a holiday closure or one-off exception
+type HoursExceptionRequest struct {
+	Date           string  `json:"date" binding:"required"` // YYYY-MM-DD
+	Kind           string  `json:"kind"`                    // holiday or exception, defaults to exception
+	IsClosed       bool    `json:"isClosed"`
+	OpensAt        *string `json:"opensAt"` // Required when not closed
+	ClosesAt       *string `json:"closesAt"`
+	RecursAnnually bool    `json:"recursAnnually"`
+	Reason         string  `json:"reason"`
+}
+
+// loadRestaurant fetches the restaurant from the :restaurantId route parameter, responding on failure
+func loadRestaurant(c *gin.Context, db *gorm.DB) (*models.Restaurant, bool) {
+	restaurantID, ok := parseRestaurantID(c)
+	if !ok {
+		return nil, false
+	}
+	var restaurant models.Restaurant
+	if err := db.First(&restaurant, restaurantID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error validating restaurant"})
+		}
+		return nil, false
+	}
+	return &restaurant, true
+}
+
+// parseIDParam reads a numeric route parameter, responding on failure
+func parseIDParam(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + name + " format"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// validateServicePeriod checks a service period request and converts it to a model
+func validateServicePeriod(req ServicePeriodRequest, restaurantID uint) (*models.ServicePeriod, error) {
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	if !utilities.IsValidServicePeriodName(name) {
+		return nil, errors.New("invalid name. Valid options: " + strings.Join(utilities.ValidServicePeriodNames, ", "))
+	}
+	if err := utilities.ValidateClockRange(req.StartsAt, req.EndsAt); err != nil {
+		return nil, err
+	}
+	acceptsReservations := true
+	if req.AcceptsReservations != nil {
+		acceptsReservations = *req.AcceptsReservations
+	}
+	return &models.ServicePeriod{
+		RestaurantID:        restaurantID,
+		Name:                name,
+		Weekday:             *req.Weekday,
+		StartsAt:            req.StartsAt,
+		EndsAt:              req.EndsAt,
+		AcceptsReservations: acceptsReservations,
+	}, nil
+}
+
+// validateHoursException checks an exception request and converts it to a model
+func validateHoursException(req HoursExceptionRequest, restaurantID uint) (*models.HoursException, error) {
+	if _, err := time.Parse("2006-01-02", req.Date); err != nil {
+		return nil, errors.New("invalid date, expected YYYY-MM-DD")
+	}
+	kind := req.Kind
+	if kind == "" {
+		kind = models.HoursExceptionException
+	}
+	if kind != models.HoursExceptionHoliday && kind != models.HoursExceptionException {
+		return nil, errors.New("invalid kind. Valid options: holiday, exception")
+	}
+	if req.RecursAnnually && kind != models.HoursExceptionHoliday {
+		return nil, errors.New("only holidays can recur annually")
+	}
+	exception := &models.HoursException{
+		RestaurantID:   restaurantID,
+		Date:           req.Date,
+		Kind:           kind,
+		IsClosed:       req.IsClosed,
+		RecursAnnually: req.RecursAnnually,
+		Reason:         req.Reason,
+	}
+	if !req.IsClosed {
+		if req.OpensAt == nil || req.ClosesAt == nil {
+			return nil, errors.New("opensAt and closesAt are required unless isClosed is true")
+		}
+		if err := utilities.ValidateClockRange(*req.OpensAt, *req.ClosesAt); err != nil {
+			return nil, err
+		}
+		exception.OpensAt = req.OpensAt
+		exception.ClosesAt = req.ClosesAt
+	}
+	return exception, nil
+}
+
+// GetRestaurantHours is a handler for getting the opening hours, service periods and exceptions of a restaurant
+func GetRestaurantHours(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		schedule, err := utilities.LoadRestaurantSchedule(db, restaurant)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching opening hours"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"timeZone":       restaurant.Location().String(),
+			"openingHours":   schedule.Hours,
+			"servicePeriods": schedule.Periods,
+			"exceptions":     schedule.Exceptions,
+		})
+	}
+}
+
+// ReplaceOpeningHours is a handler for replacing the regular weekly hours of a restaurant
+func ReplaceOpeningHours(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		var req ReplaceOpeningHoursRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+
+		hours := make([]models.OpeningHours, 0, len(req.Hours))
+		for _, interval := range req.Hours {
+			if err := utilities.ValidateClockRange(interval.OpensAt, interval.ClosesAt); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			hours = append(hours, models.OpeningHours{
+				RestaurantID: restaurant.RestaurantId,
+				Weekday:      *interval.Weekday,
+				OpensAt:      interval.OpensAt,
+				ClosesAt:     interval.ClosesAt,
+			})
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("restaurant_id = ?", restaurant.RestaurantId).Delete(&models.OpeningHours{}).Error; err != nil {
+				return err
+			}
+			if len(hours) == 0 {
+				return nil
+			}
+			return tx.Create(&hours).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving opening hours", "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"openingHours": hours})
+	}
+}
+
+// CreateServicePeriod is a handler for adding a service period to a restaurant
+func CreateServicePeriod(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		var req ServicePeriodRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+		period, err := validateServicePeriod(req, restaurant.RestaurantId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := db.Create(period).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating service period", "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"servicePeriod": period})
+	}
+}
+
+// UpdateServicePeriod is a handler for replacing a service period of a restaurant
+func UpdateServicePeriod(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		periodID, ok := parseIDParam(c, "periodId")
+		if !ok {
+			return
+		}
+		var existing models.ServicePeriod
+		if err := db.Where("service_period_id = ? AND restaurant_id = ?", periodID, restaurant.RestaurantId).First(&existing).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service period not found"})
+			return
+		}
+		var req ServicePeriodRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+		period, err := validateServicePeriod(req, restaurant.RestaurantId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		period.ServicePeriodID = existing.ServicePeriodID
+		period.CreatedAt = existing.CreatedAt
+		if err := db.Save(period).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating service period", "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"servicePeriod": period})
+	}
+}
+
+// DeleteServicePeriod is a handler for removing a service period from a restaurant
+func DeleteServicePeriod(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		periodID, ok := parseIDParam(c, "periodId")
+		if !ok {
+			return
+		}
+		result := db.Where("service_period_id = ? AND restaurant_id = ?", periodID, restaurant.RestaurantId).Delete(&models.ServicePeriod{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting service period"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Service period not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Service period deleted"})
+	}
+}
+
+// CreateHoursException is a handler for adding a holiday closure or one-off exception to a restaurant
+func CreateHoursException(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		var req HoursExceptionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+		exception, err := validateHoursException(req, restaurant.RestaurantId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := db.Create(exception).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating hours exception", "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"exception": exception})
+	}
+}
+
+// UpdateHoursException is a handler for replacing a holiday closure or one-off exception
+func UpdateHoursException(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		exceptionID, ok := parseIDParam(c, "exceptionId")
+		if !ok {
+			return
+		}
+		var existing models.HoursException
+		if err := db.Where("hours_exception_id = ? AND restaurant_id = ?", exceptionID, restaurant.RestaurantId).First(&existing).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hours exception not found"})
+			return
+		}
+		var req HoursExceptionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+		exception, err := validateHoursException(req, restaurant.RestaurantId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		exception.HoursExceptionID = existing.HoursExceptionID
+		exception.CreatedAt = existing.CreatedAt
+		if err := db.Save(exception).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating hours exception", "message": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"exception": exception})
+	}
+}
+
+// DeleteHoursException is a handler for removing a holiday closure or one-off exception
+func DeleteHoursException(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		exceptionID, ok := parseIDParam(c, "exceptionId")
+		if !ok {
+			return
+		}
+		result := db.Where("hours_exception_id = ? AND restaurant_id = ?", exceptionID, restaurant.RestaurantId).Delete(&models.HoursException{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting hours exception"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Hours exception not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Hours exception deleted"})
+	}
+}
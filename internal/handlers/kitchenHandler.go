@@ -0,0 +1,304 @@
+// This file contains the handlers for the kitchen display endpoints
+//
+// The handlers here are as follows:
+// - StreamKitchenEvents
+// - UpdateOrderItemStatus
+// - SetMenuItemAvailability
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// How often an idle stream sends a comment so proxies keep the connection open
+const kitchenKeepAliveInterval = 15 * time.Second
+
+// UpdateOrderItemStatusRequest is the request body for the kitchen moving an item along
+type UpdateOrderItemStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+}
+
+// SetMenuItemAvailabilityRequest is the request body for 86ing a menu item or bringing it back
+type SetMenuItemAvailabilityRequest struct {
+	IsAvailable *bool `json:"isAvailable" binding:"required"`
+}
+
+// kitchenItemEvent is the payload of an item.status_changed event
+type kitchenItemEvent struct {
+	OrderID       uint             `json:"orderId"`
+	ReservationID uint             `json:"reservationId"`
+	Item          models.OrderItem `json:"item"`
+}
+
+// kitchenMenuItemEvent is the payload of a menu item availability event
+type kitchenMenuItemEvent struct {
+	MenuID      uint    `json:"menuId"`
+	NameOfItem  *string `json:"nameOfItem"`
+	IsAvailable bool    `json:"isAvailable"`
+}
+
+// publishOrderEvent pushes an order snapshot to the kitchen displays of its restaurant
+func publishOrderEvent(db *gorm.DB, eventType string, order *models.Order) {
+	utilities.PublishKitchenEvent(db, utilities.KitchenEvents, order.RestaurantID, eventType, &order.OrderID, nil, order)
+}
+
+// publishMenuItemAvailability tells the kitchen displays that a menu item was 86'd or restored
+func publishMenuItemAvailability(db *gorm.DB, menuItem *models.MenuItem) {
+	eventType := models.KitchenEventMenuItemRestored
+	if !menuItem.IsAvailable {
+		eventType = models.KitchenEventMenuItem86d
+	}
+	utilities.PublishKitchenEvent(db, utilities.KitchenEvents, menuItem.RestaurantID, eventType, nil, &menuItem.MenuID,
+		kitchenMenuItemEvent{MenuID: menuItem.MenuID, NameOfItem: menuItem.NameOfItem, IsAvailable: menuItem.IsAvailable})
+}
+
+// lastEventID reads the ID of the last event a reconnecting display received. Browsers send the
+// Last-Event-ID header on reconnect; the lastEventId query parameter covers the first connection.
+func lastEventID(c *gin.Context) uint {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("lastEventId")
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+// writeKitchenEvent writes one event in the Server-Sent Events format
+func writeKitchenEvent(c *gin.Context, event models.KitchenEvent) {
+	fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.KitchenEventID, event.Type, event.Payload)
+	c.Writer.Flush()
+}
+
+// StreamKitchenEvents is a handler that streams a restaurant's kitchen events over Server-Sent Events.
+// Events are read from the database, so the display receives those published on every instance. A
+// display reconnecting with a Last-Event-ID first receives every stored event it missed, or a resync
+// event when they can no longer all be replayed.
+func StreamKitchenEvents(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+
+		// Subscribe before reading so nothing published in between is waited on
+		notifications, unsubscribe := utilities.KitchenEvents.Subscribe(restaurant.RestaurantId)
+		defer unsubscribe()
+
+		lastID := lastEventID(c)
+		var resync *models.KitchenEvent
+		var err error
+		if lastID > 0 {
+			var stored bool
+			stored, err = utilities.KitchenEventStored(db, restaurant.RestaurantId, lastID)
+			if err == nil && !stored {
+				var event models.KitchenEvent
+				event, err = utilities.KitchenResync(db, restaurant.RestaurantId)
+				resync = &event
+			}
+		} else {
+			// A first connection only receives events from now on
+			lastID, err = utilities.LatestKitchenEventID(db, restaurant.RestaurantId)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching kitchen events"})
+			return
+		}
+
+		// The server's write timeout would otherwise cut the stream off
+		if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+			fmt.Printf("Error extending kitchen stream deadline: %v\n", err)
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+
+		if resync != nil {
+			writeKitchenEvent(c, *resync)
+			lastID = resync.KitchenEventID
+		}
+
+		poll := time.NewTicker(utilities.KitchenPollInterval)
+		defer poll.Stop()
+		keepAlive := time.NewTicker(kitchenKeepAliveInterval)
+		defer keepAlive.Stop()
+		for {
+			// Events commit in ID order per restaurant, so everything up to lastID has been written
+			events, err := utilities.KitchenEventsSince(db, restaurant.RestaurantId, lastID)
+			if err != nil {
+				// The display reconnects with its Last-Event-ID and carries on from there
+				fmt.Printf("Error fetching kitchen events for restaurant %d: %v\n", restaurant.RestaurantId, err)
+				return
+			}
+			for _, event := range events {
+				writeKitchenEvent(c, event)
+				lastID = event.KitchenEventID
+			}
+
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case _, ok := <-notifications:
+				if !ok {
+					return
+				}
+			case <-poll.C:
+			case <-keepAlive.C:
+				fmt.Fprint(c.Writer, ": keep-alive\n\n")
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+// UpdateOrderItemStatus is a handler for the kitchen moving an order item from pending through to served.
+// Starting the first item of a submitted order moves the order into the kitchen.
+func UpdateOrderItemStatus(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		itemID, ok := parseIDParam(c, "itemId")
+		if !ok {
+			return
+		}
+
+		var req UpdateOrderItemStatusRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+
+		var item models.OrderItem
+		var order models.Order
+		orderMoved := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&item, itemID).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &requestError{http.StatusNotFound, "Order item not found"}
+			}
+			if err != nil {
+				return err
+			}
+			err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items.Modifiers").
+				Where("order_id = ? AND restaurant_id = ?", item.OrderID, restaurantID).
+				First(&order).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &requestError{http.StatusNotFound, "Order item not found"}
+			}
+			if err != nil {
+				return err
+			}
+
+			if order.Status != models.OrderStatusSubmitted && order.Status != models.OrderStatusInKitchen && order.Status != models.OrderStatusServed {
+				return &requestError{http.StatusConflict, "Items of a " + order.Status + " order cannot be changed by the kitchen"}
+			}
+			if !models.CanTransitionOrderItem(item.Status, req.Status) {
+				return &requestError{http.StatusConflict, fmt.Sprintf("Order item cannot move from %s to %s", item.Status, req.Status)}
+			}
+			if req.Status == models.OrderItemStatusVoided {
+				if err := checkOrderUnpaid(tx, &order); err != nil {
+					return err
+				}
+			}
+			item.Status = req.Status
+			if err := tx.Model(&item).Update("status", req.Status).Error; err != nil {
+				return err
+			}
+
+			for i := range order.Items {
+				if order.Items[i].OrderItemID == item.OrderItemID {
+					order.Items[i].Status = item.Status
+				}
+			}
+
+			if req.Status == models.OrderItemStatusVoided {
+				if err := saveOrderTotal(tx, &order); err != nil {
+					return err
+				}
+			}
+			if req.Status == models.OrderItemStatusPreparing && order.Status == models.OrderStatusSubmitted {
+				if err := order.TransitionTo(models.OrderStatusInKitchen, time.Now().UTC()); err != nil {
+					return err
+				}
+				orderMoved = true
+				return tx.Model(&order).Update("status", order.Status).Error
+			}
+			return nil
+		})
+		if err != nil {
+			respondOrderError(c, err, "updating")
+			return
+		}
+
+		utilities.PublishKitchenEvent(db, utilities.KitchenEvents, restaurantID, models.KitchenEventItemStatusChanged, &order.OrderID, nil,
+			kitchenItemEvent{OrderID: order.OrderID, ReservationID: order.ReservationID, Item: item})
+		if orderMoved {
+			publishOrderEvent(db, models.KitchenEventOrderStatusChanged, &order)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"item": item})
+	}
+}
+
+// SetMenuItemAvailability is a handler for 86ing a menu item when the kitchen runs out, or restoring it.
+// Unavailable items cannot be added to orders.
+func SetMenuItemAvailability(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		menuID, ok := parseIDParam(c, "menuId")
+		if !ok {
+			return
+		}
+
+		var req SetMenuItemAvailabilityRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+
+		var menuItem models.MenuItem
+		if err := db.Where("menu_id = ? AND restaurant_id = ?", menuID, restaurantID).First(&menuItem).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Menu item not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching menu item"})
+			}
+			return
+		}
+		if menuItem.IsAvailable == *req.IsAvailable {
+			c.JSON(http.StatusOK, gin.H{"menuItem": menuItem})
+			return
+		}
+		menuItem.IsAvailable = *req.IsAvailable
+		if err := db.Model(&menuItem).Update("is_available", menuItem.IsAvailable).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating menu item"})
+			return
+		}
+
+		publishMenuItemAvailability(db, &menuItem)
+
+		c.JSON(http.StatusOK, gin.H{"menuItem": menuItem})
+	}
+}
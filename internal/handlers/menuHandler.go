@@ -0,0 +1,781 @@
+// This file contains the handlers for managing a restaurant's menu
+//
+// The handlers here are as follows:
+// - GetMenu
+// - SearchMenu
+// - CreateMenuSection
+// - UpdateMenuSection
+// - DeleteMenuSection
+// - ReorderMenuSections
+// - CreateMenuItem
+// - UpdateMenuItem
+// - DeleteMenuItem
+// - ReorderMenuItems
+// - CreateModifierGroup
+// - UpdateModifierGroup
+// - DeleteModifierGroup
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// MenuSectionRequest is the request body for creating or updating a menu section
+type MenuSectionRequest struct {
+	Name        string  `json:"name" binding:"required,max=100"`
+	Description *string `json:"description" binding:"omitempty,max=255"`
+	SortOrder   *int    `json:"sortOrder"` // Defaults to the end of the menu for new sections
+}
+
+// MenuItemRequest is the request body for creating or updating a menu item
+type MenuItemRequest struct {
+	Name             string        `json:"name" binding:"required,max=255"`
+	Description      *string       `json:"description"`
+	Price            *models.Money `json:"price" binding:"required"`
+	ImageURL         *string       `json:"imageUrl"`
+	Category         *string       `json:"category"`
+	MenuSectionID    *uint         `json:"menuSectionId"`
+	IsAvailable      *bool         `json:"isAvailable"`
+	SortOrder        *int          `json:"sortOrder"`
+	ModifierGroupIDs []uint        `json:"modifierGroupIds"`
+	Allergens        []string      `json:"allergens"`   // Allergens the item contains, see utilities.ValidAllergens
+	DietaryTags      []string      `json:"dietaryTags"` // Diets the item suits, see utilities.ValidDietaryTags
+}
+
+// ModifierRequest is one option of a modifier group. Modifiers with an ID are updated in place.
+type ModifierRequest struct {
+	ModifierID  *uint        `json:"modifierId"`
+	Name        string       `json:"name" binding:"required,max=100"`
+	PriceDelta  models.Money `json:"priceDelta"` // Defaults to nothing extra
+	IsAvailable *bool        `json:"isAvailable"`
+}
+
+// priceDelta returns the modifier's price adjustment, which is zero when the request leaves it out
+func (r ModifierRequest) priceDelta() models.Money {
+	if r.PriceDelta.Currency == "" {
+		return models.ZeroMoney(models.DefaultCurrency)
+	}
+	return r.PriceDelta
+}
+
+// ModifierGroupRequest is the request body for creating or updating a modifier group with its modifiers
+type ModifierGroupRequest struct {
+	Name          string            `json:"name" binding:"required,max=100"`
+	MinSelections uint              `json:"minSelections"`
+	MaxSelections *uint             `json:"maxSelections"` // Defaults to 1, 0 allows any number
+	SortOrder     int               `json:"sortOrder"`
+	Modifiers     []ModifierRequest `json:"modifiers" binding:"required,min=1,dive"`
+}
+
+// ReorderRequest lists IDs in their new display order
+type ReorderRequest struct {
+	IDs []uint `json:"ids" binding:"required,min=1"`
+}
+
+// respondMenuError maps menu errors to an HTTP response
+func respondMenuError(c *gin.Context, err error, action string) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		c.JSON(reqErr.Status, gin.H{"error": reqErr.Message})
+		return
+	}
+	fmt.Printf("Error %s: %v\n", action, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action})
+}
+
+// findRestaurantRecord loads a record of the restaurant by primary key, reporting a 404 when it is missing
+// or belongs to another restaurant
+func findRestaurantRecord(tx *gorm.DB, dest interface{}, column string, id, restaurantID uint, name string) error {
+	err := tx.Where(column+" = ? AND restaurant_id = ?", id, restaurantID).First(dest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &requestError{http.StatusNotFound, name + " not found"}
+	}
+	return err
+}
+
+// loadModifierGroups loads the requested modifier groups, refusing any that belong to another restaurant
+func loadModifierGroups(tx *gorm.DB, restaurantID uint, ids []uint) ([]models.ModifierGroup, error) {
+	groups := []models.ModifierGroup{}
+	if len(ids) == 0 {
+		return groups, nil
+	}
+	if err := tx.Where("modifier_group_id IN ? AND restaurant_id = ?", ids, restaurantID).Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	if len(groups) != len(uniqueIDs(ids)) {
+		return nil, &requestError{http.StatusUnprocessableEntity, "Modifier groups must belong to this restaurant"}
+	}
+	return groups, nil
+}
+
+// uniqueIDs removes duplicate IDs, keeping the first occurrence
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]struct{}, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+	return unique
+}
+
+// applyMenuItemRequest validates a menu item request and copies it onto the item
+func applyMenuItemRequest(tx *gorm.DB, item *models.MenuItem, req MenuItemRequest) error {
+	if err := utilities.ValidateMenuPrice("price", *req.Price, models.DefaultCurrency); err != nil {
+		return &requestError{http.StatusBadRequest, err.Error()}
+	}
+	if req.MenuSectionID != nil {
+		var section models.MenuSection
+		if err := findRestaurantRecord(tx, &section, "menu_section_id", *req.MenuSectionID, item.RestaurantID, "Menu section"); err != nil {
+			return err
+		}
+	}
+	name := req.Name
+	price := *req.Price
+	item.NameOfItem = &name
+	item.Price = &price
+	item.Description = req.Description
+	item.ImageURL = req.ImageURL
+	item.Category = req.Category
+	item.MenuSectionID = req.MenuSectionID
+	if req.IsAvailable != nil {
+		item.IsAvailable = *req.IsAvailable
+	}
+	if req.SortOrder != nil {
+		item.SortOrder = *req.SortOrder
+	}
+	return nil
+}
+
+// menuItemTags validates the allergens and dietary tags of a menu item request
+func menuItemTags(req MenuItemRequest) ([]models.MenuItemTag, error) {
+	allergens, err := utilities.NormalizeMenuTags(models.MenuItemTagAllergen, req.Allergens)
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, err.Error()}
+	}
+	dietary, err := utilities.NormalizeMenuTags(models.MenuItemTagDietary, req.DietaryTags)
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, err.Error()}
+	}
+	if err := utilities.ValidateMenuItemTags(allergens, dietary); err != nil {
+		return nil, &requestError{http.StatusUnprocessableEntity, err.Error()}
+	}
+	tags := make([]models.MenuItemTag, 0, len(allergens)+len(dietary))
+	for _, allergen := range allergens {
+		tags = append(tags, models.MenuItemTag{Kind: models.MenuItemTagAllergen, Tag: allergen})
+	}
+	for _, label := range dietary {
+		tags = append(tags, models.MenuItemTag{Kind: models.MenuItemTagDietary, Tag: label})
+	}
+	return tags, nil
+}
+
+// validateModifierGroupRequest checks prices and selection limits of a modifier group request
+func validateModifierGroupRequest(req ModifierGroupRequest) error {
+	for _, modifier := range req.Modifiers {
+		if err := utilities.ValidateMenuPrice("priceDelta of "+modifier.Name, modifier.priceDelta(), models.DefaultCurrency); err != nil {
+			return &requestError{http.StatusBadRequest, err.Error()}
+		}
+	}
+	maxSelections := uint(1)
+	if req.MaxSelections != nil {
+		maxSelections = *req.MaxSelections
+	}
+	if err := utilities.ValidateModifierGroupLimits(req.MinSelections, maxSelections, len(req.Modifiers)); err != nil {
+		return &requestError{http.StatusBadRequest, err.Error()}
+	}
+	return nil
+}
+
+// reorder sets sort_order on the given rows to their position in ids. ids must list every row in scope exactly once.
+func reorder(tx *gorm.DB, model interface{}, column string, scope *gorm.DB, ids []uint) error {
+	var existing []uint
+	if err := scope.Model(model).Pluck(column, &existing).Error; err != nil {
+		return err
+	}
+	if len(uniqueIDs(ids)) != len(ids) || len(ids) != len(existing) {
+		return &requestError{http.StatusBadRequest, "ids must list every entry exactly once"}
+	}
+	known := make(map[uint]struct{}, len(existing))
+	for _, id := range existing {
+		known[id] = struct{}{}
+	}
+	for position, id := range ids {
+		if _, ok := known[id]; !ok {
+			return &requestError{http.StatusBadRequest, fmt.Sprintf("%d is not part of this list", id)}
+		}
+		if err := tx.Model(model).Where(column+" = ?", id).Update("sort_order", position).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetMenu is a handler for fetching a restaurant's menu, grouped into sections in display order.
+// Items that are not in a section are listed under unsectioned.
+func GetMenu(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+
+		itemOrder := func(db *gorm.DB) *gorm.DB { return db.Order("sort_order, menu_id") }
+		var sections []models.MenuSection
+		err := db.Preload("Items", itemOrder).
+			Preload("Items.ModifierGroups.Modifiers").
+			Preload("Items.Tags").
+			Where("restaurant_id = ?", restaurant.RestaurantId).
+			Order("sort_order, menu_section_id").
+			Find(&sections).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching menu"})
+			return
+		}
+		var unsectioned []models.MenuItem
+		err = itemOrder(db.Preload("ModifierGroups.Modifiers").Preload("Tags")).
+			Where("restaurant_id = ? AND menu_section_id IS NULL", restaurant.RestaurantId).
+			Find(&unsectioned).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching menu"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"sections": sections, "unsectioned": unsectioned})
+	}
+}
+
+// SearchMenu is a handler for searching a restaurant's menu. excludeAllergens lists allergens the items must
+// not contain and dietary lists tags they must all carry, both comma separated. q matches item names and
+// descriptions. Unavailable items are left out unless includeUnavailable is true.
+func SearchMenu(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		filter, err := utilities.ParseMenuFilter(utilities.SplitListParam(c.Query("excludeAllergens")), utilities.SplitListParam(c.Query("dietary")))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		includeUnavailable, err := utilities.ParseBoolParam(c.Query("includeUnavailable"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "includeUnavailable must be true or false"})
+			return
+		}
+
+		table := utilities.TableName(db, &models.MenuItem{})
+		query := db.Preload("ModifierGroups.Modifiers").Preload("Tags").
+			Where(table+".restaurant_id = ?", restaurant.RestaurantId)
+		if includeUnavailable == nil || !*includeUnavailable {
+			query = query.Where(table+".is_available = ?", true)
+		}
+		if search := c.Query("q"); search != "" {
+			pattern := "%" + search + "%"
+			query = query.Where("("+table+".name_of_item LIKE ? OR "+table+".description LIKE ?)", pattern, pattern)
+		}
+		query = filter.Apply(query)
+
+		var items []models.MenuItem
+		if err := query.Order(table + ".menu_section_id, " + table + ".sort_order, " + table + ".menu_id").Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching menu"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"items": items,
+			"count": len(items),
+			"filters": gin.H{
+				"excludeAllergens": filter.ExcludeAllergens,
+				"dietary":          filter.RequireDietary,
+			},
+		})
+	}
+}
+
+// CreateMenuSection is a handler for adding a section to a restaurant's menu
+func CreateMenuSection(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		var req MenuSectionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+
+		section := models.MenuSection{
+			RestaurantID: restaurant.RestaurantId,
+			Name:         req.Name,
+			Description:  req.Description,
+		}
+		if req.SortOrder != nil {
+			section.SortOrder = *req.SortOrder
+		} else {
+			var count int64
+			db.Model(&models.MenuSection{}).Where("restaurant_id = ?", restaurant.RestaurantId).Count(&count)
+			section.SortOrder = int(count)
+		}
+		if err := db.Create(&section).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating menu section"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"section": section})
+	}
+}
+
+// UpdateMenuSection is a handler for renaming or describing a menu section
+func UpdateMenuSection(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		sectionID, ok := parseIDParam(c, "sectionId")
+		if !ok {
+			return
+		}
+		var req MenuSectionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+
+		var section models.MenuSection
+		if err := findRestaurantRecord(db, &section, "menu_section_id", sectionID, restaurantID, "Menu section"); err != nil {
+			respondMenuError(c, err, "updating menu section")
+			return
+		}
+		section.Name = req.Name
+		section.Description = req.Description
+		if req.SortOrder != nil {
+			section.SortOrder = *req.SortOrder
+		}
+		if err := db.Omit("Items").Save(&section).Error; err != nil {
+			respondMenuError(c, err, "updating menu section")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"section": section})
+	}
+}
+
+// DeleteMenuSection is a handler for removing a menu section. Its items stay on the menu without a section.
+func DeleteMenuSection(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		sectionID, ok := parseIDParam(c, "sectionId")
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var section models.MenuSection
+			if err := findRestaurantRecord(tx, &section, "menu_section_id", sectionID, restaurantID, "Menu section"); err != nil {
+				return err
+			}
+			if err := tx.Model(&models.MenuItem{}).Where("menu_section_id = ?", sectionID).Update("menu_section_id", nil).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&section).Error
+		})
+		if err != nil {
+			respondMenuError(c, err, "deleting menu section")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Menu section deleted"})
+	}
+}
+
+// ReorderMenuSections is a handler for setting the display order of every section of a menu
+func ReorderMenuSections(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		var req ReorderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			scope := tx.Where("restaurant_id = ?", restaurantID)
+			return reorder(tx, &models.MenuSection{}, "menu_section_id", scope, req.IDs)
+		})
+		if err != nil {
+			respondMenuError(c, err, "reordering menu sections")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Menu sections reordered"})
+	}
+}
+
+// CreateMenuItem is a handler for adding an item to a restaurant's menu
+func CreateMenuItem(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		var req MenuItemRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+
+		item := models.MenuItem{RestaurantID: restaurant.RestaurantId, IsAvailable: true}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := applyMenuItemRequest(tx, &item, req); err != nil {
+				return err
+			}
+			groups, err := loadModifierGroups(tx, restaurant.RestaurantId, req.ModifierGroupIDs)
+			if err != nil {
+				return err
+			}
+			item.ModifierGroups = groups
+			if item.Tags, err = menuItemTags(req); err != nil {
+				return err
+			}
+			return tx.Omit("ModifierGroups.*").Create(&item).Error
+		})
+		if err != nil {
+			respondMenuError(c, err, "creating menu item")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"menuItem": item})
+	}
+}
+
+// UpdateMenuItem is a handler for replacing the details, section and modifier groups of a menu item
+func UpdateMenuItem(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		menuID, ok := parseIDParam(c, "menuId")
+		if !ok {
+			return
+		}
+		var req MenuItemRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+
+		var item models.MenuItem
+		wasAvailable := false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := findRestaurantRecord(tx, &item, "menu_id", menuID, restaurantID, "Menu item"); err != nil {
+				return err
+			}
+			wasAvailable = item.IsAvailable
+			if err := applyMenuItemRequest(tx, &item, req); err != nil {
+				return err
+			}
+			groups, err := loadModifierGroups(tx, restaurantID, req.ModifierGroupIDs)
+			if err != nil {
+				return err
+			}
+			tags, err := menuItemTags(req)
+			if err != nil {
+				return err
+			}
+			if err := tx.Omit("ModifierGroups", "Tags", "Restaurant").Save(&item).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&item).Association("ModifierGroups").Replace(groups); err != nil {
+				return err
+			}
+			if err := tx.Where("menu_id = ?", item.MenuID).Delete(&models.MenuItemTag{}).Error; err != nil {
+				return err
+			}
+			for i := range tags {
+				tags[i].MenuID = item.MenuID
+			}
+			if len(tags) > 0 {
+				if err := tx.Create(&tags).Error; err != nil {
+					return err
+				}
+			}
+			item.ModifierGroups = groups
+			item.Tags = tags
+			return nil
+		})
+		if err != nil {
+			respondMenuError(c, err, "updating menu item")
+			return
+		}
+		if wasAvailable != item.IsAvailable {
+			publishMenuItemAvailability(db, &item)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"menuItem": item})
+	}
+}
+
+// DeleteMenuItem is a handler for removing an item from the menu. Items that have been ordered are
+// kept for the order history and can only be marked unavailable.
+func DeleteMenuItem(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		menuID, ok := parseIDParam(c, "menuId")
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var item models.MenuItem
+			if err := findRestaurantRecord(tx, &item, "menu_id", menuID, restaurantID, "Menu item"); err != nil {
+				return err
+			}
+			var ordered int64
+			if err := tx.Model(&models.OrderItem{}).Where("menu_id = ?", menuID).Count(&ordered).Error; err != nil {
+				return err
+			}
+			if ordered > 0 {
+				return &requestError{http.StatusConflict, "Menu item has been ordered, mark it unavailable instead"}
+			}
+			if err := tx.Model(&item).Association("ModifierGroups").Clear(); err != nil {
+				return err
+			}
+			if err := tx.Where("menu_id = ?", item.MenuID).Delete(&models.MenuItemTag{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&item).Error
+		})
+		if err != nil {
+			respondMenuError(c, err, "deleting menu item")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Menu item deleted"})
+	}
+}
+
+// ReorderMenuItems is a handler for setting the display order of every item in a menu section
+func ReorderMenuItems(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		sectionID, ok := parseIDParam(c, "sectionId")
+		if !ok {
+			return
+		}
+		var req ReorderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var section models.MenuSection
+			if err := findRestaurantRecord(tx, &section, "menu_section_id", sectionID, restaurantID, "Menu section"); err != nil {
+				return err
+			}
+			scope := tx.Where("menu_section_id = ?", sectionID)
+			return reorder(tx, &models.MenuItem{}, "menu_id", scope, req.IDs)
+		})
+		if err != nil {
+			respondMenuError(c, err, "reordering menu items")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Menu items reordered"})
+	}
+}
+
+// CreateModifierGroup is a handler for adding a modifier group with its modifiers
+func CreateModifierGroup(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		var req ModifierGroupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+		if err := validateModifierGroupRequest(req); err != nil {
+			respondMenuError(c, err, "creating modifier group")
+			return
+		}
+
+		group := models.ModifierGroup{
+			RestaurantID:  restaurant.RestaurantId,
+			Name:          req.Name,
+			MinSelections: req.MinSelections,
+			MaxSelections: 1,
+			SortOrder:     req.SortOrder,
+		}
+		if req.MaxSelections != nil {
+			group.MaxSelections = *req.MaxSelections
+		}
+		for position, modifier := range req.Modifiers {
+			if modifier.ModifierID != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "New modifiers cannot have a modifierId"})
+				return
+			}
+			available := modifier.IsAvailable == nil || *modifier.IsAvailable
+			group.Modifiers = append(group.Modifiers, models.Modifier{
+				Name:        modifier.Name,
+				PriceDelta:  modifier.priceDelta(),
+				IsAvailable: available,
+				SortOrder:   position,
+			})
+		}
+		if err := db.Create(&group).Error; err != nil {
+			respondMenuError(c, err, "creating modifier group")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"modifierGroup": group})
+	}
+}
+
+// UpdateModifierGroup is a handler for replacing a modifier group and its list of modifiers.
+// Modifiers sent with their ID are updated, new ones are created and those left out are removed.
+func UpdateModifierGroup(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		groupID, ok := parseIDParam(c, "groupId")
+		if !ok {
+			return
+		}
+		var req ModifierGroupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+		if err := validateModifierGroupRequest(req); err != nil {
+			respondMenuError(c, err, "updating modifier group")
+			return
+		}
+
+		var group models.ModifierGroup
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Preload("Modifiers").Where("modifier_group_id = ? AND restaurant_id = ?", groupID, restaurantID).First(&group).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return &requestError{http.StatusNotFound, "Modifier group not found"}
+				}
+				return err
+			}
+			existing := make(map[uint]models.Modifier, len(group.Modifiers))
+			for _, modifier := range group.Modifiers {
+				existing[modifier.ModifierID] = modifier
+			}
+
+			modifiers := make([]models.Modifier, 0, len(req.Modifiers))
+			kept := make(map[uint]struct{})
+			for position, modifierReq := range req.Modifiers {
+				modifier := models.Modifier{ModifierGroupID: group.ModifierGroupID}
+				if modifierReq.ModifierID != nil {
+					current, ok := existing[*modifierReq.ModifierID]
+					if !ok {
+						return &requestError{http.StatusUnprocessableEntity, fmt.Sprintf("Modifier %d does not belong to this group", *modifierReq.ModifierID)}
+					}
+					modifier = current
+					kept[current.ModifierID] = struct{}{}
+				}
+				modifier.Name = modifierReq.Name
+				modifier.PriceDelta = modifierReq.priceDelta()
+				modifier.IsAvailable = modifierReq.IsAvailable == nil || *modifierReq.IsAvailable
+				modifier.SortOrder = position
+				if err := tx.Save(&modifier).Error; err != nil {
+					return err
+				}
+				modifiers = append(modifiers, modifier)
+			}
+			for id := range existing {
+				if _, ok := kept[id]; ok {
+					continue
+				}
+				if err := tx.Delete(&models.Modifier{}, id).Error; err != nil {
+					return err
+				}
+			}
+
+			group.Name = req.Name
+			group.MinSelections = req.MinSelections
+			group.MaxSelections = 1
+			if req.MaxSelections != nil {
+				group.MaxSelections = *req.MaxSelections
+			}
+			group.SortOrder = req.SortOrder
+			group.Modifiers = modifiers
+			return tx.Omit("Modifiers").Save(&group).Error
+		})
+		if err != nil {
+			respondMenuError(c, err, "updating modifier group")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"modifierGroup": group})
+	}
+}
+
+// DeleteModifierGroup is a handler for removing a modifier group from the menu and from every item offering it
+func DeleteModifierGroup(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		groupID, ok := parseIDParam(c, "groupId")
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var group models.ModifierGroup
+			if err := findRestaurantRecord(tx, &group, "modifier_group_id", groupID, restaurantID, "Modifier group"); err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM menu_item_modifier_groups WHERE modifier_group_id = ?", groupID).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("modifier_group_id = ?", groupID).Delete(&models.Modifier{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&group).Error
+		})
+		if err != nil {
+			respondMenuError(c, err, "deleting modifier group")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Modifier group deleted"})
+	}
+}
@@ -0,0 +1,491 @@
+// This file contains the handlers for the order endpoints
+//
+// The handlers here are as follows:
+// - CreateOrder
+// - GetOrders
+// - GetOrder
+// - AddOrderItems
+// - RemoveOrderItem
+// - UpdateOrderStatus
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderItemRequest is one line of an order as sent by a client
+type OrderItemRequest struct {
+	MenuID      uint    `json:"menuId" binding:"required"`
+	Quantity    uint    `json:"quantity" binding:"required,min=1,max=99"`
+	Notes       *string `json:"notes" binding:"omitempty,max=255"`
+	ModifierIDs []uint  `json:"modifierIds"` // Choices from the item's modifier groups
+}
+
+// CreateOrderRequest is the request body for opening an order against a reservation
+type CreateOrderRequest struct {
+	ReservationID uint               `json:"reservationId" binding:"required"`
+	Items         []OrderItemRequest `json:"items" binding:"dive"`
+}
+
+// AddOrderItemsRequest is the request body for adding items to an open order
+type AddOrderItemsRequest struct {
+	Items []OrderItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+// UpdateOrderStatusRequest is the request body for moving an order through its lifecycle
+type UpdateOrderStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+}
+
+// isStaffCaller reports whether the authenticated user works at the restaurant in any role
+func isStaffCaller(c *gin.Context, db *gorm.DB, restaurantID uint) bool {
+	return callerHasRestaurantRole(c, db, restaurantID, utilities.RestaurantAllRoles...)
+}
+
+// customerMayTransition reports whether a customer may move their own order between two statuses.
+// Customers can submit, recall a submitted order that the kitchen has not started, or void an open order.
+// Neither recalling nor voiding is allowed once a payment of the order is pending or made; UpdateOrderStatus
+// checks that for staff too.
+func customerMayTransition(from, to string) bool {
+	switch from {
+	case models.OrderStatusOpen:
+		return to == models.OrderStatusSubmitted || to == models.OrderStatusVoided
+	case models.OrderStatusSubmitted:
+		return to == models.OrderStatusOpen
+	}
+	return false
+}
+
+// checkOrderUnpaid refuses changes to what an order charges once it is paid or a payment of it is pending,
+// since the payment would no longer cover its items
+func checkOrderUnpaid(tx *gorm.DB, order *models.Order) error {
+	if order.IsPaid {
+		return &requestError{http.StatusConflict, "Order is paid, so its items can no longer be changed"}
+	}
+	var pending int64
+	err := tx.Model(&models.Payment{}).
+		Where("order_id = ? AND status = ?", order.OrderID, models.PaymentStatusPending).
+		Count(&pending).Error
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return &requestError{http.StatusConflict, "Order has a payment in progress, so its items can no longer be changed"}
+	}
+	return nil
+}
+
+// checkOrderVoidable refuses voiding an order while a payment of it is pending, or is paid and has not
+// been fully refunded, since voiding does not give the money back
+func checkOrderVoidable(tx *gorm.DB, order *models.Order) error {
+	var held int64
+	err := tx.Model(&models.Payment{}).
+		Where("order_id = ? AND status IN ?", order.OrderID,
+			[]string{models.PaymentStatusPending, models.PaymentStatusCompleted, models.PaymentStatusPartiallyRefunded}).
+		Count(&held).Error
+	if err != nil {
+		return err
+	}
+	if held > 0 {
+		return &requestError{http.StatusConflict, "Order has a payment that is pending or not fully refunded; refund it before voiding the order"}
+	}
+	return nil
+}
+
+// respondOrderError maps order errors to an HTTP response
+func respondOrderError(c *gin.Context, err error, action string) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		c.JSON(reqErr.Status, gin.H{"error": reqErr.Message})
+		return
+	}
+	fmt.Printf("Error %s order: %v\n", action, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + " order"})
+}
+
+// saveOrderTotal recalculates the order's total from its items and stores it
+func saveOrderTotal(tx *gorm.DB, order *models.Order) error {
+	if err := order.RecalculateTotal(); err != nil {
+		return err
+	}
+	return tx.Model(order).Select("total_minor", "total_currency").Updates(order).Error
+}
+
+// buildOrderItems validates the requested menu items against the restaurant's menu and snapshots their prices
+func buildOrderItems(tx *gorm.DB, restaurantID uint, requested []OrderItemRequest) ([]models.OrderItem, error) {
+	items := make([]models.OrderItem, 0, len(requested))
+	for _, req := range requested {
+		var menuItem models.MenuItem
+		err := tx.Preload("ModifierGroups.Modifiers").
+			Where("menu_id = ? AND restaurant_id = ?", req.MenuID, restaurantID).
+			First(&menuItem).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &requestError{http.StatusNotFound, fmt.Sprintf("Menu item %d not found", req.MenuID)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !menuItem.IsAvailable || menuItem.Price == nil {
+			return nil, &requestError{http.StatusUnprocessableEntity, fmt.Sprintf("Menu item %d is not available", req.MenuID)}
+		}
+		chosen, err := utilities.ValidateModifierSelection(menuItem.ModifierGroups, req.ModifierIDs)
+		if err != nil {
+			return nil, &requestError{http.StatusUnprocessableEntity, err.Error()}
+		}
+		name := ""
+		if menuItem.NameOfItem != nil {
+			name = *menuItem.NameOfItem
+		}
+		unitPrice := *menuItem.Price
+		modifiers := make([]models.OrderItemModifier, 0, len(chosen))
+		for _, modifier := range chosen {
+			if unitPrice, err = unitPrice.Add(modifier.PriceDelta); err != nil {
+				return nil, fmt.Errorf("menu item %d: %w", menuItem.MenuID, err)
+			}
+			modifiers = append(modifiers, models.OrderItemModifier{
+				ModifierID: modifier.ModifierID,
+				Name:       modifier.Name,
+				PriceDelta: modifier.PriceDelta,
+			})
+		}
+		items = append(items, models.OrderItem{
+			MenuID:     menuItem.MenuID,
+			NameOfItem: name,
+			Quantity:   req.Quantity,
+			UnitPrice:  unitPrice,
+			Notes:      req.Notes,
+			Status:     models.OrderItemStatusPending,
+			Modifiers:  modifiers,
+		})
+	}
+	return items, nil
+}
+
+// loadOrderForCaller locks an order of the restaurant with its items and checks that the caller may see it.
+// Customers may only access their own orders; staff may access any order of the restaurant.
+func loadOrderForCaller(c *gin.Context, tx *gorm.DB, restaurantID uint, lock bool) (*models.Order, error) {
+	orderID, err := strconv.ParseUint(c.Param("orderId"), 10, 32)
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, "Invalid order ID format"}
+	}
+
+	query := tx.Preload("Items.Modifiers")
+	if lock {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	var order models.Order
+	err = query.Where("order_id = ? AND restaurant_id = ?", orderID, restaurantID).First(&order).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &requestError{http.StatusNotFound, "Order not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	userID, _ := utilities.GetAuthenticatedUserID(c)
+	if order.UserID != userID && !isStaffCaller(c, tx, restaurantID) {
+		return nil, &requestError{http.StatusForbidden, "You cannot access this order"}
+	}
+	return &order, nil
+}
+
+// CreateOrder is a handler for opening an order for a reservation, optionally with its first items
+func CreateOrder(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		userID, ok := utilities.GetAuthenticatedUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		var req CreateOrderRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+
+		var order models.Order
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var reservation models.Reservation
+			err := tx.Where("reservation_id = ? AND restaurant_id = ?", req.ReservationID, restaurantID).First(&reservation).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &requestError{http.StatusNotFound, "Reservation not found"}
+			}
+			if err != nil {
+				return err
+			}
+			if reservation.UserID != userID && !isStaffCaller(c, tx, restaurantID) {
+				return &requestError{http.StatusForbidden, "You cannot order for this reservation"}
+			}
+			if !reservation.IsActive() {
+				return &requestError{http.StatusConflict, "Orders cannot be placed for a cancelled reservation"}
+			}
+
+			items, err := buildOrderItems(tx, restaurantID, req.Items)
+			if err != nil {
+				return err
+			}
+			// The order belongs to the guest who booked, even when staff open it for them
+			order = models.Order{
+				ReservationID: reservation.ReservationID,
+				RestaurantID:  restaurantID,
+				UserID:        reservation.UserID,
+				Status:        models.OrderStatusOpen,
+				Items:         items,
+			}
+			if err := order.RecalculateTotal(); err != nil {
+				return err
+			}
+			return tx.Create(&order).Error
+		})
+		if err != nil {
+			respondOrderError(c, err, "creating")
+			return
+		}
+		publishOrderEvent(db, models.KitchenEventOrderCreated, &order)
+
+		c.JSON(http.StatusCreated, gin.H{"order": order})
+	}
+}
+
+// GetOrders is a handler for listing a restaurant's orders. Staff see every order, customers only their own.
+// Results can be filtered with the status and reservationId query parameters.
+func GetOrders(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+
+		query := db.Preload("Items.Modifiers").Where("restaurant_id = ?", restaurantID)
+		if !isStaffCaller(c, db, restaurantID) {
+			userID, _ := utilities.GetAuthenticatedUserID(c)
+			query = query.Where("user_id = ?", userID)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if reservationID := c.Query("reservationId"); reservationID != "" {
+			query = query.Where("reservation_id = ?", reservationID)
+		}
+
+		var orders []models.Order
+		if err := query.Order("created_at DESC").Find(&orders).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching orders"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"orders": orders, "count": len(orders)})
+	}
+}
+
+// GetOrder is a handler for fetching a single order with its items
+func GetOrder(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+
+		order, err := loadOrderForCaller(c, db, restaurantID, false)
+		if err != nil {
+			respondOrderError(c, err, "fetching")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"order": order})
+	}
+}
+
+// AddOrderItems is a handler for adding items to an order that has not been submitted or paid yet
+func AddOrderItems(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+
+		var req AddOrderItemsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+
+		var order *models.Order
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			order, err = loadOrderForCaller(c, tx, restaurantID, true)
+			if err != nil {
+				return err
+			}
+			if !order.IsEditable() {
+				return &requestError{http.StatusConflict, "Items can only be added to an open order"}
+			}
+			if err := checkOrderUnpaid(tx, order); err != nil {
+				return err
+			}
+
+			items, err := buildOrderItems(tx, restaurantID, req.Items)
+			if err != nil {
+				return err
+			}
+			for i := range items {
+				items[i].OrderID = order.OrderID
+			}
+			if err := tx.Create(&items).Error; err != nil {
+				return err
+			}
+			order.Items = append(order.Items, items...)
+			return saveOrderTotal(tx, order)
+		})
+		if err != nil {
+			respondOrderError(c, err, "updating")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"order": order})
+	}
+}
+
+// RemoveOrderItem is a handler for taking an item off an order. Items of an open order are deleted;
+// once the order has been submitted only staff can void an item, which keeps it on record. Items of an
+// order that is paid or being paid cannot be removed.
+func RemoveOrderItem(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		itemID, ok := parseIDParam(c, "itemId")
+		if !ok {
+			return
+		}
+
+		var order *models.Order
+		var voided *models.OrderItem
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			order, err = loadOrderForCaller(c, tx, restaurantID, true)
+			if err != nil {
+				return err
+			}
+
+			index := -1
+			for i, item := range order.Items {
+				if item.OrderItemID == itemID {
+					index = i
+				}
+			}
+			if index < 0 {
+				return &requestError{http.StatusNotFound, "Order item not found"}
+			}
+			if err := checkOrderUnpaid(tx, order); err != nil {
+				return err
+			}
+
+			switch {
+			case order.IsEditable():
+				if err := tx.Select("Modifiers").Delete(&order.Items[index]).Error; err != nil {
+					return err
+				}
+				order.Items = append(order.Items[:index], order.Items[index+1:]...)
+			case order.Status == models.OrderStatusClosed || order.Status == models.OrderStatusVoided:
+				return &requestError{http.StatusConflict, "Items cannot be removed from a " + order.Status + " order"}
+			case !isStaffCaller(c, tx, restaurantID):
+				return &requestError{http.StatusForbidden, "Only staff can void items of a submitted order"}
+			case !models.CanTransitionOrderItem(order.Items[index].Status, models.OrderItemStatusVoided):
+				return &requestError{http.StatusConflict, "A " + order.Items[index].Status + " item cannot be voided"}
+			default:
+				voided = &order.Items[index]
+				order.Items[index].Status = models.OrderItemStatusVoided
+				if err := tx.Model(&order.Items[index]).Update("status", models.OrderItemStatusVoided).Error; err != nil {
+					return err
+				}
+			}
+			return saveOrderTotal(tx, order)
+		})
+		if err != nil {
+			respondOrderError(c, err, "updating")
+			return
+		}
+		if voided != nil {
+			utilities.PublishKitchenEvent(db, utilities.KitchenEvents, restaurantID, models.KitchenEventItemStatusChanged, &order.OrderID, nil,
+				kitchenItemEvent{OrderID: order.OrderID, ReservationID: order.ReservationID, Item: *voided})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"order": order})
+	}
+}
+
+// UpdateOrderStatus is a handler for moving an order through its lifecycle.
+// Customers can submit, recall or void their own order; staff drive it through the kitchen to closed.
+func UpdateOrderStatus(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+
+		var req UpdateOrderStatusRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+
+		var order *models.Order
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			order, err = loadOrderForCaller(c, tx, restaurantID, true)
+			if err != nil {
+				return err
+			}
+			if !models.CanTransitionOrder(order.Status, req.Status) {
+				return &requestError{http.StatusConflict, fmt.Sprintf("Order cannot move from %s to %s", order.Status, req.Status)}
+			}
+			if !isStaffCaller(c, tx, restaurantID) && !customerMayTransition(order.Status, req.Status) {
+				return &requestError{http.StatusForbidden, "Only staff can move an order to " + req.Status}
+			}
+			if req.Status == models.OrderStatusSubmitted && len(order.Items) == 0 {
+				return &requestError{http.StatusUnprocessableEntity, "An order needs at least one item to be submitted"}
+			}
+			// A recalled order can be changed, and a voided one is never charged
+			switch req.Status {
+			case models.OrderStatusOpen:
+				if err := checkOrderUnpaid(tx, order); err != nil {
+					return err
+				}
+			case models.OrderStatusVoided:
+				if err := checkOrderVoidable(tx, order); err != nil {
+					return err
+				}
+			}
+
+			if err := order.TransitionTo(req.Status, time.Now().UTC()); err != nil {
+				return &requestError{http.StatusConflict, err.Error()}
+			}
+			return tx.Model(order).Select("status", "submitted_at", "served_at", "closed_at").Updates(order).Error
+		})
+		if err != nil {
+			respondOrderError(c, err, "updating")
+			return
+		}
+		publishOrderEvent(db, models.KitchenEventOrderStatusChanged, order)
+
+		c.JSON(http.StatusOK, gin.H{"order": order})
+	}
+}
@@ -0,0 +1,436 @@
+// This file contains the handlers for paying orders through the payments provider
+//
+// The handlers here are as follows:
+// - CreateOrderPayment
+// - ConfirmOrderPayment
+// - GetOrderPayment
+// - loadOrderPayment
+// - reusePendingPayment
+// - intentPaymentStatus
+// - cancelDeclinedIntent
+// - settlePayment
+// - respondPaymentError
+
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/payments"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ConfirmPaymentRequest is the request body for paying with a payment method collected by the client
+type ConfirmPaymentRequest struct {
+	PaymentMethod string `json:"paymentMethod" binding:"required"` // The provider's payment method ID, e.g. pm_card_visa
+}
+
+// PaymentResponse describes a payment to the customer or staff paying an order
+type PaymentResponse struct {
+	PaymentID      uint         `json:"paymentId"`
+	OrderID        *uint        `json:"orderId"`
+	Amount         models.Money `json:"amount"`
+	Status         string       `json:"status"`
+	FailureMessage *string      `json:"failureMessage"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	FinalizedAt    *time.Time   `json:"finalizedAt"`
+}
+
+func newPaymentResponse(payment *models.Payment) PaymentResponse {
+	return PaymentResponse{
+		PaymentID:      payment.PaymentID,
+		OrderID:        payment.OrderID,
+		Amount:         payment.Amount,
+		Status:         payment.Status,
+		FailureMessage: payment.FailureMessage,
+		CreatedAt:      payment.CreatedAt,
+		FinalizedAt:    payment.FinalizedAt,
+	}
+}
+
+// respondPaymentError maps payment errors to an HTTP response
+func respondPaymentError(c *gin.Context, err error, action string) {
+	var reqErr *requestError
+	switch {
+	case errors.As(err, &reqErr):
+		c.JSON(reqErr.Status, gin.H{"error": reqErr.Message})
+	case errors.Is(err, payments.ErrInvalidState):
+		c.JSON(http.StatusConflict, gin.H{"error": "The payments provider cannot " + action + " this payment in its current state"})
+	default:
+		fmt.Printf("Error %s payment: %v\n", action, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Error " + action + " payment"})
+	}
+}
+
+// loadOrderPayment returns an order the caller may access and its payment named by :paymentId, locking the
+// order when lock is set
+func loadOrderPayment(c *gin.Context, db *gorm.DB, restaurantID uint, lock bool) (*models.Order, *models.Payment, error) {
+	order, err := loadOrderForCaller(c, db, restaurantID, lock)
+	if err != nil {
+		return nil, nil, err
+	}
+	paymentID, err := strconv.ParseUint(c.Param("paymentId"), 10, 32)
+	if err != nil {
+		return nil, nil, &requestError{http.StatusBadRequest, "Invalid payment ID format"}
+	}
+	var payment models.Payment
+	err = db.Where("payment_id = ? AND order_id = ?", paymentID, order.OrderID).First(&payment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, &requestError{http.StatusNotFound, "Payment not found"}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if payment.Provider != payments.Default().Name() {
+		return nil, nil, &requestError{http.StatusConflict, "Payment was made with a payments provider that is no longer in use"}
+	}
+	return order, &payment, nil
+}
+
+// intentPaymentStatus returns the payment status an intent amounts to, and why it failed if it did
+func intentPaymentStatus(intent *payments.Intent) (string, string) {
+	switch {
+	case intent.Status == payments.IntentSucceeded:
+		return models.PaymentStatusCompleted, ""
+	case intent.Status == payments.IntentCanceled:
+		return models.PaymentStatusFailed, "Payment was canceled"
+	case intent.Status == payments.IntentRequiresPaymentMethod && intent.LastError != "":
+		return models.PaymentStatusFailed, intent.LastError
+	}
+	return models.PaymentStatusPending, ""
+}
+
+// cancelDeclinedIntent cancels the intent of a payment that is failed because it was declined, so that the
+// customer cannot retry it with its client secret once the order needs a new payment. An intent that is gone,
+// or that succeeded or was canceled in the meantime, is left to settlePayment and the provider's webhooks.
+func cancelDeclinedIntent(ctx context.Context, provider payments.Provider, intentID string) error {
+	_, err := provider.Cancel(ctx, intentID)
+	if errors.Is(err, payments.ErrInvalidState) || errors.Is(err, payments.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// settlePayment moves a pending payment to completed or failed, marking its order paid when it completed.
+// Payments that were settled already are left alone, except that a failed payment the provider later
+// reports as succeeded is completed, e.g. when its intent was paid before it could be canceled. A payment
+// that completes once its order is paid already is flagged, and a refund of all of it is issued; send it
+// with sendIssuedRefunds once the transaction commits. Reconciliation sends it otherwise. Calling it again
+// with the same outcome changes nothing. Call it inside a transaction.
+func settlePayment(tx *gorm.DB, paymentID uint, status, failureMessage string) (*models.Payment, error) {
+	var payment models.Payment
+	if err := tx.Select("payment_id", "order_id").First(&payment, paymentID).Error; err != nil {
+		return nil, err
+	}
+	// Lock the order before the payment, as the payment handlers do, so that two payments of an order
+	// completing at once see each other
+	var order models.Order
+	if payment.OrderID != nil {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("order_id", "is_paid").First(&order, *payment.OrderID).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, paymentID).Error; err != nil {
+		return nil, err
+	}
+	lateSuccess := payment.Status == models.PaymentStatusFailed && status == models.PaymentStatusCompleted
+	if (payment.Status != models.PaymentStatusPending && !lateSuccess) || status == models.PaymentStatusPending {
+		return &payment, nil
+	}
+
+	now := time.Now().UTC()
+	duplicate := status == models.PaymentStatusCompleted && order.IsPaid
+	payment.Status = status
+	payment.FinalizedAt = &now
+	payment.FlaggedAt = nil
+	payment.FailureMessage = nil
+	if status == models.PaymentStatusFailed {
+		payment.FailureMessage = &failureMessage
+	}
+	if duplicate {
+		payment.FlaggedAt = &now
+	}
+	if err := tx.Model(&payment).Select("status", "finalized_at", "flagged_at", "failure_message").Updates(&payment).Error; err != nil {
+		return nil, err
+	}
+
+	switch {
+	case duplicate:
+		// The order was paid by another payment, so this one is given back
+		refund := models.Refund{
+			PaymentID: payment.PaymentID,
+			Amount:    payment.Amount,
+			Reason:    duplicatePaymentRefundReason,
+			Status:    models.RefundStatusPending,
+		}
+		if err := tx.Omit("Payment").Create(&refund).Error; err != nil {
+			return nil, err
+		}
+	case status == models.PaymentStatusCompleted && payment.OrderID != nil:
+		if err := tx.Model(&models.Order{}).Where("order_id = ?", *payment.OrderID).Update("is_paid", true).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &payment, nil
+}
+
+// reusePendingPayment returns the order's pending payment of its current total and the client secret of
+// its intent, or nil if there is none. Every other pending payment of the order is canceled with the
+// provider and failed, so that at most one payment of an order can be paid. It reports whether one of
+// them turned out to be paid already. Call it inside a transaction with the order locked.
+func reusePendingPayment(ctx context.Context, tx *gorm.DB, order *models.Order) (*models.Payment, string, bool, error) {
+	provider := payments.Default()
+	var pending []models.Payment
+	err := tx.Where("order_id = ? AND status = ? AND provider = ?", order.OrderID, models.PaymentStatusPending, provider.Name()).
+		Order("payment_id DESC").
+		Find(&pending).Error
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	var reusable *models.Payment
+	var clientSecret string
+	paid := false
+	for i := range pending {
+		payment := &pending[i]
+		if reusable == nil && payment.Amount == order.Total {
+			intent, err := provider.GetIntent(ctx, payment.StripePaymentKey)
+			if err != nil && !errors.Is(err, payments.ErrNotFound) {
+				return nil, "", false, err
+			}
+			if err == nil {
+				if status, _ := intentPaymentStatus(intent); status == models.PaymentStatusPending {
+					reusable, clientSecret = payment, intent.ClientSecret
+					continue
+				}
+			}
+		}
+
+		intent, err := provider.Cancel(ctx, payment.StripePaymentKey)
+		if errors.Is(err, payments.ErrInvalidState) {
+			// Paid, processing or canceled since it was last checked
+			intent, err = provider.GetIntent(ctx, payment.StripePaymentKey)
+		}
+		status, failure := models.PaymentStatusFailed, "Payment was canceled"
+		switch {
+		case err == nil:
+			status, failure = intentPaymentStatus(intent)
+		case !errors.Is(err, payments.ErrNotFound):
+			return nil, "", false, err
+		}
+		if status == models.PaymentStatusPending {
+			return nil, "", false, &requestError{http.StatusConflict, "An earlier payment of this order is still processing; try again shortly"}
+		}
+		if _, err := settlePayment(tx, payment.PaymentID, status, failure); err != nil {
+			return nil, "", false, err
+		}
+		paid = paid || status == models.PaymentStatusCompleted
+	}
+	return reusable, clientSecret, paid, nil
+}
+
+// CreateOrderPayment is a handler for starting to pay a submitted order. It responds with the provider's
+// client secret, which lets the client collect card details, and the payment to confirm. While the order
+// has a pending payment of its total, that payment is returned instead of starting another.
+func CreateOrderPayment(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		provider := payments.Default()
+		ctx := c.Request.Context()
+
+		var payment *models.Payment
+		var clientSecret string
+		paid, created := false, false
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// The order stays locked until the payment is saved, so concurrent requests cannot start two payments
+			order, err := loadOrderForCaller(c, tx, restaurantID, true)
+			if err != nil {
+				return err
+			}
+			switch {
+			case order.IsPaid:
+				return &requestError{http.StatusConflict, "Order is already paid"}
+			case order.Status == models.OrderStatusOpen || order.Status == models.OrderStatusVoided:
+				return &requestError{http.StatusConflict, "Only submitted orders can be paid"}
+			case order.Total.Amount <= 0:
+				return &requestError{http.StatusUnprocessableEntity, "Order has nothing to pay"}
+			}
+
+			payment, clientSecret, paid, err = reusePendingPayment(ctx, tx, order)
+			if err != nil || paid || payment != nil {
+				return err
+			}
+
+			description := fmt.Sprintf("Order %d", order.OrderID)
+			// A retry after a response was lost gets the intent the first attempt created
+			var idempotencyKey string
+			if key := c.GetHeader(utilities.IdempotencyKeyHeader); key != "" {
+				idempotencyKey = fmt.Sprintf("order-%d-%x", order.OrderID, sha256.Sum256([]byte(key)))
+			}
+			intent, err := provider.CreateIntent(ctx, payments.IntentParams{
+				Amount:      order.Total.Amount,
+				Currency:    order.Total.Currency,
+				Description: description,
+				Metadata: map[string]string{
+					"order_id":      strconv.FormatUint(uint64(order.OrderID), 10),
+					"restaurant_id": strconv.FormatUint(uint64(restaurantID), 10),
+				},
+				IdempotencyKey: idempotencyKey,
+			})
+			if err != nil {
+				return err
+			}
+
+			payment = &models.Payment{
+				UserID:           order.UserID,
+				RestaurantID:     restaurantID,
+				OrderID:          &order.OrderID,
+				Provider:         provider.Name(),
+				StripePaymentKey: intent.ID,
+				Amount:           order.Total,
+				Status:           models.PaymentStatusPending,
+				PaymentMethod:    "card",
+				Description:      description,
+			}
+			clientSecret, created = intent.ClientSecret, true
+			return tx.Omit("Restaurant").Create(payment).Error
+		})
+		switch {
+		case err != nil:
+			respondPaymentError(c, err, "creating")
+		case paid:
+			c.JSON(http.StatusConflict, gin.H{"error": "Order is already paid"})
+		case created:
+			c.JSON(http.StatusCreated, gin.H{"payment": newPaymentResponse(payment), "clientSecret": clientSecret})
+		default:
+			c.JSON(http.StatusOK, gin.H{"payment": newPaymentResponse(payment), "clientSecret": clientSecret})
+		}
+	}
+}
+
+// ConfirmOrderPayment is a handler for paying a pending payment with a payment method. Authorized payments
+// are captured straight away. A declined payment fails, its intent is canceled and the order needs a new
+// payment.
+func ConfirmOrderPayment(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		var req ConfirmPaymentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "paymentMethod is required"})
+			return
+		}
+		provider := payments.Default()
+		ctx := c.Request.Context()
+
+		var payment *models.Payment
+		var intent *payments.Intent
+		var cardErr *payments.CardError
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// The order stays locked until the outcome is saved, so that it cannot be charged twice
+			order, found, err := loadOrderPayment(c, tx, restaurantID, true)
+			if err != nil {
+				return err
+			}
+			payment = found
+			switch {
+			case payment.Status != models.PaymentStatusPending:
+				return &requestError{http.StatusConflict, "Payment is already " + payment.Status}
+			case order.IsPaid:
+				return &requestError{http.StatusConflict, "Order is already paid"}
+			case order.Total != payment.Amount:
+				return &requestError{http.StatusConflict, "The order total has changed since this payment was started; start a new payment"}
+			}
+
+			intent, err = provider.Confirm(ctx, payment.StripePaymentKey, req.PaymentMethod)
+			if errors.As(err, &cardErr) {
+				if err := cancelDeclinedIntent(ctx, provider, payment.StripePaymentKey); err != nil {
+					return err
+				}
+				payment, err = settlePayment(tx, payment.PaymentID, models.PaymentStatusFailed, cardErr.Message)
+				return err
+			}
+			if err == nil && intent.Status == payments.IntentRequiresCapture {
+				intent, err = provider.Capture(ctx, intent.ID, 0)
+			}
+			if err != nil {
+				return err
+			}
+			status, failure := intentPaymentStatus(intent)
+			if status == models.PaymentStatusFailed && intent.Status != payments.IntentCanceled {
+				if err := cancelDeclinedIntent(ctx, provider, intent.ID); err != nil {
+					return err
+				}
+			}
+			payment, err = settlePayment(tx, payment.PaymentID, status, failure)
+			return err
+		})
+		switch {
+		case err != nil:
+			respondPaymentError(c, err, "confirming")
+		case cardErr != nil:
+			c.JSON(http.StatusPaymentRequired, gin.H{"error": "Payment was declined: " + cardErr.Message, "payment": newPaymentResponse(payment)})
+		default:
+			c.JSON(http.StatusOK, gin.H{"payment": newPaymentResponse(payment), "intentStatus": intent.Status})
+		}
+	}
+}
+
+// GetOrderPayment is a handler for fetching a payment of an order. A pending payment is first brought up
+// to date with the provider, e.g. once the customer has completed authentication with their bank.
+func GetOrderPayment(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		_, payment, err := loadOrderPayment(c, db, restaurantID, false)
+		if err != nil {
+			respondPaymentError(c, err, "fetching")
+			return
+		}
+
+		if payment.Status == models.PaymentStatusPending {
+			provider := payments.Default()
+			ctx := c.Request.Context()
+			intent, err := provider.GetIntent(ctx, payment.StripePaymentKey)
+			if err != nil {
+				respondPaymentError(c, err, "fetching")
+				return
+			}
+			status, failure := intentPaymentStatus(intent)
+			if status == models.PaymentStatusFailed && intent.Status != payments.IntentCanceled {
+				if err := cancelDeclinedIntent(ctx, provider, intent.ID); err != nil {
+					respondPaymentError(c, err, "fetching")
+					return
+				}
+			}
+			err = db.Transaction(func(tx *gorm.DB) error {
+				payment, err = settlePayment(tx, payment.PaymentID, status, failure)
+				return err
+			})
+			if err != nil {
+				respondPaymentError(c, err, "fetching")
+				return
+			}
+			sendIssuedRefunds(ctx, db, payment.PaymentID)
+		}
+		c.JSON(http.StatusOK, gin.H{"payment": newPaymentResponse(payment)})
+	}
+}
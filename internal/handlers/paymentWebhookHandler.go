@@ -0,0 +1,323 @@
+// This file contains the handlers for payment outcomes reported by the payments provider after the fact
+//
+// The handlers here are as follows:
+// - HandlePaymentWebhook
+// - applyIntentEvent
+// - applyRefundEvent
+// - ReconcilePayments
+// - ReconcilePendingPayments
+// - reconcilePendingRefunds
+// - checkPendingRefund
+// - StartPaymentReconciliation
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/payments"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PaymentPendingThreshold  = 30 * time.Minute // How long a payment may stay pending before it is flagged
+	PaymentReconcileInterval = 10 * time.Minute // How often StartPaymentReconciliation checks pending payments
+)
+
+const (
+	maxWebhookPayloadSize   = 1 << 20
+	maxReconciliationPerRun = 100
+)
+
+// PaymentReconciliation reports what a reconciliation run did
+type PaymentReconciliation struct {
+	Checked           int    `json:"checked"`
+	Settled           int    `json:"settled"`           // Payments the provider had completed or failed
+	FlaggedPaymentIDs []uint `json:"flaggedPaymentIds"` // Payments still pending, which need looking into
+	RefundsChecked    int    `json:"refundsChecked"`
+	RefundsSettled    int    `json:"refundsSettled"`   // Refunds the provider had made or refused
+	PendingRefundIDs  []uint `json:"pendingRefundIds"` // Refunds the provider has not settled yet
+	Errors            int    `json:"errors"`           // Payments and refunds that could not be checked this time
+}
+
+// HandlePaymentWebhook is a handler for events sent by the payments provider, such as a payment succeeding
+// once the customer has authenticated with their bank, or a pending refund going through. Each event is
+// handled once however often it is delivered. Errors are answered with a 5xx so that the provider
+// delivers the event again later.
+func HandlePaymentWebhook(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookPayloadSize))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Error reading webhook"})
+			return
+		}
+		provider := payments.Default()
+		event, err := provider.ParseWebhook(payload, c.Request.Header)
+		if errors.Is(err, payments.ErrInvalidSignature) {
+			fmt.Println("Rejected payment webhook:", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook signature"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook payload"})
+			return
+		}
+
+		ctx := c.Request.Context()
+		duplicate := false
+		var paymentID *uint
+		err = db.Transaction(func(tx *gorm.DB) error {
+			record := models.PaymentEvent{Provider: provider.Name(), EventID: event.ID, Type: event.Type}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				duplicate = true
+				return nil
+			}
+
+			var err error
+			switch {
+			case event.Intent != nil:
+				paymentID, err = applyIntentEvent(ctx, tx, provider, event.Intent)
+			case event.Refund != nil:
+				paymentID, err = applyRefundEvent(tx, provider.Name(), event.Refund)
+			}
+			if err != nil || paymentID == nil {
+				return err
+			}
+			return tx.Model(&record).Update("payment_id", *paymentID).Error
+		})
+		if err != nil {
+			fmt.Printf("Error handling payment webhook %s: %v\n", event.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error handling webhook"})
+			return
+		}
+		if event.Intent != nil && paymentID != nil {
+			sendIssuedRefunds(ctx, db, *paymentID)
+		}
+		c.JSON(http.StatusOK, gin.H{"received": true, "duplicate": duplicate})
+	}
+}
+
+// applyIntentEvent settles the payment of an intent, returning its ID, or nil if the intent is not one of ours.
+// A declined intent is canceled as its payment fails.
+func applyIntentEvent(ctx context.Context, tx *gorm.DB, provider payments.Provider, intent *payments.Intent) (*uint, error) {
+	var payment models.Payment
+	err := tx.Where("provider = ? AND stripe_payment_key = ?", provider.Name(), intent.ID).First(&payment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil // An intent created outside this app
+	}
+	if err != nil {
+		return nil, err
+	}
+	status, failure := intentPaymentStatus(intent)
+	if status == models.PaymentStatusFailed && intent.Status != payments.IntentCanceled && payment.Status == models.PaymentStatusPending {
+		if err := cancelDeclinedIntent(ctx, provider, intent.ID); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := settlePayment(tx, payment.PaymentID, status, failure); err != nil {
+		return nil, err
+	}
+	return &payment.PaymentID, nil
+}
+
+// applyRefundEvent settles a refund, returning the ID of its payment, or nil if the refund is not one of
+// ours. Refunds whose provider ID was not saved yet, e.g. because the provider's response was lost, are
+// found by the RefundID sendRefund gave them.
+func applyRefundEvent(tx *gorm.DB, providerName string, result *payments.Refund) (*uint, error) {
+	var refund models.Refund
+	err := tx.Joins("Payment").Where("Payment.provider = ? AND provider_refund_id = ?", providerName, result.ID).First(&refund).Error
+	if refundID, parseErr := strconv.ParseUint(result.Metadata[refundMetadataKey], 10, 32); errors.Is(err, gorm.ErrRecordNotFound) && parseErr == nil {
+		err = tx.Joins("Payment").
+			Where("Payment.provider = ? AND Payment.stripe_payment_key = ? AND refund_id = ?", providerName, result.IntentID, refundID).
+			First(&refund).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := settleRefund(tx, refund.RefundID, result); err != nil {
+		return nil, err
+	}
+	return &refund.PaymentID, nil
+}
+
+// ReconcilePendingPayments checks payments and refunds that have been pending for longer than olderThan
+// with the provider. Those the provider has settled are settled here too, in case their webhook was lost,
+// declined intents are canceled, and the rest of the payments are flagged. Both are checked again on every
+// run until they settle.
+func ReconcilePendingPayments(ctx context.Context, db *gorm.DB, olderThan time.Duration) (*PaymentReconciliation, error) {
+	provider := payments.Default()
+	now := time.Now().UTC()
+	var pending []models.Payment
+	err := db.Where("status = ? AND created_at < ?", models.PaymentStatusPending, now.Add(-olderThan)).
+		Order("flagged_at IS NOT NULL").Order("payment_id").
+		Limit(maxReconciliationPerRun).
+		Find(&pending).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PaymentReconciliation{Checked: len(pending), FlaggedPaymentIDs: []uint{}}
+	for _, payment := range pending {
+		status, failure := models.PaymentStatusPending, ""
+		// Payments made with a provider no longer in use cannot be checked, so they are flagged
+		if payment.Provider == provider.Name() {
+			intent, err := provider.GetIntent(ctx, payment.StripePaymentKey)
+			if err == nil {
+				status, failure = intentPaymentStatus(intent)
+				if status == models.PaymentStatusFailed && intent.Status != payments.IntentCanceled {
+					err = cancelDeclinedIntent(ctx, provider, intent.ID)
+				}
+			}
+			if err != nil && !errors.Is(err, payments.ErrNotFound) {
+				fmt.Printf("Error checking payment %d with the provider: %v\n", payment.PaymentID, err)
+				result.Errors++
+				continue
+			}
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if status != models.PaymentStatusPending {
+				_, err := settlePayment(tx, payment.PaymentID, status, failure)
+				return err
+			}
+			return tx.Model(&models.Payment{}).
+				Where("payment_id = ? AND status = ? AND flagged_at IS NULL", payment.PaymentID, models.PaymentStatusPending).
+				Update("flagged_at", now).Error
+		})
+		switch {
+		case err != nil:
+			fmt.Printf("Error reconciling payment %d: %v\n", payment.PaymentID, err)
+			result.Errors++
+		case status != models.PaymentStatusPending:
+			result.Settled++
+			sendIssuedRefunds(ctx, db, payment.PaymentID)
+		default:
+			result.FlaggedPaymentIDs = append(result.FlaggedPaymentIDs, payment.PaymentID)
+		}
+	}
+	if err := reconcilePendingRefunds(ctx, db, now.Add(-olderThan), result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// reconcilePendingRefunds checks refunds that have been pending since before createdBefore with the
+// provider, settling those it has made or refused. Refunds it never received are sent again.
+func reconcilePendingRefunds(ctx context.Context, db *gorm.DB, createdBefore time.Time, result *PaymentReconciliation) error {
+	provider := payments.Default()
+	table := utilities.TableName(db, &models.Refund{})
+	var pending []models.Refund
+	err := db.Joins("Payment").
+		Where(table+".status = ? AND "+table+".created_at < ?", models.RefundStatusPending, createdBefore).
+		Order(table + ".refund_id").
+		Limit(maxReconciliationPerRun).
+		Find(&pending).Error
+	if err != nil {
+		return err
+	}
+
+	result.RefundsChecked = len(pending)
+	result.PendingRefundIDs = []uint{}
+	for _, refund := range pending {
+		// Refunds of payments made with a provider no longer in use cannot be checked
+		if refund.Payment.Provider != provider.Name() {
+			result.PendingRefundIDs = append(result.PendingRefundIDs, refund.RefundID)
+			continue
+		}
+		outcome, err := checkPendingRefund(ctx, provider, &refund)
+		if err == nil {
+			err = db.Transaction(func(tx *gorm.DB) error {
+				_, _, err := settleRefund(tx, refund.RefundID, outcome)
+				return err
+			})
+		}
+		switch {
+		case err != nil:
+			fmt.Printf("Error reconciling refund %d: %v\n", refund.RefundID, err)
+			result.Errors++
+		case outcome.Status == payments.RefundPending:
+			result.PendingRefundIDs = append(result.PendingRefundIDs, refund.RefundID)
+		default:
+			result.RefundsSettled++
+		}
+	}
+	return nil
+}
+
+// checkPendingRefund returns the provider's view of a pending refund, found by its provider ID or the
+// RefundID sendRefund gave it. A refund the provider does not have is sent again under its idempotency key.
+func checkPendingRefund(ctx context.Context, provider payments.Provider, refund *models.Refund) (*payments.Refund, error) {
+	made, err := provider.ListRefunds(ctx, refund.Payment.StripePaymentKey)
+	if err != nil && !errors.Is(err, payments.ErrNotFound) {
+		return nil, err
+	}
+	refundID := strconv.FormatUint(uint64(refund.RefundID), 10)
+	for i := range made {
+		if (refund.ProviderRefundID != nil && made[i].ID == *refund.ProviderRefundID) || made[i].Metadata[refundMetadataKey] == refundID {
+			return &made[i], nil
+		}
+	}
+	return sendRefund(ctx, refund, &refund.Payment)
+}
+
+// StartPaymentReconciliation runs ReconcilePendingPayments every interval in the background, logging the
+// payments it flags and the refunds still pending
+func StartPaymentReconciliation(db *gorm.DB, interval, olderThan time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			result, err := ReconcilePendingPayments(context.Background(), db, olderThan)
+			if err != nil {
+				log.Printf("payments: reconciliation failed: %v", err)
+				continue
+			}
+			if len(result.FlaggedPaymentIDs) > 0 {
+				log.Printf("payments: %d payments pending for over %s: %v", len(result.FlaggedPaymentIDs), olderThan, result.FlaggedPaymentIDs)
+			}
+			if len(result.PendingRefundIDs) > 0 {
+				log.Printf("payments: %d refunds pending for over %s: %v", len(result.PendingRefundIDs), olderThan, result.PendingRefundIDs)
+			}
+		}
+	}()
+}
+
+// ReconcilePayments is a handler for running reconciliation now. The olderThan query parameter, e.g. 15m,
+// overrides PaymentPendingThreshold.
+func ReconcilePayments(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		olderThan := PaymentPendingThreshold
+		if value := c.Query("olderThan"); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "olderThan must be a duration such as 15m"})
+				return
+			}
+			olderThan = parsed
+		}
+		result, err := ReconcilePendingPayments(c.Request.Context(), db, olderThan)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error reconciling payments"})
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
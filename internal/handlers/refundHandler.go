@@ -0,0 +1,367 @@
+// This file contains the handlers for refunding payments
+//
+// The handlers here are as follows:
+// - CreatePaymentRefund
+// - GetPaymentRefunds
+// - loadRefundablePayment
+// - refundTotals
+// - updatePaymentRefundStatus
+// - settleRefund
+// - sendRefund
+// - sendIssuedRefunds
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/payments"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Metadata key under which refunds sent to the provider carry their RefundID
+const refundMetadataKey = "refund_id"
+
+// Reason of the refunds settlePayment issues for payments that complete once their order is paid already.
+// Refunds the app issues itself have an IssuedBy of 0.
+const duplicatePaymentRefundReason = "The order was already paid by another payment"
+
+// RefundRequest is the request body for refunding a payment
+type RefundRequest struct {
+	Amount *models.Money `json:"amount"` // Defaults to everything not refunded yet
+	Reason string        `json:"reason" binding:"required,max=255"`
+}
+
+// RefundResponse describes a refund of a payment
+type RefundResponse struct {
+	RefundID       uint         `json:"refundId"`
+	PaymentID      uint         `json:"paymentId"`
+	Amount         models.Money `json:"amount"`
+	Reason         string       `json:"reason"`
+	Status         string       `json:"status"`
+	FailureMessage *string      `json:"failureMessage"`
+	IssuedBy       uint         `json:"issuedBy"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	UpdatedAt      time.Time    `json:"updatedAt"`
+}
+
+func newRefundResponse(refund *models.Refund) RefundResponse {
+	return RefundResponse{
+		RefundID:       refund.RefundID,
+		PaymentID:      refund.PaymentID,
+		Amount:         refund.Amount,
+		Reason:         refund.Reason,
+		Status:         refund.Status,
+		FailureMessage: refund.FailureMessage,
+		IssuedBy:       refund.IssuedBy,
+		CreatedAt:      refund.CreatedAt,
+		UpdatedAt:      refund.UpdatedAt,
+	}
+}
+
+// loadRefundablePayment returns the payment named by :paymentId if the caller manages its restaurant,
+// locking it when lock is set. Users with restaurant:administer manage every restaurant.
+func loadRefundablePayment(c *gin.Context, db *gorm.DB, lock bool) (*models.Payment, error) {
+	paymentID, err := strconv.ParseUint(c.Param("paymentId"), 10, 32)
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, "Invalid payment ID format"}
+	}
+	query := db
+	if lock {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	var payment models.Payment
+	err = query.First(&payment, paymentID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &requestError{http.StatusNotFound, "Payment not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := utilities.ResolveRestaurantRole(db, c, payment.RestaurantID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if !utilities.HasRestaurantRole(role, utilities.RestaurantManagementRoles...) {
+		return nil, &requestError{http.StatusForbidden, "You do not have access to this restaurant"}
+	}
+	return &payment, nil
+}
+
+// refundTotals returns how much of a payment has been refunded, and how much is refunded or being refunded
+func refundTotals(tx *gorm.DB, payment *models.Payment) (models.Money, models.Money, error) {
+	succeeded := models.ZeroMoney(payment.Amount.Currency)
+	committed := models.ZeroMoney(payment.Amount.Currency)
+	var refunds []models.Refund
+	err := tx.Where("payment_id = ? AND status IN ?", payment.PaymentID, []string{models.RefundStatusPending, models.RefundStatusSucceeded}).
+		Find(&refunds).Error
+	if err != nil {
+		return succeeded, committed, err
+	}
+	for _, refund := range refunds {
+		if committed, err = committed.Add(refund.Amount); err != nil {
+			return succeeded, committed, err
+		}
+		if refund.Status == models.RefundStatusSucceeded {
+			if succeeded, err = succeeded.Add(refund.Amount); err != nil {
+				return succeeded, committed, err
+			}
+		}
+	}
+	return succeeded, committed, nil
+}
+
+// updatePaymentRefundStatus sets a completed payment's status from its succeeded refunds. Call it inside a
+// transaction with the payment locked.
+func updatePaymentRefundStatus(tx *gorm.DB, payment *models.Payment) error {
+	switch payment.Status {
+	case models.PaymentStatusCompleted, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded:
+	default:
+		return nil
+	}
+	refunded, _, err := refundTotals(tx, payment)
+	if err != nil {
+		return err
+	}
+	status := models.PaymentStatusCompleted
+	switch {
+	case refunded.Amount >= payment.Amount.Amount:
+		status = models.PaymentStatusRefunded
+	case refunded.Amount > 0:
+		status = models.PaymentStatusPartiallyRefunded
+	}
+	if status == payment.Status {
+		return nil
+	}
+	payment.Status = status
+	return tx.Model(payment).Update("status", status).Error
+}
+
+// settleRefund records the provider's view of a refund and updates its payment's status. A pending refund
+// takes any outcome, and a succeeded one can still fail, as the provider may report when the customer's
+// bank returns it. Failed refunds are left alone. Call it inside a transaction.
+func settleRefund(tx *gorm.DB, refundID uint, result *payments.Refund) (*models.Refund, *models.Payment, error) {
+	var refund models.Refund
+	if err := tx.Select("refund_id", "payment_id").First(&refund, refundID).Error; err != nil {
+		return nil, nil, err
+	}
+	// Lock the payment before the refund, in the order CreatePaymentRefund does
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, refund.PaymentID).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&refund, refundID).Error; err != nil {
+		return nil, nil, err
+	}
+	if refund.Status == models.RefundStatusFailed ||
+		(refund.Status == models.RefundStatusSucceeded && result.Status != payments.RefundFailed) {
+		return &refund, &payment, nil
+	}
+
+	switch result.Status {
+	case payments.RefundSucceeded:
+		refund.Status = models.RefundStatusSucceeded
+	case payments.RefundFailed:
+		refund.Status = models.RefundStatusFailed
+		message := result.FailureReason
+		if message == "" {
+			message = "The payments provider could not refund the payment"
+		}
+		refund.FailureMessage = &message
+	default:
+		refund.Status = models.RefundStatusPending
+	}
+	if result.ID != "" {
+		refund.ProviderRefundID = &result.ID
+	}
+	if err := tx.Model(&refund).Select("status", "provider_refund_id", "failure_message").Updates(&refund).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := updatePaymentRefundStatus(tx, &payment); err != nil {
+		return nil, nil, err
+	}
+	return &refund, &payment, nil
+}
+
+// sendRefund asks the provider to make a refund of the payment's intent. Every attempt for a refund is sent
+// under the same idempotency key, so that the provider makes it at most once, and carries its RefundID so
+// that its webhooks can be matched to it. Refunds the provider refuses come back as failed; other errors
+// leave it unknown whether the refund was made.
+func sendRefund(ctx context.Context, refund *models.Refund, payment *models.Payment) (*payments.Refund, error) {
+	refundID := strconv.FormatUint(uint64(refund.RefundID), 10)
+	result, err := payments.Default().Refund(ctx, payments.RefundParams{
+		IntentID: payment.StripePaymentKey,
+		Amount:   refund.Amount.Amount,
+		Metadata: map[string]string{
+			refundMetadataKey: refundID,
+			"payment_id":      strconv.FormatUint(uint64(payment.PaymentID), 10),
+		},
+		IdempotencyKey: "refund-" + refundID,
+	})
+	var cardErr *payments.CardError
+	if errors.Is(err, payments.ErrInvalidState) || errors.Is(err, payments.ErrNotFound) || errors.As(err, &cardErr) {
+		// The provider refused, so the amount can be refunded again
+		return &payments.Refund{Status: payments.RefundFailed, FailureReason: err.Error()}, nil
+	}
+	return result, err
+}
+
+// sendIssuedRefunds sends the refunds settlePayment issued for a payment to the provider and saves the
+// outcome. Errors are logged rather than returned: the refunds stay pending, and reconciliation sends
+// them again.
+func sendIssuedRefunds(ctx context.Context, db *gorm.DB, paymentID uint) {
+	table := utilities.TableName(db, &models.Refund{})
+	var issued []models.Refund
+	err := db.Joins("Payment").
+		Where(table+".payment_id = ? AND "+table+".status = ? AND issued_by = 0 AND provider_refund_id IS NULL", paymentID, models.RefundStatusPending).
+		Find(&issued).Error
+	if err != nil {
+		fmt.Printf("Error loading the refunds issued for payment %d: %v\n", paymentID, err)
+		return
+	}
+	for _, refund := range issued {
+		result, err := sendRefund(ctx, &refund, &refund.Payment)
+		if err == nil {
+			err = db.Transaction(func(tx *gorm.DB) error {
+				_, _, err := settleRefund(tx, refund.RefundID, result)
+				return err
+			})
+		}
+		if err != nil {
+			fmt.Printf("Error sending refund %d of payment %d, it is left pending: %v\n", refund.RefundID, paymentID, err)
+		}
+	}
+}
+
+// CreatePaymentRefund is a handler for refunding some or all of a completed payment with the payments
+// provider, for the owners and managers of the payment's restaurant. The amount is reserved before the
+// provider is asked, so concurrent refunds cannot together exceed the payment. Refunds the provider
+// refuses are kept as failed. When the provider's answer is lost the refund is answered with 202 Accepted
+// and stays pending until reconciliation checks it, so that a retry with the same Idempotency-Key gets
+// it back instead of refunding again.
+func CreatePaymentRefund(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req RefundRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A reason of at most 255 characters and an optional amount are required"})
+			return
+		}
+		reason := strings.TrimSpace(req.Reason)
+		if reason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required"})
+			return
+		}
+		issuerID, _ := utilities.GetAuthenticatedUserID(c)
+		provider := payments.Default()
+
+		var payment *models.Payment
+		var refund models.Refund
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			payment, err = loadRefundablePayment(c, tx, true)
+			if err != nil {
+				return err
+			}
+			switch {
+			case payment.Status == models.PaymentStatusRefunded:
+				return &requestError{http.StatusConflict, "Payment is already fully refunded"}
+			case payment.Status != models.PaymentStatusCompleted && payment.Status != models.PaymentStatusPartiallyRefunded:
+				return &requestError{http.StatusConflict, "Only completed payments can be refunded"}
+			case payment.Provider != provider.Name():
+				return &requestError{http.StatusConflict, "Payment was made with a payments provider that is no longer in use"}
+			}
+
+			_, committed, err := refundTotals(tx, payment)
+			if err != nil {
+				return err
+			}
+			remaining, err := payment.Amount.Sub(committed)
+			if err != nil {
+				return err
+			}
+			amount := remaining
+			if req.Amount != nil {
+				amount = *req.Amount
+			}
+			switch {
+			case amount.Currency != payment.Amount.Currency:
+				return &requestError{http.StatusUnprocessableEntity, "Refund must be in the payment's currency, " + payment.Amount.Currency}
+			case remaining.Amount <= 0:
+				return &requestError{http.StatusConflict, "Nothing is left to refund; other refunds of this payment are still pending"}
+			case amount.Amount <= 0:
+				return &requestError{http.StatusUnprocessableEntity, "Refund amount must be positive"}
+			case amount.Amount > remaining.Amount:
+				return &requestError{http.StatusUnprocessableEntity, fmt.Sprintf("Refund exceeds the %s left to refund", remaining)}
+			}
+
+			refund = models.Refund{
+				PaymentID: payment.PaymentID,
+				Amount:    amount,
+				Reason:    reason,
+				Status:    models.RefundStatusPending,
+				IssuedBy:  issuerID,
+			}
+			return tx.Omit("Payment").Create(&refund).Error
+		})
+		if err != nil {
+			respondPaymentError(c, err, "refunding")
+			return
+		}
+
+		// From here on the refund exists, so every response is one Idempotent keeps: a retry must get this
+		// refund back rather than make another
+		result, err := sendRefund(c.Request.Context(), &refund, payment)
+		if err != nil {
+			// The refund may or may not have been made, so it stays pending and its amount reserved until
+			// reconciliation checks it with the provider
+			fmt.Printf("Error refunding payment %d, refund %d is left pending: %v\n", payment.PaymentID, refund.RefundID, err)
+			c.JSON(http.StatusAccepted, gin.H{"message": "The payments provider did not confirm the refund; it stays pending until checked with the provider", "refund": newRefundResponse(&refund), "payment": newPaymentResponse(payment)})
+			return
+		}
+
+		var settled *models.Refund
+		err = db.Transaction(func(tx *gorm.DB) error {
+			settled, payment, err = settleRefund(tx, refund.RefundID, result)
+			return err
+		})
+		if err != nil {
+			fmt.Printf("Error saving refund %d, it is left pending: %v\n", refund.RefundID, err)
+			c.JSON(http.StatusAccepted, gin.H{"message": "The refund was sent but its outcome could not be saved; it stays pending until checked with the provider", "refund": newRefundResponse(&refund), "payment": newPaymentResponse(payment)})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"refund": newRefundResponse(settled), "payment": newPaymentResponse(payment)})
+	}
+}
+
+// GetPaymentRefunds is a handler for listing the refunds of a payment, failed ones included, oldest first.
+// Like refunding, it is limited to the managers of the payment's restaurant.
+func GetPaymentRefunds(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		payment, err := loadRefundablePayment(c, db, false)
+		if err != nil {
+			respondPaymentError(c, err, "fetching")
+			return
+		}
+		var refunds []models.Refund
+		if err := db.Where("payment_id = ?", payment.PaymentID).Order("created_at, refund_id").Find(&refunds).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching refunds"})
+			return
+		}
+		response := make([]RefundResponse, len(refunds))
+		for i := range refunds {
+			response[i] = newRefundResponse(&refunds[i])
+		}
+		c.JSON(http.StatusOK, gin.H{"payment": newPaymentResponse(payment), "refunds": response})
+	}
+}
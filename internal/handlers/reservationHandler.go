@@ -0,0 +1,352 @@
+// This file contains the handlers for the reservation endpoints
+//
+// The handlers here are as follows:
+// - CreateReservation
+// - UpdateReservation
+// - CancelReservation
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateReservationRequest is the request body for booking a table.
+// StartTime is RFC 3339, or a wall clock time such as 2024-05-01T19:00 in the restaurant's time zone.
+type CreateReservationRequest struct {
+	TableID         *uint  `json:"tableId"` // Optional, a table is picked when omitted
+	PartySize       uint   `json:"partySize" binding:"required,min=1"`
+	StartTime       string `json:"startTime" binding:"required"`
+	DurationMinutes uint   `json:"durationMinutes"` // Optional, defaults to DefaultReservationDuration
+}
+
+// UpdateReservationRequest is the request body for modifying a booking. Omitted fields are left unchanged.
+type UpdateReservationRequest struct {
+	TableID         *uint   `json:"tableId"`
+	PartySize       *uint   `json:"partySize"`
+	StartTime       *string `json:"startTime"`
+	DurationMinutes *uint   `json:"durationMinutes"`
+}
+
+// ReservationResponse is a reservation with its times in both UTC and the restaurant's local time
+type ReservationResponse struct {
+	ReservationID   uint                    `json:"reservationId"`
+	RestaurantID    uint                    `json:"restaurantId"`
+	UserID          uint                    `json:"userId"`
+	TableID         uint                    `json:"tableId"`
+	PartySize       uint                    `json:"partySize"`
+	DurationMinutes uint                    `json:"durationMinutes"`
+	Status          string                  `json:"status"`
+	StartTime       utilities.LocalizedTime `json:"startTime"`
+	EndTime         utilities.LocalizedTime `json:"endTime"`
+	CreatedAt       time.Time               `json:"createdAt"`
+}
+
+// newReservationResponse converts a reservation for the API, localizing its times to loc
+func newReservationResponse(reservation models.Reservation, loc *time.Location) ReservationResponse {
+	return ReservationResponse{
+		ReservationID:   reservation.ReservationID,
+		RestaurantID:    reservation.RestaurantID,
+		UserID:          reservation.UserID,
+		TableID:         reservation.TableID,
+		PartySize:       reservation.PartySize,
+		DurationMinutes: reservation.DurationMinutes,
+		Status:          reservation.Status,
+		StartTime:       utilities.Localize(reservation.Time, loc),
+		EndTime:         utilities.Localize(reservation.EndTime, loc),
+		CreatedAt:       reservation.CreatedAt.UTC(),
+	}
+}
+
+// reservationDuration converts the requested minutes into a validated duration
+func reservationDuration(minutes uint) (time.Duration, error) {
+	if minutes == 0 {
+		return utilities.DefaultReservationDuration, nil
+	}
+	duration := time.Duration(minutes) * time.Minute
+	if duration < utilities.MinReservationDuration || duration > utilities.MaxReservationDuration {
+		return 0, fmt.Errorf("durationMinutes must be between %d and %d",
+			int(utilities.MinReservationDuration.Minutes()), int(utilities.MaxReservationDuration.Minutes()))
+	}
+	return duration, nil
+}
+
+// requestError is returned from inside a transaction to abort it with a specific HTTP response
+type requestError struct {
+	Status  int
+	Message string
+}
+
+func (e *requestError) Error() string {
+	return e.Message
+}
+
+// respondReservationError maps booking errors to an HTTP response
+func respondReservationError(c *gin.Context, err error, action string) {
+	var reqErr *requestError
+	switch {
+	case errors.As(err, &reqErr):
+		c.JSON(reqErr.Status, gin.H{"error": reqErr.Message})
+	case errors.Is(err, utilities.ErrTableNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, utilities.ErrTableTooSmall), errors.Is(err, utilities.ErrTableOutOfService):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, utilities.ErrTableAlreadyBooked), errors.Is(err, utilities.ErrNoTableAvailable):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		fmt.Printf("Error %s reservation: %v\n", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + " reservation"})
+	}
+}
+
+// parseRestaurantID reads and validates the :restaurantId route parameter
+func parseRestaurantID(c *gin.Context) (uint, bool) {
+	restaurantID, err := strconv.ParseUint(c.Param("restaurantId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid restaurant ID format"})
+		return 0, false
+	}
+	return uint(restaurantID), true
+}
+
+// callerHasRestaurantRole reports whether the authenticated user holds one of roles in the restaurant
+func callerHasRestaurantRole(c *gin.Context, db *gorm.DB, restaurantID uint, roles ...string) bool {
+	role, err := utilities.ResolveRestaurantRole(db, c, restaurantID)
+	if err != nil {
+		fmt.Println("Error resolving restaurant role:", err)
+		return false
+	}
+	return utilities.HasRestaurantRole(role, roles...)
+}
+
+// loadReservationForCaller locks a reservation of the restaurant and checks that the caller may change it.
+// Customers may only change their own bookings; front of house staff may change any booking of the restaurant.
+func loadReservationForCaller(c *gin.Context, tx *gorm.DB, restaurantID uint) (*models.Reservation, error) {
+	reservationID, err := strconv.ParseUint(c.Param("reservationId"), 10, 32)
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, "Invalid reservation ID format"}
+	}
+
+	var reservation models.Reservation
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("reservation_id = ? AND restaurant_id = ?", reservationID, restaurantID).
+		First(&reservation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &requestError{http.StatusNotFound, "Reservation not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	userID, _ := utilities.GetAuthenticatedUserID(c)
+	if reservation.UserID != userID && !callerHasRestaurantRole(c, tx, restaurantID, utilities.RestaurantFrontOfHouseRoles...) {
+		return nil, &requestError{http.StatusForbidden, "You cannot modify this reservation"}
+	}
+	return &reservation, nil
+}
+
+// CreateReservation is a handler for booking a table at a restaurant
+func CreateReservation(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+		userID, ok := utilities.GetAuthenticatedUserID(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
+
+		var req CreateReservationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+		duration, err := reservationDuration(req.DurationMinutes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		startTime, err := utilities.ParseRestaurantTime(req.StartTime, restaurant.Location())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !startTime.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "startTime must be in the future"})
+			return
+		}
+
+		window := utilities.ReservationWindow{Start: startTime, End: startTime.Add(duration)}
+		schedule, err := utilities.LoadRestaurantSchedule(db, restaurant)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching opening hours"})
+			return
+		}
+		if open, reason := schedule.IsOpenFor(window); !open {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": reason})
+			return
+		}
+		reservation := models.Reservation{
+			RestaurantID:    restaurantID,
+			UserID:          userID,
+			PartySize:       req.PartySize,
+			DurationMinutes: uint(duration.Minutes()),
+			Time:            window.Start,
+			EndTime:         window.End,
+			Status:          models.ReservationStatusBooked,
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			table, err := utilities.ReserveTable(tx, restaurantID, req.TableID, req.PartySize, window, 0)
+			if err != nil {
+				return err
+			}
+			reservation.TableID = table.TableID
+			return tx.Create(&reservation).Error
+		})
+		if err != nil {
+			respondReservationError(c, err, "creating")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"reservation": newReservationResponse(reservation, restaurant.Location())})
+	}
+}
+
+// UpdateReservation is a handler for changing the time, party size or table of a booking
+func UpdateReservation(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+
+		var req UpdateReservationRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+		if req.PartySize != nil && *req.PartySize == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "partySize must be at least 1"})
+			return
+		}
+
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+
+		var reservation *models.Reservation
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			reservation, err = loadReservationForCaller(c, tx, restaurantID)
+			if err != nil {
+				return err
+			}
+			if !reservation.IsActive() {
+				return &requestError{http.StatusConflict, "Cancelled reservations cannot be modified"}
+			}
+
+			start := reservation.Time
+			if req.StartTime != nil {
+				start, err = utilities.ParseRestaurantTime(*req.StartTime, restaurant.Location())
+				if err != nil {
+					return &requestError{http.StatusBadRequest, err.Error()}
+				}
+				if !start.After(time.Now()) {
+					return &requestError{http.StatusBadRequest, "startTime must be in the future"}
+				}
+			}
+			minutes := reservation.DurationMinutes
+			if req.DurationMinutes != nil {
+				minutes = *req.DurationMinutes
+			}
+			duration, err := reservationDuration(minutes)
+			if err != nil {
+				return &requestError{http.StatusBadRequest, err.Error()}
+			}
+			partySize := reservation.PartySize
+			if req.PartySize != nil {
+				partySize = *req.PartySize
+			}
+			tableID := &reservation.TableID
+			if req.TableID != nil {
+				tableID = req.TableID
+			}
+
+			window := utilities.ReservationWindow{Start: start, End: start.Add(duration)}
+			schedule, err := utilities.LoadRestaurantSchedule(tx, restaurant)
+			if err != nil {
+				return err
+			}
+			if open, reason := schedule.IsOpenFor(window); !open {
+				return &requestError{http.StatusUnprocessableEntity, reason}
+			}
+			table, err := utilities.ReserveTable(tx, restaurantID, tableID, partySize, window, reservation.ReservationID)
+			if req.TableID == nil && (errors.Is(err, utilities.ErrTableAlreadyBooked) || errors.Is(err, utilities.ErrTableTooSmall)) {
+				// The current table no longer fits, so move the booking to any table that does
+				table, err = utilities.ReserveTable(tx, restaurantID, nil, partySize, window, reservation.ReservationID)
+			}
+			if err != nil {
+				return err
+			}
+
+			reservation.TableID = table.TableID
+			reservation.PartySize = partySize
+			reservation.DurationMinutes = uint(duration.Minutes())
+			reservation.Time = window.Start
+			reservation.EndTime = window.End
+			return tx.Save(reservation).Error
+		})
+		if err != nil {
+			respondReservationError(c, err, "updating")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"reservation": newReservationResponse(*reservation, restaurant.Location())})
+	}
+}
+
+// CancelReservation is a handler for cancelling a booking, which frees its table for the booked window
+func CancelReservation(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			reservation, err := loadReservationForCaller(c, tx, restaurantID)
+			if err != nil {
+				return err
+			}
+			if !reservation.IsActive() {
+				return nil
+			}
+			return tx.Model(reservation).Update("status", models.ReservationStatusCancelled).Error
+		})
+		if err != nil {
+			respondReservationError(c, err, "cancelling")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reservation cancelled"})
+	}
+}
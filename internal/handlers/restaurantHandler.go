@@ -14,32 +14,205 @@ package handlers
 
 import (
 	// "log"
-	// "errors"
+	"errors"
 	// "bytes"
 	"fmt"
 	// "io"
 	"net/http"
+	"net/mail"
 	"strconv"
+	"strings"
 	"waitress-backend/internal/models"
 	"waitress-backend/internal/utilities"
 
 	// "github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	// "waitress-backend/internal/utilities"
 )
 
-// EditRestaurant is a handler for editing a restaurant
+// EditRestaurantRequest is a JSON Merge Patch of a restaurant. Keys match the restaurant's JSON fields;
+// members left out are unchanged and null clears a nullable field. CategoryIDs replaces the
+// restaurant's categories and OwnerID may only be changed by super admins.
+type EditRestaurantRequest struct {
+	Name           utilities.PatchField[string]  `json:"Name"`
+	Address        utilities.PatchField[string]  `json:"Address"`
+	Phone          utilities.PatchField[string]  `json:"Phone"`
+	Email          utilities.PatchField[string]  `json:"Email"`
+	Website        utilities.PatchField[string]  `json:"Website"`
+	NumberOfTables utilities.PatchField[int]     `json:"NumberOfTables"`
+	Latitude       utilities.PatchField[float64] `json:"Latitude"`
+	Longitude      utilities.PatchField[float64] `json:"Longitude"`
+	TimeZone       utilities.PatchField[string]  `json:"TimeZone"`
+	ImageURL       utilities.PatchField[string]  `json:"ImageURL"`
+	CategoryIDs    utilities.PatchField[[]uint]  `json:"CategoryIDs"`
+	OwnerID        utilities.PatchField[uint]    `json:"OwnerID"`
+}
+
+// requiredText applies a patch to a column that cannot be empty
+func requiredText(updates map[string]interface{}, column, field string, patch utilities.PatchField[string]) error {
+	if !patch.Set {
+		return nil
+	}
+	if patch.Null || strings.TrimSpace(patch.Value) == "" {
+		return fmt.Errorf("%s cannot be empty", field)
+	}
+	updates[column] = strings.TrimSpace(patch.Value)
+	return nil
+}
+
+// nullableValue applies a patch to a nullable column, clearing it on null
+func nullableValue[T any](updates map[string]interface{}, column string, patch utilities.PatchField[T]) {
+	if !patch.Set {
+		return
+	}
+	if patch.Null {
+		updates[column] = nil
+		return
+	}
+	updates[column] = patch.Value
+}
+
+// restaurantUpdates validates a restaurant patch and converts it into column updates
+func restaurantUpdates(req EditRestaurantRequest) (map[string]interface{}, error) {
+	updates := make(map[string]interface{})
+	for _, field := range []struct {
+		column, name string
+		patch        utilities.PatchField[string]
+	}{
+		{"name", "Name", req.Name},
+		{"address", "Address", req.Address},
+		{"phone", "Phone", req.Phone},
+		{"email", "Email", req.Email},
+	} {
+		if err := requiredText(updates, field.column, field.name, field.patch); err != nil {
+			return nil, err
+		}
+	}
+	if req.Email.HasValue() {
+		if _, err := mail.ParseAddress(req.Email.Value); err != nil {
+			return nil, errors.New("Email is not a valid email address")
+		}
+	}
+	if req.TimeZone.Set {
+		if req.TimeZone.Null {
+			return nil, errors.New("TimeZone cannot be empty")
+		}
+		if err := utilities.ValidateTimeZone(req.TimeZone.Value); err != nil {
+			return nil, err
+		}
+		updates["time_zone"] = req.TimeZone.Value
+	}
+	if req.NumberOfTables.HasValue() && req.NumberOfTables.Value < 0 {
+		return nil, errors.New("NumberOfTables cannot be negative")
+	}
+	if req.Latitude.HasValue() && (req.Latitude.Value < -90 || req.Latitude.Value > 90) {
+		return nil, errors.New("Latitude must be between -90 and 90")
+	}
+	if req.Longitude.HasValue() && (req.Longitude.Value < -180 || req.Longitude.Value > 180) {
+		return nil, errors.New("Longitude must be between -180 and 180")
+	}
+	if req.OwnerID.Null {
+		return nil, errors.New("OwnerID cannot be empty")
+	}
+	nullableValue(updates, "website", req.Website)
+	nullableValue(updates, "number_of_tables", req.NumberOfTables)
+	nullableValue(updates, "latitude", req.Latitude)
+	nullableValue(updates, "longitude", req.Longitude)
+	nullableValue(updates, "image_url", req.ImageURL)
+	if req.OwnerID.Set {
+		updates["owner_id"] = req.OwnerID.Value
+	}
+	return updates, nil
+}
+
+// EditRestaurant is a handler for partially updating a restaurant with a JSON Merge Patch.
+// The route only lets the restaurant's owners through; super admins and devs may edit any restaurant.
 func EditRestaurant(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Here, we will query for a restaurant based
-		// On the owner/admin. Using a discriminator,
-		// We can control what the flow of this func
-		// looks like. For example, we can edit the tables,
-		// menu, employees, available tables? etc.
-		// delimiter := c.PostForm("delimiter")
-		// fmt.printf("%+v\n",delimiter)
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+		var req EditRestaurantRequest
+		if err := utilities.DecodeMergePatch(c.Request.Body, &req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload", "message": err.Error()})
+			return
+		}
+		if req.OwnerID.Set && !utilities.HasPermission(utilities.GetAuthenticatedAuthType(c), utilities.PermissionRestaurantTransfer) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to change a restaurant's owner"})
+			return
+		}
+		updates, err := restaurantUpdates(req)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if req.OwnerID.HasValue() {
+				var owner models.User
+				if err := tx.First(&owner, req.OwnerID.Value).Error; err != nil {
+					if errors.Is(err, gorm.ErrRecordNotFound) {
+						return &requestError{http.StatusUnprocessableEntity, "OwnerID does not match a user"}
+					}
+					return err
+				}
+			}
+			if len(updates) > 0 {
+				if err := tx.Model(restaurant).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+			if req.OwnerID.HasValue() {
+				// The previous owner loses their membership along with the restaurant
+				err := tx.Model(&models.Staff{}).
+					Where("restaurant_id = ? AND role = ? AND user_id <> ?", restaurant.RestaurantId, models.StaffRoleOwner, req.OwnerID.Value).
+					Update("is_active", false).Error
+				if err != nil {
+					return err
+				}
+				owner := models.Staff{UserID: req.OwnerID.Value, RestaurantID: restaurant.RestaurantId, Role: models.StaffRoleOwner, IsActive: true}
+				err = tx.Clauses(clause.OnConflict{DoUpdates: clause.AssignmentColumns([]string{"role", "is_active"})}).Create(&owner).Error
+				if err != nil {
+					return err
+				}
+			}
+			if req.CategoryIDs.Set {
+				categories := []models.Category{}
+				ids := uniqueIDs(req.CategoryIDs.Value)
+				if len(ids) > 0 {
+					if err := tx.Where("category_id IN ?", ids).Find(&categories).Error; err != nil {
+						return err
+					}
+					if len(categories) != len(ids) {
+						return &requestError{http.StatusUnprocessableEntity, "CategoryIDs contains an unknown category"}
+					}
+				}
+				if err := tx.Model(restaurant).Association("Categories").Replace(categories); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			var reqErr *requestError
+			if errors.As(err, &reqErr) {
+				c.JSON(reqErr.Status, gin.H{"error": reqErr.Message})
+				return
+			}
+			fmt.Println("Error editing restaurant:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error editing restaurant"})
+			return
+		}
 
+		var updated models.Restaurant
+		if err := db.Preload("Categories").First(&updated, restaurant.RestaurantId).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching restaurant"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"restaurant": updated})
 	}
 }
 
@@ -47,6 +220,9 @@ type LocationRequest struct {
 	Latitude  float64 `json:"latitude"`
 	Longitude float64 `json:"longitude"`
 	ApiToken  string  `json:"apiToken"`
+	// Optional menu constraints, only restaurants with an available item meeting them are returned
+	ExcludeAllergens []string `json:"excludeAllergens"`
+	Dietary          []string `json:"dietary"`
 }
 
 // GetLocalRestaurants is a handler for getting local restaurants based on user location
@@ -71,6 +247,23 @@ func GetLocalRestaurants(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
 
 		maxDistance := 100000.0 // Max distance in meters, increase for testing
 
+		menuFilter, err := utilities.ParseMenuFilter(locationReq.ExcludeAllergens, locationReq.Dietary)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		menuCondition := ""
+		args := []interface{}{userLat, userLong, userLat}
+		if !menuFilter.IsEmpty() {
+			condition, filterArgs := menuFilter.SQL(db, "mi.menu_id")
+			menuCondition = `WHERE EXISTS (
+				SELECT 1 FROM ` + utilities.TableName(db, &models.MenuItem{}) + ` mi
+				WHERE mi.restaurant_id = restaurants.restaurant_id AND mi.is_available = true AND ` + condition + `
+			)`
+			args = append(args, filterArgs...)
+		}
+		args = append(args, maxDistance)
+
 		// SQL query to calculate distance and filter restaurants
 		query := `
 			SELECT *, (
@@ -80,12 +273,13 @@ func GetLocalRestaurants(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
 				)
 			) AS distance
 			FROM restaurants
+			` + menuCondition + `
 			HAVING distance < ?
 			ORDER BY distance
 		`
 		fmt.Printf("Query parameters: userLat=%f, userLong=%f, maxDistance=%f\n", userLat, userLong, maxDistance)
 		// Use raw SQL query to get nearby restaurants
-		err := db.Raw(query, userLat, userLong, userLat, maxDistance).Scan(&restaurants).Error
+		err = db.Raw(query, args...).Scan(&restaurants).Error
 		if err != nil {
 			fmt.Println("Error executing the query:", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching nearby restaurants"})
@@ -114,8 +308,26 @@ func CreateRestaurant(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
 			return
 		}
 
-		// Insert the restaurant into the database
-		if err := db.Create(&restaurant).Error; err != nil {
+		if restaurant.TimeZone == "" {
+			restaurant.TimeZone = "UTC"
+		}
+		if err := utilities.ValidateTimeZone(restaurant.TimeZone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Insert the restaurant and its owner's membership into the database
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&restaurant).Error; err != nil {
+				return err
+			}
+			if restaurant.OwnerID == 0 {
+				return nil
+			}
+			owner := models.Staff{UserID: restaurant.OwnerID, RestaurantID: restaurant.RestaurantId, Role: models.StaffRoleOwner, IsActive: true}
+			return tx.Create(&owner).Error
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating restaurant", "message": err.Error()})
 			return
 		}
@@ -146,16 +358,24 @@ func GetReservations(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
 
 		fmt.Println("Received apiToken:", apiToken)
 
-		// Query for reservations - you'll need to implement the filtering logic here
-		err := db.Find(&reservations).Error
+		restaurant, ok := loadRestaurant(c, db)
+		if !ok {
+			return
+		}
+
+		err := db.Where("restaurant_id = ?", restaurant.RestaurantId).Order("time").Find(&reservations).Error
 		if err != nil {
 			fmt.Println("Error executing the query:", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching reservations"})
 			return
 		}
 
-		// Return reservations
-		c.JSON(http.StatusOK, gin.H{"reservations": reservations})
+		// Return reservations with times in both UTC and the restaurant's time zone
+		response := make([]ReservationResponse, 0, len(reservations))
+		for _, reservation := range reservations {
+			response = append(response, newReservationResponse(reservation, restaurant.Location()))
+		}
+		c.JSON(http.StatusOK, gin.H{"reservations": response, "timeZone": restaurant.Location().String()})
 	}
 }
 
@@ -173,7 +393,7 @@ func GetSingleRestaurant(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
 		var restaurant models.Restaurant
 
 		// Query for the restaurant by ID with all necessary associations
-		err = db.Preload("MenuItems").Preload("Categories").Preload("Ratings").First(&restaurant, restaurantID).Error
+		err = db.Preload("MenuItems").Preload("MenuItems.Tags").Preload("Categories").Preload("Ratings").Preload("OpeningHours").Preload("ServicePeriods").First(&restaurant, restaurantID).Error
 		if err != nil {
 			if err == gorm.ErrRecordNotFound {
 				c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found"})
@@ -283,13 +503,17 @@ func GetAvailableTables(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
 		}
 
 		// Validate and parse query parameters using our utility
-		filters, err := utilities.ValidateTableFilters(c)
+		filters, err := utilities.ValidateTableFilters(c, restaurant.Location())
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		// Build dynamic query for available tables
+		// The available filter applies to the requested window rather than the is_available column
+		availableFilter := filters.Available
+		filters.Available = nil
+
+		// Build dynamic query for operational tables that fit the party
 		query := utilities.BuildAvailableTableQuery(db, uint(restaurantID), filters)
 
 		// Execute query to get matching tables
@@ -299,10 +523,39 @@ func GetAvailableTables(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
 			return
 		}
 
+		tableIDs := make([]uint, 0, len(tables))
+		for _, table := range tables {
+			tableIDs = append(tableIDs, table.TableID)
+		}
+		statuses, err := utilities.CheckMultipleTablesAvailability(db, tableIDs, filters.Window)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking table availability"})
+			return
+		}
+		schedule, err := utilities.LoadRestaurantSchedule(db, &restaurant)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching opening hours"})
+			return
+		}
+		utilities.ApplyScheduleToAvailability(statuses, schedule, filters.Window)
+		utilities.LocalizeAvailability(statuses, restaurant.Location())
+		isOpen, closedReason := schedule.IsOpenFor(filters.Window)
+
 		// Convert to API response format optimized for React Native
-		var results []utilities.TableQueryResult
+		results := []utilities.TableQueryResult{}
+		availableCount := 0
 		for _, table := range tables {
-			results = append(results, utilities.ConvertTableToResult(table))
+			status := statuses[table.TableID]
+			if availableFilter != nil && status.IsCurrentlyFree != *availableFilter {
+				continue
+			}
+			if status.IsCurrentlyFree {
+				availableCount++
+			}
+			result := utilities.ConvertTableToResult(table)
+			result.IsReserved = !status.IsCurrentlyFree
+			result.Availability = status
+			results = append(results, result)
 		}
 
 		// Build comprehensive response
@@ -317,10 +570,18 @@ func GetAvailableTables(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
 				"view":        filters.View,
 				"minCapacity": filters.MinCapacity,
 				"maxCapacity": filters.MaxCapacity,
-				"available":   filters.Available,
+				"partySize":   filters.PartySize,
+				"available":   availableFilter,
+			},
+			"window": gin.H{
+				"start":        utilities.Localize(filters.Window.Start, restaurant.Location()),
+				"end":          utilities.Localize(filters.Window.End, restaurant.Location()),
+				"isOpen":       isOpen,
+				"closedReason": closedReason,
 			},
-			"tables": results,
-			"count":  len(results),
+			"tables":         results,
+			"count":          len(results),
+			"availableCount": availableCount,
 		}
 
 		c.JSON(http.StatusOK, response)
@@ -0,0 +1,177 @@
+// This file contains the handlers for a user's login history and signed in devices
+//
+// The handlers here are as follows:
+// - GetLoginHistory
+// - GetSessions
+// - LogoutEverywhere
+// - recordLogin
+
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Reasons a login attempt failed
+const (
+	loginFailureUnknownEmail    = "unknown_email"
+	loginFailureInvalidPassword = "invalid_password"
+	loginFailureThrottled       = "throttled"
+)
+
+// Number of login attempts returned when no limit is given, and the most that can be asked for
+const (
+	defaultLoginHistoryLimit = 20
+	maxLoginHistoryLimit     = 100
+)
+
+// loginAttempt is what recordLogin stores about a login
+type loginAttempt struct {
+	UserID        *uint
+	Email         string
+	Succeeded     bool
+	FailureReason string
+	FamilyID      string
+}
+
+// LoginHistoryResponse describes one login attempt on the user's account
+type LoginHistoryResponse struct {
+	LoginID       uint      `json:"loginId"`
+	Succeeded     bool      `json:"succeeded"`
+	FailureReason *string   `json:"failureReason"`
+	ClientID      *uint     `json:"clientId"`
+	RemoteAddr    *string   `json:"remoteAddr"`
+	UserAgent     *string   `json:"userAgent"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// SessionResponse describes a device that is signed in, i.e. an unrevoked refresh token family
+type SessionResponse struct {
+	SessionID    string    `json:"sessionId"`
+	DeviceName   string    `json:"deviceName"`
+	SignedInAt   time.Time `json:"signedInAt"`
+	LastActiveAt time.Time `json:"lastActiveAt"` // When its refresh token was last exchanged
+	ExpiresAt    time.Time `json:"expiresAt"`
+	Current      bool      `json:"current"`
+}
+
+// optionalString returns nil for an empty string
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// recordLogin stores a login attempt with the client, address and user agent it came from.
+// Failing to record it does not fail the login.
+func recordLogin(db *gorm.DB, c *gin.Context, attempt loginAttempt) {
+	login := models.UserLogin{
+		UserID:        attempt.UserID,
+		Email:         attempt.Email,
+		Succeeded:     attempt.Succeeded,
+		FailureReason: optionalString(attempt.FailureReason),
+		FamilyID:      optionalString(attempt.FamilyID),
+		RemoteAddr:    optionalString(c.ClientIP()),
+		UserAgent:     optionalString(c.Request.UserAgent()),
+	}
+	if client, ok := utilities.GetAuthenticatedClient(c); ok {
+		login.ClientID = &client.ID
+	}
+	if err := db.Omit("User").Create(&login).Error; err != nil {
+		fmt.Println("Error recording login:", err)
+	}
+}
+
+// GetLoginHistory is a handler for listing the most recent login attempts on the caller's account
+func GetLoginHistory(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := utilities.GetAuthenticatedUserID(c)
+		limit := defaultLoginHistoryLimit
+		if value := c.Query("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 1 || parsed > maxLoginHistoryLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxLoginHistoryLimit)})
+				return
+			}
+			limit = parsed
+		}
+
+		var logins []models.UserLogin
+		err := db.Where("user_id = ?", userID).Order("created_at DESC, login_id DESC").Limit(limit).Find(&logins).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching login history"})
+			return
+		}
+
+		response := make([]LoginHistoryResponse, 0, len(logins))
+		for _, login := range logins {
+			response = append(response, LoginHistoryResponse{
+				LoginID:       login.LoginID,
+				Succeeded:     login.Succeeded,
+				FailureReason: login.FailureReason,
+				ClientID:      login.ClientID,
+				RemoteAddr:    login.RemoteAddr,
+				UserAgent:     login.UserAgent,
+				CreatedAt:     login.CreatedAt,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{"logins": response})
+	}
+}
+
+// GetSessions is a handler for listing the devices the caller is signed in on
+func GetSessions(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := utilities.GetAuthenticatedUserID(c)
+
+		var response []SessionResponse
+		err := db.Model(&models.RefreshToken{}).
+			Select("family_id AS session_id, MAX(device_name) AS device_name, MIN(created_at) AS signed_in_at, "+
+				"MAX(created_at) AS last_active_at, MAX(expires_at) AS expires_at").
+			Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+			Group("family_id").
+			Order("last_active_at DESC").
+			Scan(&response).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching sessions"})
+			return
+		}
+
+		current := c.GetString(utilities.ContextSessionIDKey)
+		for i := range response {
+			response[i].Current = response[i].SessionID == current
+		}
+		if response == nil {
+			response = []SessionResponse{}
+		}
+		c.JSON(http.StatusOK, gin.H{"sessions": response})
+	}
+}
+
+// LogoutEverywhere is a handler for signing the caller out on every device. All of their refresh
+// tokens are revoked and access tokens and cookie sessions issued until now stop working.
+func LogoutEverywhere(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := utilities.GetAuthenticatedUserID(c)
+		if err := utilities.RevokeUserTokens(db, userID); err != nil {
+			fmt.Println("Error signing out everywhere:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error signing out everywhere"})
+			return
+		}
+
+		session := sessions.Default(c)
+		session.Clear()
+		session.Save()
+		c.JSON(http.StatusOK, gin.H{"message": "Signed out on every device"})
+	}
+}
@@ -0,0 +1,291 @@
+// This file contains the handlers for managing the staff of a restaurant
+//
+// The handlers here are as follows:
+// - GetRestaurantStaff
+// - AddRestaurantStaff
+// - UpdateRestaurantStaff
+// - RemoveRestaurantStaff
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AddStaffRequest is the request body for giving a user a role in a restaurant. The user is
+// identified by userId or email.
+type AddStaffRequest struct {
+	UserID *uint  `json:"userId"`
+	Email  string `json:"email"`
+	Role   string `json:"role" binding:"required"`
+}
+
+// UpdateStaffRequest is the request body for changing a staff member's role or suspending them
+type UpdateStaffRequest struct {
+	Role     *string `json:"role"`
+	IsActive *bool   `json:"isActive"`
+}
+
+// StaffResponse describes a staff membership
+type StaffResponse struct {
+	StaffID   uint      `json:"staffId"`
+	UserID    uint      `json:"userId"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	IsActive  bool      `json:"isActive"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func newStaffResponse(staff models.Staff) StaffResponse {
+	return StaffResponse{
+		StaffID:   staff.StaffID,
+		UserID:    staff.UserID,
+		Email:     staff.User.Email,
+		Role:      staff.Role,
+		IsActive:  staff.IsActive,
+		CreatedAt: staff.CreatedAt,
+	}
+}
+
+// respondStaffError maps staff management errors to an HTTP response
+func respondStaffError(c *gin.Context, err error, action string) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		c.JSON(reqErr.Status, gin.H{"error": reqErr.Message})
+		return
+	}
+	fmt.Printf("Error %s staff member: %v\n", action, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + " staff member"})
+}
+
+// canGrantStaffRole reports whether a caller holding callerRole may grant or manage role.
+// Only owners manage owners and managers; managers manage the rest of the team.
+func canGrantStaffRole(callerRole, role string) bool {
+	if callerRole == models.StaffRoleOwner {
+		return true
+	}
+	return callerRole == models.StaffRoleManager && role != models.StaffRoleOwner && role != models.StaffRoleManager
+}
+
+// validateStaffRole normalizes a requested role and checks the caller may grant it
+func validateStaffRole(c *gin.Context, role string) (string, error) {
+	role = strings.ToLower(strings.TrimSpace(role))
+	if !utilities.IsValidStaffRole(role) {
+		return "", &requestError{http.StatusBadRequest, "Invalid role. Valid options: " + strings.Join(utilities.ValidStaffRoles, ", ")}
+	}
+	if !canGrantStaffRole(utilities.GetRestaurantRole(c), role) {
+		return "", &requestError{http.StatusForbidden, "Only owners can grant the " + role + " role"}
+	}
+	return role, nil
+}
+
+// loadStaffForCaller locks a membership of the restaurant and checks that the caller may change it
+func loadStaffForCaller(c *gin.Context, tx *gorm.DB, restaurantID uint) (*models.Staff, error) {
+	staffID, err := strconv.ParseUint(c.Param("staffId"), 10, 32)
+	if err != nil {
+		return nil, &requestError{http.StatusBadRequest, "Invalid staff ID format"}
+	}
+
+	var staff models.Staff
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("User").
+		Where("staff_id = ? AND restaurant_id = ?", staffID, restaurantID).
+		First(&staff).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &requestError{http.StatusNotFound, "Staff member not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	userID, _ := utilities.GetAuthenticatedUserID(c)
+	if staff.UserID == userID {
+		return nil, &requestError{http.StatusForbidden, "You cannot change your own membership"}
+	}
+	if !canGrantStaffRole(utilities.GetRestaurantRole(c), staff.Role) {
+		return nil, &requestError{http.StatusForbidden, "Only owners can manage the " + staff.Role + " role"}
+	}
+	return &staff, nil
+}
+
+// GetRestaurantStaff is a handler for listing the staff of a restaurant
+func GetRestaurantStaff(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+
+		var staff []models.Staff
+		if err := db.Preload("User").Where("restaurant_id = ?", restaurantID).Order("staff_id").Find(&staff).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching staff"})
+			return
+		}
+
+		response := make([]StaffResponse, 0, len(staff))
+		for _, member := range staff {
+			response = append(response, newStaffResponse(member))
+		}
+		c.JSON(http.StatusOK, gin.H{"staff": response})
+	}
+}
+
+// AddRestaurantStaff is a handler for giving a user a role in a restaurant. Customers given a
+// role become staff users so they can reach the staff routes.
+func AddRestaurantStaff(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+
+		var req AddStaffRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if req.UserID == nil && strings.TrimSpace(req.Email) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "userId or email is required"})
+			return
+		}
+
+		var staff models.Staff
+		err := db.Transaction(func(tx *gorm.DB) error {
+			role, err := validateStaffRole(c, req.Role)
+			if err != nil {
+				return err
+			}
+
+			var user models.User
+			query := tx.Clauses(clause.Locking{Strength: "UPDATE"})
+			if req.UserID != nil {
+				query = query.Where("user_id = ?", *req.UserID)
+			} else {
+				query = query.Where("email = ?", strings.TrimSpace(req.Email))
+			}
+			if err := query.First(&user).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+				return &requestError{http.StatusNotFound, "User not found"}
+			} else if err != nil {
+				return err
+			}
+
+			var existing int64
+			if err := tx.Model(&models.Staff{}).Where("user_id = ? AND restaurant_id = ?", user.UserID, restaurantID).Count(&existing).Error; err != nil {
+				return err
+			}
+			if existing > 0 {
+				return &requestError{http.StatusConflict, "User is already a member of this restaurant's staff"}
+			}
+
+			if user.AuthType == string(utilities.Customer) {
+				if err := tx.Model(&user).Update("auth_type", string(utilities.Staff)).Error; err != nil {
+					return err
+				}
+			}
+
+			staff = models.Staff{UserID: user.UserID, RestaurantID: restaurantID, Role: role, IsActive: true}
+			if err := tx.Omit("User").Create(&staff).Error; err != nil {
+				return err
+			}
+			staff.User = user
+			return nil
+		})
+		if err != nil {
+			respondStaffError(c, err, "adding")
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"staff": newStaffResponse(staff)})
+	}
+}
+
+// UpdateRestaurantStaff is a handler for changing a staff member's role or suspending them
+func UpdateRestaurantStaff(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+
+		var req UpdateStaffRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var staff *models.Staff
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			if staff, err = loadStaffForCaller(c, tx, restaurantID); err != nil {
+				return err
+			}
+
+			updates := map[string]interface{}{}
+			if req.Role != nil {
+				role, err := validateStaffRole(c, *req.Role)
+				if err != nil {
+					return err
+				}
+				updates["role"] = role
+				staff.Role = role
+			}
+			if req.IsActive != nil {
+				updates["is_active"] = *req.IsActive
+				staff.IsActive = *req.IsActive
+			}
+			if len(updates) == 0 {
+				return nil
+			}
+			return tx.Model(staff).Updates(updates).Error
+		})
+		if err != nil {
+			respondStaffError(c, err, "updating")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"staff": newStaffResponse(*staff)})
+	}
+}
+
+// RemoveRestaurantStaff is a handler for removing a user from a restaurant's staff. The restaurant's
+// owner of record keeps their membership until ownership is transferred.
+func RemoveRestaurantStaff(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, ok := parseRestaurantID(c)
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			staff, err := loadStaffForCaller(c, tx, restaurantID)
+			if err != nil {
+				return err
+			}
+
+			var restaurant models.Restaurant
+			if err := tx.Select("restaurant_id", "owner_id").First(&restaurant, restaurantID).Error; err != nil {
+				return err
+			}
+			if restaurant.OwnerID == staff.UserID {
+				return &requestError{http.StatusConflict, "The restaurant's owner cannot be removed; transfer ownership first"}
+			}
+			return tx.Delete(staff).Error
+		})
+		if err != nil {
+			respondStaffError(c, err, "removing")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Staff member removed successfully"})
+	}
+}
@@ -0,0 +1,307 @@
+// This file contains the handlers for TOTP two-factor authentication
+//
+// The handlers here are as follows:
+// - VerifyLoginTwoFactor
+// - SetUpLoginTwoFactor
+// - SetUpTwoFactor
+// - EnableTwoFactor
+// - DisableTwoFactor
+// - RegenerateRecoveryCodes
+// - requireEnabledTwoFactor
+// - loadChallengedUser
+// - respondTwoFactorError
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Reason recorded for a login that failed at the second step
+const loginFailureInvalidTwoFactorCode = "invalid_two_factor_code"
+
+// LoginChallengeRequest is the request body for the second step of a two-factor login
+type LoginChallengeRequest struct {
+	ChallengeToken string `json:"challengeToken" form:"challengeToken" binding:"required"`
+	Code           string `json:"code" form:"code"` // A TOTP code or a recovery code
+	UserAgent      string `json:"userAgent" form:"userAgent"`
+}
+
+// TwoFactorCodeRequest is the request body for confirming a change with a TOTP or recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" form:"code" binding:"required"`
+}
+
+// respondTwoFactorError maps two-factor errors to an HTTP response
+func respondTwoFactorError(c *gin.Context, err error, action string) {
+	var reqErr *requestError
+	switch {
+	case errors.Is(err, utilities.ErrUserTokenInvalid), errors.Is(err, utilities.ErrUserTokenExpired):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login challenge is invalid or has expired; please log in again"})
+	case errors.Is(err, utilities.ErrTwoFactorCodeInvalid):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid two-factor code"})
+	case errors.Is(err, utilities.ErrTwoFactorNotSetUp):
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not set up"})
+	case errors.Is(err, utilities.ErrTwoFactorAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+	case errors.As(err, &reqErr):
+		c.JSON(reqErr.Status, gin.H{"error": reqErr.Message})
+	default:
+		fmt.Printf("Error %s: %v\n", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action})
+	}
+}
+
+// requireEnabledTwoFactor fails with ErrTwoFactorNotSetUp unless the user has completed enrollment
+func requireEnabledTwoFactor(db *gorm.DB, userID uint) error {
+	twoFactor, err := utilities.GetTwoFactor(db, userID)
+	if err != nil {
+		return err
+	}
+	if twoFactor == nil || twoFactor.EnabledAt == nil {
+		return utilities.ErrTwoFactorNotSetUp
+	}
+	return nil
+}
+
+// loadChallengedUser returns the user a login challenge was issued to without redeeming it
+func loadChallengedUser(db *gorm.DB, challengeToken string, now time.Time) (models.User, error) {
+	var user models.User
+	challenge, err := utilities.LookupUserToken(db, challengeToken, models.UserTokenPurposeLoginChallenge, now)
+	if err != nil {
+		return user, err
+	}
+	if err := db.Preload("Entity").First(&user, challenge.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, utilities.ErrUserTokenInvalid
+		}
+		return user, err
+	}
+	if user.AccessRevoked {
+		return user, &requestError{http.StatusForbidden, "Access has been revoked for this user"}
+	}
+	return user, nil
+}
+
+// VerifyLoginTwoFactor is a handler for the second step of a two-factor login. It redeems the challenge
+// from Login with a TOTP or recovery code and responds like Login. A user whose role requires two-factor
+// authentication and who set it up during login also receives their recovery codes.
+func VerifyLoginTwoFactor(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LoginChallengeRequest
+		if err := c.ShouldBind(&req); err != nil || req.Code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "challengeToken and code are required"})
+			return
+		}
+		now := time.Now()
+		user, err := loadChallengedUser(db, req.ChallengeToken, now)
+		if err != nil {
+			respondTwoFactorError(c, err, "verifying two-factor code")
+			return
+		}
+
+		// Code guesses count against the same limits as password guesses
+		address := c.ClientIP()
+		wait, err := utilities.LoginLimiter.Check(user.Email, address, now)
+		if err != nil {
+			respondTwoFactorError(c, err, "verifying two-factor code")
+			return
+		}
+		if wait > 0 {
+			recordLogin(db, c, loginAttempt{UserID: &user.UserID, Email: user.Email, FailureReason: loginFailureThrottled})
+			respondLoginThrottled(c, wait)
+			return
+		}
+
+		var recoveryCodes []string
+		err = db.Transaction(func(tx *gorm.DB) error {
+			activated, err := utilities.VerifyTwoFactorCode(tx, user.UserID, req.Code, now)
+			if err != nil {
+				return err
+			}
+			if _, err := utilities.RedeemUserToken(tx, req.ChallengeToken, models.UserTokenPurposeLoginChallenge, now); err != nil {
+				return err
+			}
+			if activated {
+				recoveryCodes, err = utilities.NewRecoveryCodes(tx, user.UserID)
+			}
+			return err
+		})
+		if errors.Is(err, utilities.ErrTwoFactorCodeInvalid) {
+			recordLogin(db, c, loginAttempt{UserID: &user.UserID, Email: user.Email, FailureReason: loginFailureInvalidTwoFactorCode})
+			if err := utilities.LoginLimiter.Failure(user.Email, address, now); err != nil {
+				fmt.Println("Error recording failed login:", err)
+			}
+		}
+		if err != nil {
+			respondTwoFactorError(c, err, "verifying two-factor code")
+			return
+		}
+
+		if err := utilities.LoginLimiter.Success(user.Email); err != nil {
+			fmt.Println("Error clearing failed logins:", err)
+		}
+		userAgent := req.UserAgent
+		if client, ok := utilities.GetAuthenticatedClient(c); ok {
+			userAgent = client.ClientType
+		}
+		var extra gin.H
+		if recoveryCodes != nil {
+			extra = gin.H{"recoveryCodes": recoveryCodes}
+		}
+		completeLogin(db, c, user, userAgent, extra)
+	}
+}
+
+// SetUpLoginTwoFactor is a handler for starting enrollment during login, for users whose role requires
+// two-factor authentication but who have not set it up. The challenge is not redeemed; the user finishes
+// logging in with a code from their authenticator app.
+func SetUpLoginTwoFactor(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LoginChallengeRequest
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "challengeToken is required"})
+			return
+		}
+		user, err := loadChallengedUser(db, req.ChallengeToken, time.Now())
+		if err != nil {
+			respondTwoFactorError(c, err, "setting up two-factor authentication")
+			return
+		}
+
+		secret, err := utilities.StartTwoFactorEnrollment(db, user.UserID)
+		if err != nil {
+			respondTwoFactorError(c, err, "setting up two-factor authentication")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauthUri": utilities.TOTPURI(user.Email, secret)})
+	}
+}
+
+// SetUpTwoFactor is a handler for starting enrollment. It returns a new secret to add to an authenticator
+// app; two-factor authentication is enabled once EnableTwoFactor receives a code from it.
+func SetUpTwoFactor(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := utilities.GetAuthenticatedUserID(c)
+		var user models.User
+		if err := db.Select("user_id", "email").First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+			return
+		}
+
+		secret, err := utilities.StartTwoFactorEnrollment(db, userID)
+		if err != nil {
+			respondTwoFactorError(c, err, "setting up two-factor authentication")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauthUri": utilities.TOTPURI(user.Email, secret)})
+	}
+}
+
+// EnableTwoFactor is a handler for completing enrollment with a code from the authenticator app. The
+// recovery codes are only returned here.
+func EnableTwoFactor(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TwoFactorCodeRequest
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+			return
+		}
+		userID, _ := utilities.GetAuthenticatedUserID(c)
+
+		var recoveryCodes []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			twoFactor, err := utilities.GetTwoFactor(tx, userID)
+			if err != nil {
+				return err
+			}
+			if twoFactor == nil {
+				return utilities.ErrTwoFactorNotSetUp
+			}
+			if twoFactor.EnabledAt != nil {
+				return utilities.ErrTwoFactorAlreadyEnabled
+			}
+			if _, err := utilities.VerifyTwoFactorCode(tx, userID, req.Code, time.Now()); err != nil {
+				return err
+			}
+			recoveryCodes, err = utilities.NewRecoveryCodes(tx, userID)
+			return err
+		})
+		if err != nil {
+			respondTwoFactorError(c, err, "enabling two-factor authentication")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recoveryCodes": recoveryCodes})
+	}
+}
+
+// DisableTwoFactor is a handler for turning off two-factor authentication, confirmed with a code. Users
+// whose role requires it cannot turn it off.
+func DisableTwoFactor(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TwoFactorCodeRequest
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+			return
+		}
+		userID, _ := utilities.GetAuthenticatedUserID(c)
+		authType := utilities.GetAuthenticatedAuthType(c)
+		if utilities.TwoFactorRequired(authType) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your account"})
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := requireEnabledTwoFactor(tx, userID); err != nil {
+				return err
+			}
+			if _, err := utilities.VerifyTwoFactorCode(tx, userID, req.Code, time.Now()); err != nil {
+				return err
+			}
+			return utilities.DisableTwoFactor(tx, userID)
+		})
+		if err != nil {
+			respondTwoFactorError(c, err, "disabling two-factor authentication")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+	}
+}
+
+// RegenerateRecoveryCodes is a handler for replacing the caller's recovery codes, confirmed with a code
+func RegenerateRecoveryCodes(db *gorm.DB, router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req TwoFactorCodeRequest
+		if err := c.ShouldBind(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+			return
+		}
+		userID, _ := utilities.GetAuthenticatedUserID(c)
+
+		var recoveryCodes []string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := requireEnabledTwoFactor(tx, userID); err != nil {
+				return err
+			}
+			if _, err := utilities.VerifyTwoFactorCode(tx, userID, req.Code, time.Now()); err != nil {
+				return err
+			}
+			var err error
+			recoveryCodes, err = utilities.NewRecoveryCodes(tx, userID)
+			return err
+		})
+		if err != nil {
+			respondTwoFactorError(c, err, "regenerating recovery codes")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"recoveryCodes": recoveryCodes})
+	}
+}
@@ -2,9 +2,10 @@
 //
 // The handlers here are as follows:
 // - CreateUser
-// - GetUser
+// - ListUsers
 // - UpdateUserLocation
 // - UpdateUserAccountInformation
+// - resolveTargetUser
 
 package handlers
 
@@ -13,11 +14,14 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
 	// "waitress-backend/internal/handlers"
 	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
@@ -48,6 +52,15 @@ func CreateUser(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Accounts registered here are customers; staff roles are granted by restaurants
+		if req.UserType == "" {
+			req.UserType = string(utilities.Customer)
+		}
+		if req.UserType != string(utilities.Customer) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only customer accounts can be registered"})
+			return
+		}
+
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error hashing password"})
@@ -70,7 +83,13 @@ func CreateUser(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		token, err := createToken(newUser)
+		// The account can sign in right away, but ordering and paying wait until the email address is
+		// confirmed through the link mailed here
+		if err := sendEmailVerification(c, db, newUser); err != nil {
+			fmt.Println("Error sending verification email:", err)
+		}
+
+		token, refreshToken, err := issueTokenPair(db, newUser, "", c.GetHeader("User-Agent"))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Error creating token"})
 			return
@@ -81,30 +100,109 @@ func CreateUser(db *gorm.DB) gin.HandlerFunc {
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"message": "User created successfully",
-			"user":    newUser,
-			"token":   token,
+			"message":      "User created successfully",
+			"user":         newUser,
+			"token":        token,
+			"refreshToken": refreshToken,
 		})
 	}
 }
 
-// GetUser is a handler for getting all users in the database
-func GetUser(db *gorm.DB) gin.HandlerFunc {
+// UserSummaryResponse describes a user in the admin user listing
+type UserSummaryResponse struct {
+	UserID        uint      `json:"userId"`
+	Email         string    `json:"email"`
+	FirstName     string    `json:"firstName"`
+	LastName      string    `json:"lastName"`
+	AuthType      string    `json:"authType"`
+	EmailVerified bool      `json:"emailVerified"`
+	AccessRevoked bool      `json:"accessRevoked"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// ListUsers is a handler for paging through users, optionally searching by email or name
+func ListUsers(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		page, err := utilities.ParsePage(c.Query("page"), c.Query("pageSize"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		query := db.Model(&models.User{}).Joins("Entity")
+		if search := strings.TrimSpace(c.Query("search")); search != "" {
+			pattern := "%" + utilities.EscapeLike(search) + "%"
+			query = query.Where("(users.email LIKE ? OR `Entity`.first_name LIKE ? OR `Entity`.last_name LIKE ? OR "+
+				"CONCAT(`Entity`.first_name, ' ', `Entity`.last_name) LIKE ?)", pattern, pattern, pattern, pattern)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting users"})
+			return
+		}
 		var users []models.User
-		results := db.Find(&users)
-		if results.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"Message": results.Error.Error()})
+		if err := query.Order("users.user_id").Offset(page.Offset()).Limit(page.Size).Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching users"})
+			return
+		}
+
+		response := make([]UserSummaryResponse, 0, len(users))
+		for _, user := range users {
+			response = append(response, UserSummaryResponse{
+				UserID:        user.UserID,
+				Email:         user.Email,
+				FirstName:     user.Entity.FirstName,
+				LastName:      user.Entity.LastName,
+				AuthType:      user.AuthType,
+				EmailVerified: user.EmailVerifiedAt != nil,
+				AccessRevoked: user.AccessRevoked,
+				CreatedAt:     user.CreatedAt,
+			})
 		}
-		c.IndentedJSON(http.StatusOK, users)
+		c.JSON(http.StatusOK, gin.H{"users": response, "page": page.Number, "pageSize": page.Size, "total": total})
 	}
 }
 
+// resolveTargetUser returns the user a request acts on: the caller, or the requested user when the
+// caller may change their account. It responds itself when the caller may not.
+func resolveTargetUser(c *gin.Context, db *gorm.DB, requested uint) (uint, bool) {
+	callerID, _ := utilities.GetAuthenticatedUserID(c)
+	if requested == 0 || requested == callerID {
+		return callerID, true
+	}
+	var target models.User
+	if err := db.Select("user_id", "auth_type").Where("user_id = ?", requested).First(&target).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error"})
+		}
+		return 0, false
+	}
+	if !utilities.CanActOnUser(c, requested, target.AuthType) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only change your own account"})
+		return 0, false
+	}
+	return requested, true
+}
+
 // UpdateUserLocation is a handler for updating a user's location based on their user latitude and longitude
 func UpdateUserLocation(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var foundUser models.User
-		userId := c.PostForm("userId")
+		var requested uint64
+		if value := c.PostForm("userId"); value != "" {
+			var err error
+			if requested, err = strconv.ParseUint(value, 10, 32); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+				return
+			}
+		}
+		userId, ok := resolveTargetUser(c, db, uint(requested))
+		if !ok {
+			return
+		}
 		address := c.PostForm("address")
 		latitudeStr := c.PostForm("latitude")
 		longitudeStr := c.PostForm("longitude")
@@ -138,7 +236,7 @@ func UpdateUserLocation(db *gorm.DB) gin.HandlerFunc {
 
 // UpdateAccountInfoRequest represents the JSON structure of the update account information request
 type UpdateAccountInfoRequest struct {
-	UserID    int    `json:"userId" binding:"required"`
+	UserID    uint   `json:"userId"` // Defaults to the caller; changing another user needs user:manage and cannot change their email
 	FirstName string `json:"firstName" binding:"required"`
 	LastName  string `json:"lastName" binding:"required"`
 	Email     string `json:"email" binding:"required"`
@@ -164,10 +262,16 @@ func UpdateUserAccountInformation(db *gorm.DB) gin.HandlerFunc {
 		// Log the struct for debugging
 		fmt.Printf("Parsed request struct: %+v\n", request)
 
+		callerID, _ := utilities.GetAuthenticatedUserID(c)
+		userID, ok := resolveTargetUser(c, db, request.UserID)
+		if !ok {
+			return
+		}
+
 		var foundUser models.User
 		address := request.Street + ", " + request.City + ", " + request.State + " " + request.Zip
 
-		result := db.Preload("Entity").Where("user_id = ?", request.UserID).First(&foundUser)
+		result := db.Preload("Entity").Where("user_id = ?", userID).First(&foundUser)
 		if result.Error != nil {
 			if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 				c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
@@ -177,12 +281,36 @@ func UpdateUserAccountInformation(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		emailChanged := !strings.EqualFold(request.Email, foundUser.Email)
+		// Changing the email moves the account's sign-in and recovery, so only its owner may do it
+		if emailChanged && userID != callerID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You can only change the email address of your own account"})
+			return
+		}
+		if emailChanged {
+			var taken int64
+			if err := db.Model(&models.User{}).Where("email = ? AND user_id <> ?", request.Email, foundUser.UserID).Count(&taken).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Database error"})
+				return
+			}
+			if taken > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "Email address is already in use"})
+				return
+			}
+		}
+
 		// Update user information
 		updatedUser, err := foundUser.UpdateAccountInformation(db, request.FirstName, request.LastName, request.Email, address, request.City, request.State, request.Zip, request.Phone)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		// A new address has to be verified again
+		if emailChanged {
+			if err := sendEmailVerification(c, db, *updatedUser); err != nil {
+				fmt.Println("Error sending verification email:", err)
+			}
+		}
 		token := sessions.Default(c).Get("apiToken")
 		c.JSON(http.StatusOK, gin.H{"message": "User account information updated successfully", "user": updatedUser, "token": token})
 	}
@@ -0,0 +1,197 @@
+// The mail package sends the emails the server addresses to users.
+//
+// The senders here are as follows:
+// - Sender
+// - SMTPSender
+// - MemorySender
+// - FileSender
+// - NewSenderFromEnv
+// - SetDefault
+// - Default
+
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// format renders a message with its headers
+func format(from string, msg Message, now time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return b.Bytes()
+}
+
+// validate rejects messages whose headers could be used to inject others
+func validate(msg Message) error {
+	if msg.To == "" {
+		return fmt.Errorf("mail: message has no recipient")
+	}
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return fmt.Errorf("mail: header contains a line break")
+	}
+	return nil
+}
+
+// SMTPSender delivers messages through an SMTP server, authenticating when a username is set
+type SMTPSender struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers a message. The context is not consulted once the connection is open.
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	return smtp.SendMail(addr, auth, s.From, []string{msg.To}, format(s.From, msg, time.Now()))
+}
+
+// MemorySender keeps messages in memory instead of delivering them, for tests and local development
+type MemorySender struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemorySender creates an empty in-memory sink
+func NewMemorySender() *MemorySender {
+	return &MemorySender{}
+}
+
+// Send records a message
+func (s *MemorySender) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first
+func (s *MemorySender) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// FileSender writes each message to a .eml file in a directory instead of delivering it
+type FileSender struct {
+	Dir  string
+	From string
+}
+
+// Send writes a message to a new file named after the time and recipient
+func (s *FileSender) Send(ctx context.Context, msg Message) error {
+	if err := validate(msg); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), sanitizeFileName(msg.To))
+	return os.WriteFile(filepath.Join(s.Dir, name), format(s.From, msg, now), 0o644)
+}
+
+func sanitizeFileName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// Default sender address when MAIL_FROM is not set
+const defaultFrom = "Waitress <no-reply@waitress.local>"
+
+// NewSenderFromEnv chooses a sender from the environment: SMTP when MAIL_SMTP_HOST is set, a directory
+// of .eml files when MAIL_FILE_DIR is set, and otherwise an in-memory sink.
+func NewSenderFromEnv() (Sender, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = defaultFrom
+	}
+	if host := os.Getenv("MAIL_SMTP_HOST"); host != "" {
+		port := 587
+		if value := os.Getenv("MAIL_SMTP_PORT"); value != "" {
+			var err error
+			if port, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("mail: invalid MAIL_SMTP_PORT %q", value)
+			}
+		}
+		return &SMTPSender{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("MAIL_SMTP_USERNAME"),
+			Password: os.Getenv("MAIL_SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+	}
+	if dir := os.Getenv("MAIL_FILE_DIR"); dir != "" {
+		return &FileSender{Dir: dir, From: from}, nil
+	}
+	log.Println("mail: neither MAIL_SMTP_HOST nor MAIL_FILE_DIR is set; emails are kept in memory and not delivered")
+	return NewMemorySender(), nil
+}
+
+var (
+	defaultMu     sync.RWMutex
+	defaultSender Sender = NewMemorySender()
+)
+
+// SetDefault replaces the sender used by the handlers
+func SetDefault(sender Sender) {
+	defaultMu.Lock()
+	defaultSender = sender
+	defaultMu.Unlock()
+}
+
+// Default returns the sender used by the handlers
+func Default() Sender {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultSender
+}
@@ -0,0 +1,18 @@
+// This file contains the models for verifying requests signed by API clients
+//
+// The models here are as follows:
+// - ClientNonce
+
+package models
+
+import (
+	"time"
+)
+
+// ClientNonce records a nonce an API client has signed a request with, so that the request cannot be
+// replayed. Rows are only needed until the request's timestamp falls outside the signature window.
+type ClientNonce struct {
+	NonceHash string    `gorm:"primaryKey;size:64"` // Hash of the client's public ID and the nonce
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+}
@@ -26,6 +26,7 @@ type APIClient struct {
 	PreviousSecret     *string    // Pointer to allow nil (nullable)
 	ClientType         string     `gorm:"size:32"`
 	Name               string     `gorm:"size:32"`
+	LastUsedAt         *time.Time // Last time the client made a signed request
 }
 
 // KeyPair represents a pair of corresponding public/secret tokens.
@@ -53,6 +54,20 @@ func (client *APIClient) BeforeCreate(tx *gorm.DB) (err error) {
 	return nil
 }
 
+// RotateSecret replaces the client's secret with a new random one, keeping the current secret as
+// PreviousSecret so requests signed with it are accepted while the client switches over.
+func (client *APIClient) RotateSecret(now time.Time) (string, error) {
+	secret, err := generateRandomString(32)
+	if err != nil {
+		return "", err
+	}
+	previous := client.Secret
+	client.PreviousSecret = &previous
+	client.Secret = secret
+	client.LastSecretRotation = &now
+	return secret, nil
+}
+
 // generateRandomString creates a random string of a specified length.
 func generateRandomString(length int) (string, error) {
 	// Adjust the length value as appropriate for the base64 encoding
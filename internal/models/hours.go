@@ -0,0 +1,78 @@
+// This file contains models describing when a restaurant is open
+//
+// The models here are as follows:
+// - OpeningHours
+// - ServicePeriod
+// - HoursException
+
+package models
+
+import (
+	"time"
+)
+
+// OpeningHours is one open interval in a restaurant's regular week.
+// A day may have several intervals, e.g. a lunch and a dinner opening.
+type OpeningHours struct {
+	OpeningHoursID uint      `gorm:"primaryKey;autoIncrement"`
+	RestaurantID   uint      `gorm:"not null;index"`
+	Weekday        uint      `gorm:"not null"`        // 0 = Sunday ... 6 = Saturday, matching time.Weekday
+	OpensAt        string    `gorm:"size:5;not null"` // "HH:MM" wall clock time
+	ClosesAt       string    `gorm:"size:5;not null"` // "HH:MM", at or before OpensAt when open past midnight
+	CreatedAt      time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt      time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+
+	Restaurant Restaurant `gorm:"foreignKey:RestaurantID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (OpeningHours) TableName() string {
+	return "opening_hours"
+}
+
+// ServicePeriod is a named service such as brunch, lunch or dinner on a given weekday.
+// When a day has service periods, reservations must start inside one that accepts them.
+type ServicePeriod struct {
+	ServicePeriodID     uint      `gorm:"primaryKey;autoIncrement"`
+	RestaurantID        uint      `gorm:"not null;index"`
+	Name                string    `gorm:"size:50;not null"` // brunch, lunch, dinner, ...
+	Weekday             uint      `gorm:"not null"`         // 0 = Sunday ... 6 = Saturday
+	StartsAt            string    `gorm:"size:5;not null"`  // "HH:MM"
+	EndsAt              string    `gorm:"size:5;not null"`  // "HH:MM", at or before StartsAt when running past midnight
+	AcceptsReservations bool      `gorm:"not null"`
+	CreatedAt           time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt           time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+
+	Restaurant Restaurant `gorm:"foreignKey:RestaurantID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (ServicePeriod) TableName() string {
+	return "service_periods"
+}
+
+// HoursException overrides the regular week on a single date, either closing the restaurant
+// (holidays, private events) or replacing its hours for that day.
+type HoursException struct {
+	HoursExceptionID uint      `gorm:"primaryKey;autoIncrement"`
+	RestaurantID     uint      `gorm:"not null;index"`
+	Date             string    `gorm:"size:10;not null;index"` // "YYYY-MM-DD"
+	Kind             string    `gorm:"size:20;not null"`       // holiday, exception
+	IsClosed         bool      `gorm:"not null"`
+	OpensAt          *string   `gorm:"size:5"` // Replacement hours when not closed
+	ClosesAt         *string   `gorm:"size:5"`
+	RecursAnnually   bool      `gorm:"default:false"` // Holidays such as Dec 25 that repeat every year
+	Reason           string    `gorm:"size:255"`
+	CreatedAt        time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt        time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+
+	Restaurant Restaurant `gorm:"foreignKey:RestaurantID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+func (HoursException) TableName() string {
+	return "hours_exceptions"
+}
+
+// Kinds of hours exceptions
+const (
+	HoursExceptionHoliday   = "holiday"
+	HoursExceptionException = "exception"
+)
@@ -0,0 +1,23 @@
+// This file contains the models for replaying retried requests
+//
+// The models here are as follows:
+// - IdempotencyKey
+
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey records the first response to a request sent with an Idempotency-Key header, so that
+// retries of it are answered without running the request again. StatusCode is zero while the first
+// request is still being handled. Rows are only needed until they expire.
+type IdempotencyKey struct {
+	KeyHash     string    `gorm:"primaryKey;size:64"` // Hash of the header, user and route
+	Fingerprint string    `gorm:"size:64;not null"`   // Hash of the request the key was first used with
+	StatusCode  int       `gorm:"not null;default:0"`
+	ContentType string    `gorm:"size:255"`
+	Body        []byte    `gorm:"type:mediumblob"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+	CreatedAt   time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+}
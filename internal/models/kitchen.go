@@ -0,0 +1,35 @@
+// This file contains models related to the kitchen display
+//
+// The models here are as follows:
+// - KitchenEvent
+
+package models
+
+import (
+	"time"
+)
+
+// Kinds of kitchen events
+const (
+	KitchenEventOrderCreated       = "order.created"
+	KitchenEventOrderStatusChanged = "order.status_changed"
+	KitchenEventItemStatusChanged  = "item.status_changed"
+	KitchenEventMenuItem86d        = "menu_item.86d"
+	KitchenEventMenuItemRestored   = "menu_item.restored"
+	KitchenEventResync             = "resync" // Events were skipped; the display reloads the restaurant's orders
+)
+
+// KitchenEvent is one message pushed to a restaurant's kitchen display. Events are stored so a
+// display that reconnects can replay everything after the last event ID it received, and so that
+// displays connected to any instance receive them.
+type KitchenEvent struct {
+	KitchenEventID uint      `gorm:"primaryKey;autoIncrement:true"`
+	RestaurantID   uint      `gorm:"not null;index"`
+	Type           string    `gorm:"size:50;not null"`
+	OrderID        *uint     `gorm:"index"`
+	MenuID         *uint     // Set for menu item events
+	Payload        string    `gorm:"type:text;not null"` // JSON snapshot sent as the event data
+	CreatedAt      time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+
+	Restaurant Restaurant `gorm:"foreignKey:RestaurantID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
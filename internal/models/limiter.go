@@ -0,0 +1,20 @@
+// This file contains the models for throttling failed logins
+//
+// The models here are as follows:
+// - LoginLimit
+
+package models
+
+import (
+	"time"
+)
+
+// LoginLimit counts the failed logins against an account or from an address, so that every server
+// instance throttles them alike. Rows are only needed until they expire.
+type LoginLimit struct {
+	KeyHash      string     `gorm:"primaryKey;size:64"` // Hash of the account or address key
+	Failures     int        `gorm:"not null;default:0"`
+	LastFailure  *time.Time // Nil until the first failure
+	BlockedUntil *time.Time // Nil when attempts are allowed
+	ExpiresAt    time.Time  `gorm:"not null;index"`
+}
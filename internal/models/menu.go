@@ -0,0 +1,101 @@
+// This file contains models related to a restaurant's menu
+//
+// The models here are as follows:
+// - MenuSection
+// - MenuItem
+// - MenuItemTag
+// - ModifierGroup
+// - Modifier
+
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MenuSection groups menu items under a heading such as "Starters" or "Desserts"
+type MenuSection struct {
+	MenuSectionID uint      `gorm:"primaryKey;autoIncrement:true"`
+	RestaurantID  uint      `gorm:"not null;index"`
+	Name          string    `gorm:"size:100;not null"`
+	Description   *string   `gorm:"size:255"`
+	SortOrder     int       `gorm:"not null;default:0"`
+	CreatedAt     time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt     time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+
+	Items      []MenuItem `gorm:"foreignKey:MenuSectionID"`
+	Restaurant Restaurant `gorm:"foreignKey:RestaurantID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// MenuItem represents a menu item record in the database.
+type MenuItem struct {
+	MenuID         uint            `gorm:"primaryKey;autoIncrement:true"`
+	RestaurantID   uint            `gorm:"not null"`
+	MenuSectionID  *uint           `gorm:"index"` // Nil for items not placed in a section
+	NameOfItem     *string         // Pointer to allow nil (nullable)
+	Price          *Money          `gorm:"embedded;embeddedPrefix:price_"` // Nil until the item is priced
+	IsAvailable    bool            `gorm:"not null"`                       // False while the item is 86'd
+	Category       *string         // Pointer to allow nil (nullable)
+	ImageURL       *string         // Pointer to allow nil (nullable)
+	Description    *string         // Pointer to allow nil (nullable)
+	SortOrder      int             `gorm:"not null;default:0"` // Position within its section
+	ModifierGroups []ModifierGroup `gorm:"many2many:menu_item_modifier_groups;joinForeignKey:MenuID;joinReferences:ModifierGroupID"`
+	Tags           []MenuItemTag   `gorm:"foreignKey:MenuID"` // Allergens and dietary labels
+	Restaurant     Restaurant      `gorm:"foreignKey:RestaurantID"`
+}
+
+func (m *MenuItem) GetMenuItem(db *gorm.DB, menuId string) (*MenuItem, error) {
+	var menuItem MenuItem
+	if err := db.Where("menu_id = ?", menuId).First(&menuItem).Error; err != nil {
+		return nil, err
+	}
+	return &menuItem, nil
+}
+
+// Kinds of menu item tags
+const (
+	MenuItemTagAllergen = "allergen" // The item contains the allergen, e.g. peanuts
+	MenuItemTagDietary  = "dietary"  // The item suits the diet, e.g. vegan
+)
+
+// MenuItemTag is an allergen the item contains or a dietary label it meets
+type MenuItemTag struct {
+	MenuItemTagID uint   `gorm:"primaryKey;autoIncrement:true" json:"-"`
+	MenuID        uint   `gorm:"not null;uniqueIndex:idx_menu_item_tag" json:"-"`
+	Kind          string `gorm:"size:20;not null;uniqueIndex:idx_menu_item_tag;index:idx_menu_item_tag_lookup"`
+	Tag           string `gorm:"size:50;not null;uniqueIndex:idx_menu_item_tag;index:idx_menu_item_tag_lookup"`
+
+	MenuItem MenuItem `gorm:"foreignKey:MenuID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// ModifierGroup is a set of options a guest picks from when ordering an item, such as
+// "Choose a side" or "Extras". A group can be shared by several items of the same restaurant.
+type ModifierGroup struct {
+	ModifierGroupID uint      `gorm:"primaryKey;autoIncrement:true"`
+	RestaurantID    uint      `gorm:"not null;index"`
+	Name            string    `gorm:"size:100;not null"`
+	MinSelections   uint      `gorm:"not null;default:0"` // 1 or more makes the group required
+	MaxSelections   uint      `gorm:"not null"`           // 0 allows any number of selections
+	SortOrder       int       `gorm:"not null;default:0"`
+	CreatedAt       time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt       time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+
+	Modifiers  []Modifier `gorm:"foreignKey:ModifierGroupID"`
+	Restaurant Restaurant `gorm:"foreignKey:RestaurantID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// Modifier is one option of a modifier group, e.g. "Extra cheese" for +1.50
+type Modifier struct {
+	ModifierID      uint      `gorm:"primaryKey;autoIncrement:true"`
+	ModifierGroupID uint      `gorm:"not null;index"`
+	Name            string    `gorm:"size:100;not null"`
+	PriceDelta      Money     `gorm:"embedded;embeddedPrefix:price_delta_"` // Added to the item price when chosen
+	IsAvailable     bool      `gorm:"not null"`
+	SortOrder       int       `gorm:"not null;default:0"`
+	CreatedAt       time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt       time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+
+	ModifierGroup ModifierGroup `gorm:"foreignKey:ModifierGroupID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
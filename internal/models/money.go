@@ -0,0 +1,263 @@
+// This file contains the money type used for prices, order totals and payments
+//
+// The models here are as follows:
+// - Money
+// - ZeroMoney
+// - ParseMoney
+// - MustParseMoney
+// - CurrencyExponent
+
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Currency of menus and orders until restaurants can choose their own
+const DefaultCurrency = "USD"
+
+// Digits after the decimal point of supported ISO 4217 currencies
+var currencyExponents = map[string]int{
+	"AUD": 2, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2, "DKK": 2, "EUR": 2, "GBP": 2, "HKD": 2,
+	"INR": 2, "MXN": 2, "NOK": 2, "NZD": 2, "PLN": 2, "SEK": 2, "SGD": 2, "USD": 2, "ZAR": 2,
+	"CLP": 0, "ISK": 0, "JPY": 0, "KRW": 0, "VND": 0,
+	"BHD": 3, "JOD": 3, "KWD": 3, "OMR": 3, "TND": 3,
+}
+
+// Errors returned by money arithmetic
+var (
+	ErrCurrencyMismatch = errors.New("money: amounts are in different currencies")
+	ErrMoneyOverflow    = errors.New("money: amount is too large")
+)
+
+// CurrencyExponent returns how many decimal places the currency has, e.g. 2 for USD and 0 for JPY
+func CurrencyExponent(currency string) (int, bool) {
+	exponent, ok := currencyExponents[currency]
+	return exponent, ok
+}
+
+// Money is an amount in the minor unit of an ISO 4217 currency, e.g. 1250 USD is $12.50.
+//
+// Arithmetic is exact. The only rounding happens in MulRatio, which rounds half away from zero, and in
+// Split, which hands leftover minor units to the first shares so the shares always add up.
+// Embedded in models it is stored as <prefix>minor and <prefix>currency columns.
+type Money struct {
+	Amount   int64  `gorm:"column:minor"`
+	Currency string `gorm:"column:currency;size:3"`
+}
+
+// ZeroMoney returns no money in the currency
+func ZeroMoney(currency string) Money {
+	return Money{Currency: currency}
+}
+
+// ParseMoney parses a decimal amount such as "12.50" or "-3" in the currency. Amounts with more decimal
+// places than the currency has are rejected rather than rounded.
+func ParseMoney(value, currency string) (Money, error) {
+	exponent, ok := CurrencyExponent(currency)
+	if !ok {
+		return Money{}, fmt.Errorf("money: unsupported currency %q", currency)
+	}
+	s := strings.TrimSpace(value)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(s, ".")
+	if whole == "" && fraction == "" {
+		return Money{}, fmt.Errorf("money: %q is not an amount", value)
+	}
+	for _, part := range []string{whole, fraction} {
+		if strings.Trim(part, "0123456789") != "" {
+			return Money{}, fmt.Errorf("money: %q is not an amount", value)
+		}
+	}
+	if trimmed := strings.TrimRight(fraction, "0"); len(trimmed) > exponent {
+		return Money{}, fmt.Errorf("money: %q has more than %d decimal places for %s", value, exponent, currency)
+	}
+	fraction = (fraction + strings.Repeat("0", exponent))[:exponent]
+
+	amount, err := strconv.ParseInt("0"+whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, ErrMoneyOverflow
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+// MustParseMoney is like ParseMoney but panics on error, for amounts written in code
+func MustParseMoney(value, currency string) Money {
+	m, err := ParseMoney(value, currency)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Decimal formats the amount with the currency's decimal places, e.g. "12.50"
+func (m Money) Decimal() string {
+	exponent, ok := CurrencyExponent(m.Currency)
+	if !ok {
+		exponent = 2
+	}
+	digits := strconv.FormatUint(absAmount(m.Amount), 10)
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	sign := ""
+	if m.Amount < 0 {
+		sign = "-"
+	}
+	if exponent == 0 {
+		return sign + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+// String formats the money for logs, e.g. "12.50 USD"
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+func absAmount(amount int64) uint64 {
+	if amount < 0 {
+		return uint64(-(amount + 1)) + 1
+	}
+	return uint64(amount)
+}
+
+// IsZero reports whether the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsNegative reports whether the amount is below zero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Add returns the sum of two amounts in the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	sum := m.Amount + other.Amount
+	if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: sum, Currency: m.Currency}, nil
+}
+
+// Sub returns the difference of two amounts in the same currency
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Amount == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+	return m.Add(Money{Amount: -other.Amount, Currency: other.Currency})
+}
+
+// Times returns the amount multiplied by a whole number, e.g. a unit price by a quantity
+func (m Money) Times(n int64) (Money, error) {
+	return m.MulRatio(n, 1)
+}
+
+// MulRatio returns the amount multiplied by numerator/denominator, rounded half away from zero to a
+// minor unit. A tax of 8.875% is MulRatio(8875, 100000).
+func (m Money) MulRatio(numerator, denominator int64) (Money, error) {
+	if denominator == 0 {
+		return Money{}, fmt.Errorf("money: ratio has a zero denominator")
+	}
+	product := new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(numerator))
+	den := big.NewInt(denominator)
+	if den.Sign() < 0 {
+		product.Neg(product)
+		den.Neg(den)
+	}
+	// Round half away from zero: (2|product| + denominator) / (2 * denominator), truncated
+	magnitude := new(big.Int).Abs(product)
+	magnitude.Lsh(magnitude, 1).Add(magnitude, den).Quo(magnitude, new(big.Int).Lsh(den, 1))
+	if product.Sign() < 0 {
+		magnitude.Neg(magnitude)
+	}
+	if !magnitude.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+	return Money{Amount: magnitude.Int64(), Currency: m.Currency}, nil
+}
+
+// Split divides the amount into n shares that differ by at most one minor unit and add up to the
+// amount. The first shares get the leftover units, e.g. 10.00 split three ways is 3.34, 3.33, 3.33.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("money: cannot split into %d shares", n)
+	}
+	share := m.Amount / int64(n)
+	leftover := m.Amount % int64(n)
+	step := int64(1)
+	if leftover < 0 {
+		leftover, step = -leftover, -1
+	}
+	shares := make([]Money, n)
+	for i := range shares {
+		shares[i] = Money{Amount: share, Currency: m.Currency}
+		if int64(i) < leftover {
+			shares[i].Amount += step
+		}
+	}
+	return shares, nil
+}
+
+// moneyJSON is the JSON form of money. The amount is a decimal string so clients need not use floats.
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON encodes money as {"amount": "12.50", "currency": "USD"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+// UnmarshalJSON decodes {"amount": "12.50", "currency": "USD"}. The amount may also be a JSON number,
+// which is read from its digits rather than through a float, and a bare amount is in DefaultCurrency.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if string(data) == "null" {
+		return nil
+	}
+	currency := DefaultCurrency
+	if len(data) > 0 && data[0] == '{' {
+		var value moneyJSON
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		if value.Currency != "" {
+			currency = strings.ToUpper(value.Currency)
+		}
+		data = bytes.TrimSpace(value.Amount)
+	}
+	text := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	} else if len(data) == 0 || bytes.ContainsAny(data, "eE") {
+		return fmt.Errorf("money: amount must be a decimal such as \"12.50\"")
+	}
+	parsed, err := ParseMoney(text, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
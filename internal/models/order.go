@@ -0,0 +1,166 @@
+// This file contains models related to orders placed during a reservation
+//
+// The models here are as follows:
+// - Order
+// - OrderItem
+// - OrderItemModifier
+
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Order statuses. An order moves open -> submitted -> in_kitchen -> served -> closed,
+// and can be voided at any point before it is closed.
+const (
+	OrderStatusOpen      = "open"
+	OrderStatusSubmitted = "submitted"
+	OrderStatusInKitchen = "in_kitchen"
+	OrderStatusServed    = "served"
+	OrderStatusClosed    = "closed"
+	OrderStatusVoided    = "voided"
+)
+
+// Order item statuses. The kitchen moves an item pending -> preparing -> ready -> served,
+// and staff can void it until it has been served.
+const (
+	OrderItemStatusPending   = "pending"
+	OrderItemStatusPreparing = "preparing"
+	OrderItemStatusReady     = "ready"
+	OrderItemStatusServed    = "served"
+	OrderItemStatusVoided    = "voided"
+)
+
+// orderTransitions lists the statuses each order status may move to
+var orderTransitions = map[string][]string{
+	OrderStatusOpen:      {OrderStatusSubmitted, OrderStatusVoided},
+	OrderStatusSubmitted: {OrderStatusOpen, OrderStatusInKitchen, OrderStatusVoided},
+	OrderStatusInKitchen: {OrderStatusServed, OrderStatusVoided},
+	OrderStatusServed:    {OrderStatusClosed, OrderStatusVoided},
+}
+
+// orderItemTransitions lists the statuses each order item status may move to
+var orderItemTransitions = map[string][]string{
+	OrderItemStatusPending:   {OrderItemStatusPreparing, OrderItemStatusVoided},
+	OrderItemStatusPreparing: {OrderItemStatusReady, OrderItemStatusVoided},
+	OrderItemStatusReady:     {OrderItemStatusServed, OrderItemStatusVoided},
+}
+
+// Order represents an order record in the database.
+type Order struct {
+	OrderID       uint       `gorm:"primaryKey;autoIncrement:true"`
+	ReservationID uint       `gorm:"not null;index"`
+	RestaurantID  uint       `gorm:"not null;index"`
+	UserID        uint       `gorm:"not null"`
+	Status        string     `gorm:"size:20;not null;default:'open';index"`
+	Total         Money      `gorm:"embedded;embeddedPrefix:total_"` // Sum of the order's items that are not voided
+	IsPaid        bool       `gorm:"default:false"`
+	SubmittedAt   *time.Time // Set when the order is first sent to the restaurant
+	ServedAt      *time.Time
+	ClosedAt      *time.Time // Set when the order is closed or voided
+	CreatedAt     time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt     time.Time  `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+
+	Items       []OrderItem `gorm:"foreignKey:OrderID"`
+	Reservation Reservation `gorm:"foreignKey:ReservationID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// OrderItem is one line of an order. The name and unit price are copied from the menu item when it
+// is ordered, so later menu changes do not alter what the table was charged.
+// UnitPrice includes the price of the chosen modifiers.
+type OrderItem struct {
+	OrderItemID uint      `gorm:"primaryKey;autoIncrement:true"`
+	OrderID     uint      `gorm:"not null;index"`
+	MenuID      uint      `gorm:"not null;index"`
+	NameOfItem  string    `gorm:"size:255;not null"`
+	Quantity    uint      `gorm:"not null;default:1"`
+	UnitPrice   Money     `gorm:"embedded;embeddedPrefix:unit_price_"`
+	Notes       *string   `gorm:"size:255"` // e.g. "no onions"
+	Status      string    `gorm:"size:20;not null;default:'pending'"`
+	CreatedAt   time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+
+	Modifiers []OrderItemModifier `gorm:"foreignKey:OrderItemID"`
+	MenuItem  MenuItem            `gorm:"foreignKey:MenuID" json:"-"`
+}
+
+// OrderItemModifier is a modifier chosen for an order item, copied from the menu when ordered
+type OrderItemModifier struct {
+	OrderItemModifierID uint   `gorm:"primaryKey;autoIncrement:true"`
+	OrderItemID         uint   `gorm:"not null;index"`
+	ModifierID          uint   `gorm:"not null"`
+	Name                string `gorm:"size:100;not null"`
+	PriceDelta          Money  `gorm:"embedded;embeddedPrefix:price_delta_"`
+
+	OrderItem OrderItem `gorm:"foreignKey:OrderItemID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// CanTransitionOrder reports whether an order may move from one status to another
+func CanTransitionOrder(from, to string) bool {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransitionOrderItem reports whether an order item may move from one status to another
+func CanTransitionOrderItem(from, to string) bool {
+	for _, next := range orderItemTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEditable reports whether items can still be added to or removed from the order
+func (o *Order) IsEditable() bool {
+	return o.Status == OrderStatusOpen
+}
+
+// TransitionTo moves the order to a new status, recording when it was submitted, served or closed
+func (o *Order) TransitionTo(status string, at time.Time) error {
+	if !CanTransitionOrder(o.Status, status) {
+		return fmt.Errorf("order cannot move from %s to %s", o.Status, status)
+	}
+	switch status {
+	case OrderStatusSubmitted:
+		if o.SubmittedAt == nil {
+			o.SubmittedAt = &at
+		}
+	case OrderStatusServed:
+		o.ServedAt = &at
+	case OrderStatusClosed, OrderStatusVoided:
+		o.ClosedAt = &at
+	}
+	o.Status = status
+	return nil
+}
+
+// RecalculateTotal sets Total from the order's items, ignoring voided items. Every item must be priced
+// in the same currency.
+func (o *Order) RecalculateTotal() error {
+	total := ZeroMoney(DefaultCurrency)
+	priced := false
+	for _, item := range o.Items {
+		if item.Status == OrderItemStatusVoided {
+			continue
+		}
+		line, err := item.UnitPrice.Times(int64(item.Quantity))
+		if err != nil {
+			return err
+		}
+		if !priced {
+			total.Currency, priced = line.Currency, true
+		}
+		if total, err = total.Add(line); err != nil {
+			return fmt.Errorf("order %d: %w", o.OrderID, err)
+		}
+	}
+	o.Total = total
+	return nil
+}
@@ -4,20 +4,68 @@ import (
 	"time"
 )
 
+// Payment statuses. A payment is pending until the provider reports it completed or failed. A completed
+// payment becomes partially refunded, then refunded, as its refunds succeed.
+const (
+	PaymentStatusPending           = "pending"
+	PaymentStatusCompleted         = "completed"
+	PaymentStatusFailed            = "failed"
+	PaymentStatusPartiallyRefunded = "partially_refunded"
+	PaymentStatusRefunded          = "refunded"
+)
+
+// Refund statuses
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusFailed    = "failed"
+)
+
 type Payment struct {
 	PaymentID         uint       `gorm:"primaryKey;autoIncrement:true"`
 	UserID            uint       `gorm:"not null"`
 	RestaurantID      uint       `gorm:"not null"`
-	StripePaymentKey  string     `gorm:"size:255;not null"`
-	Amount            float64    `gorm:"not null"`
-	Currency          string     `gorm:"size:3;not null"` // ISO 4217 currency code
-	Status            string     `gorm:"size:50;not null"` // e.g., 'pending', 'completed', 'failed'
+	OrderID           *uint      `gorm:"index"` // The order paid for, if any
+	Provider          string     `gorm:"size:20;not null;default:'stripe'"` // The payments provider that holds the intent, e.g. 'stripe', 'fake'
+	StripePaymentKey  string     `gorm:"size:255;not null"` // The provider's payment intent ID
+	Amount            Money      `gorm:"embedded;embeddedPrefix:amount_"` // In an ISO 4217 currency
+	Status            string     `gorm:"size:50;not null"` // e.g., 'pending', 'completed', 'failed', 'refunded'
 	PaymentMethod     string     `gorm:"size:50;not null"` // e.g., 'card', 'bank_transfer'
 	Description       string     `gorm:"size:255"`
+	FailureMessage    *string    `gorm:"size:255"` // Why the provider declined the payment
     CreatedAt         time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
     UpdatedAt     	  time.Time  `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
 	FinalizedAt       *time.Time
+	FlaggedAt         *time.Time `gorm:"index"` // Set when reconciliation finds the payment stuck in pending
 	// Relationships
 	// User              User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
 	Restaurant        Restaurant `gorm:"foreignKey:RestaurantID;"`
 }
+
+// PaymentEvent records a webhook event received from a payments provider. Providers deliver an event
+// at least once, so redeliveries are recognized by the event ID and ignored.
+type PaymentEvent struct {
+	Provider  string    `gorm:"primaryKey;size:20"`
+	EventID   string    `gorm:"primaryKey;size:255"`
+	Type      string    `gorm:"size:100;not null"` // e.g. payment_intent.succeeded
+	PaymentID *uint     `gorm:"index"`             // The payment the event was about, if it is one of ours
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// Refund returns some or all of a completed payment to the customer. Refunds are kept, failed ones
+// included, as the record of who refunded what and why. Pending and succeeded refunds of a payment never
+// add up to more than its amount.
+type Refund struct {
+	RefundID         uint      `gorm:"primaryKey;autoIncrement"`
+	PaymentID        uint      `gorm:"not null;index"`
+	Amount           Money     `gorm:"embedded;embeddedPrefix:amount_"`
+	Reason           string    `gorm:"size:255;not null"`
+	Status           string    `gorm:"size:20;not null"`     // e.g. 'pending', 'succeeded', 'failed'
+	ProviderRefundID *string   `gorm:"size:255;uniqueIndex"` // Set once the provider has accepted the refund
+	FailureMessage   *string   `gorm:"size:255"`
+	IssuedBy         uint      `gorm:"not null"` // The user who issued the refund, or 0 if the app issued it
+	CreatedAt        time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt        time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+
+	Payment Payment `gorm:"belongsTo:Payment;foreignKey:PaymentID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT" json:"-"`
+}
@@ -4,8 +4,6 @@
 // - Receipt
 // - Restaurant
 // - Reservation
-// - MenuItem
-// - Order
 // - Table
 
 package models
@@ -46,12 +44,18 @@ type Restaurant struct {
 	NumberOfTables *int           // Pointer to allow nil (nullable)
 	Latitude       *float64       // Pointer to allow nil (nullable)
 	Longitude      *float64       // Pointer to allow nil (nullable)
-	Receipts       []Receipt      `gorm:"foreignKey:RestaurantID"` // One-to-many relationship
+	TimeZone       string         `gorm:"size:64;not null;default:'UTC'"` // IANA zone name, e.g. America/New_York
+	Receipts       []Receipt      `gorm:"foreignKey:RestaurantID"`        // One-to-many relationship
 	Reservations   *[]Reservation `gorm:"foreignKey:RestaurantID"`
 	MenuItems      *[]MenuItem    `gorm:"foreignKey:RestaurantID"` // One-to-many relationship
+	MenuSections   *[]MenuSection `gorm:"foreignKey:RestaurantID"`
 	Owner          User           `gorm:"foreignKey:OwnerID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
 	Ratings        *[]Rating      `gorm:"foreignKey:RestaurantID"` // One-to-many relationship
 	ImageURL       *string        // Pointer to allow nil (nullable)
+	// Opening hours
+	OpeningHours    *[]OpeningHours   `gorm:"foreignKey:RestaurantID"`
+	ServicePeriods  *[]ServicePeriod  `gorm:"foreignKey:RestaurantID"`
+	HoursExceptions *[]HoursException `gorm:"foreignKey:RestaurantID"`
 	// Calculated fields
 	AverageRating float32 `gorm:"default:0"`
 	ReviewCount   *int    `gorm:"-"`
@@ -61,6 +65,19 @@ func (Restaurant) TableName() string {
 	return "restaurants"
 }
 
+// Location returns the restaurant's time zone, falling back to UTC when it is unset or unknown.
+// Opening hours and reservation times are evaluated as wall clock times in this zone.
+func (r *Restaurant) Location() *time.Location {
+	if r.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(r.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
 // Rating represents a rating record in the database.
 // We can use this to calculate the average rating for a restaurant.
 type Rating struct {
@@ -74,47 +91,31 @@ type Rating struct {
 
 // Reservation represents a reservation record in the database.
 type Reservation struct {
-	ReservationID uint           `gorm:"primaryKey;autoIncrement:true"`
-	CreatedAt     time.Time      `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
-	UpdatedAt     time.Time      `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
-	DeletedAt     gorm.DeletedAt `gorm:"index"`
-	RestaurantID  uint           `gorm:"not null"`
-	UserID        uint           `gorm:"not null"`
-	TableID       uint           `gorm:"not null"`
-	Time          time.Time      `gorm:"not null"`
-	Restaurant    Restaurant     `gorm:"foreignKey:RestaurantID"`
+	ReservationID   uint           `gorm:"primaryKey;autoIncrement:true"`
+	CreatedAt       time.Time      `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt       time.Time      `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+	DeletedAt       gorm.DeletedAt `gorm:"index"`
+	RestaurantID    uint           `gorm:"not null"`
+	UserID          uint           `gorm:"not null"`
+	TableID         uint           `gorm:"not null"`
+	Time            time.Time      `gorm:"not null"`       // Start of the booking
+	EndTime         time.Time      `gorm:"not null;index"` // Time + DurationMinutes, stored for overlap queries
+	PartySize       uint           `gorm:"not null;default:1"`
+	DurationMinutes uint           `gorm:"not null;default:90"`
+	Status          string         `gorm:"size:20;not null;default:'booked'"` // booked, cancelled
+	Restaurant      Restaurant     `gorm:"foreignKey:RestaurantID"`
 	// User            User                     `gorm:"foreignKey:UserID"`
 }
 
-// MenuItem represents a menu item record in the database.
-type MenuItem struct {
-	MenuID       uint       `gorm:"primaryKey;autoIncrement:true"`
-	RestaurantID uint       `gorm:"not null"`
-	NameOfItem   *string    // Pointer to allow nil (nullable)
-	Price        *float64   // Pointer to allow nil (nullable)
-	IsAvailable  bool       `gorm:"default:true"`
-	Category     *string    // Pointer to allow nil (nullable)
-	ImageURL     *string    // Pointer to allow nil (nullable)
-	Description  *string    // Pointer to allow nil (nullable)
-	Restaurant   Restaurant `gorm:"foreignKey:RestaurantID"`
-}
-
-func (m *MenuItem) GetMenuItem(db *gorm.DB, menuId string) (*MenuItem, error) {
-	var menuItem MenuItem
-	if err := db.Where("menu_id = ?", menuId).First(&menuItem).Error; err != nil {
-		return nil, err
-	}
-	return &menuItem, nil
-}
+// Reservation statuses
+const (
+	ReservationStatusBooked    = "booked"
+	ReservationStatusCancelled = "cancelled"
+)
 
-// Order represents an order record in the database.
-type Order struct {
-	OrderID       uint     `gorm:"primaryKey;autoIncrement:true"`
-	ReservationID uint     `gorm:"not null"`
-	UserID        uint     `gorm:"not null"`
-	Total         *float64 // Pointer to allow nil (nullable)
-	IsPaid        bool     `gorm:"default:false"`
-	// Reservation    Reservation     `gorm:"foreignKey:ReservationID"`
+// IsActive reports whether the reservation still holds its table.
+func (r *Reservation) IsActive() bool {
+	return r.Status == "" || r.Status == ReservationStatusBooked
 }
 
 // Table represents a table record in the database.
@@ -131,7 +132,7 @@ type Table struct {
 	TableType           string `gorm:"size:50"`  // "booth", "standard", "high-top", "bar-seat"
 
 	IsAvailable bool  `gorm:"default:true"`  // Can table be reserved (not broken, etc.)
-	IsReserved  bool  `gorm:"default:false"` // Currently reserved
+	IsReserved  bool  `gorm:"default:false"` // Legacy flag, availability is computed from reservations
 	CustomerID  *uint // Current customer if occupied
 
 	CoordinateX *float64 `gorm:"default:null"` // Grid X position
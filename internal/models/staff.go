@@ -5,13 +5,32 @@
 
 package models
 
-// Staff represents a singular staff user in the database.
+import (
+	"time"
+)
+
+// Roles a staff member can hold in a restaurant
+const (
+	StaffRoleOwner   = "owner"
+	StaffRoleManager = "manager"
+	StaffRoleHost    = "host"
+	StaffRoleServer  = "server"
+	StaffRoleKitchen = "kitchen"
+)
+
+// Staff is a user's membership of a restaurant's team. Permissions on a restaurant come from the
+// role held there, so a user working at several restaurants has one record per restaurant.
 type Staff struct {
-	User                // Embedding User struct to inherit User fields
-	StaffID      uint   `gorm:"primaryKey;autoIncrement:false"` // Use UserID as primary key
-	Role         string `gorm:"size:50;not null"`
-	RestaurantID uint   `gorm:"not null"` // Foreign key to the Restaurant table
-	IsActive     bool   `gorm:"default:true"`
+	StaffID      uint      `gorm:"primaryKey;autoIncrement"`
+	UserID       uint      `gorm:"not null;uniqueIndex:idx_staff_user_restaurant"`
+	RestaurantID uint      `gorm:"not null;uniqueIndex:idx_staff_user_restaurant;index"` // Foreign key to the Restaurant table
+	Role         string    `gorm:"size:50;not null"`
+	IsActive     bool      `gorm:"default:true"`
+	CreatedAt    time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt    time.Time `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+
+	User       User       `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Restaurant Restaurant `gorm:"foreignKey:RestaurantID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
 }
 
 func (Staff) TableName() string {
@@ -0,0 +1,59 @@
+// This file contains the models for issued authentication tokens
+//
+// The models here are as follows:
+// - RefreshToken
+// - RevokedToken
+// - UserToken
+
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is a single-use token that a device exchanges for a new access token. Every exchange
+// rotates it, so the tokens issued to one device since login form a family sharing a FamilyID.
+// Only a hash of the token is stored.
+type RefreshToken struct {
+	RefreshTokenID uint       `gorm:"primaryKey;autoIncrement"`
+	UserID         uint       `gorm:"not null;index"`
+	FamilyID       string     `gorm:"size:64;not null;index"`
+	TokenHash      string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	DeviceName     string     `gorm:"size:255"` // The user agent the family was issued to
+	ExpiresAt      time.Time  `gorm:"not null"`
+	UsedAt         *time.Time // Set once the token has been exchanged
+	RevokedAt      *time.Time // Set on logout or when reuse is detected
+	CreatedAt      time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// RevokedToken records an access token ID or token family that may no longer be used. Rows are
+// only needed until every access token they cover has expired.
+type RevokedToken struct {
+	TokenID   string    `gorm:"primaryKey;size:64"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+}
+
+// Purposes of a UserToken
+const (
+	UserTokenPurposeEmailVerification = "email_verification"
+	UserTokenPurposePasswordReset     = "password_reset"
+	UserTokenPurposeLoginChallenge    = "login_challenge" // Issued on login to users who must enter a TOTP code
+)
+
+// UserToken is a single-use token mailed to a user to prove they control their email address, either to
+// verify it or to reset their password, or handed out between the two steps of a two-factor login.
+// Only a hash of the token is stored.
+type UserToken struct {
+	UserTokenID uint       `gorm:"primaryKey;autoIncrement"`
+	UserID      uint       `gorm:"not null;index"`
+	Purpose     string     `gorm:"size:32;not null"`
+	TokenHash   string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt   time.Time  `gorm:"not null"`
+	UsedAt      *time.Time // Set once the token has been redeemed or superseded
+	CreatedAt   time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
@@ -0,0 +1,36 @@
+// This file contains the models for two-factor authentication
+//
+// The models here are as follows:
+// - TwoFactor
+// - RecoveryCode
+
+package models
+
+import (
+	"time"
+)
+
+// TwoFactor holds a user's TOTP secret. Enrollment is pending until the user proves their authenticator
+// app works by entering a code, which sets EnabledAt.
+type TwoFactor struct {
+	UserID       uint       `gorm:"primaryKey"`
+	Secret       string     `gorm:"size:64;not null" json:"-"` // Base32, as shown to authenticator apps
+	EnabledAt    *time.Time // Nil while enrollment is pending
+	LastUsedStep int64      // Time step of the last accepted code; each code is accepted once
+	CreatedAt    time.Time  `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt    time.Time  `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
+
+// RecoveryCode is a single-use code that stands in for a TOTP code when the user has lost their
+// authenticator. Only a hash of the code is stored.
+type RecoveryCode struct {
+	RecoveryCodeID uint   `gorm:"primaryKey;autoIncrement"`
+	UserID         uint   `gorm:"not null;index"`
+	CodeHash       string `gorm:"size:64;not null" json:"-"`
+	UsedAt         *time.Time
+	CreatedAt      time.Time `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+
+	User User `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+}
@@ -9,6 +9,7 @@ package models
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -32,24 +33,26 @@ func (Entity) TableName() string {
 
 // User represents a user in the system.
 type User struct {
-	UserID        uint   `gorm:"primaryKey;autoIncrement"`
-	EntityID      uint   `gorm:"not null"`                                // Explicitly define the foreign key field
-	Entity        Entity `gorm:"foreignKey:EntityID;references:EntityID"` // Fix the reference
-	Email         string `gorm:"size:255;not null;unique"`
-	PasswordHash  string `gorm:"size:255;not null" json:"-"`
-	AccessRevoked bool
-	AuthType      string `gorm:"size:50"`
-	Latitude      float64
-	Longitude     float64
-	Phone         *string
-	Address       *string
-	ProfileImage  *string
-	Reservations  []Reservation  `gorm:"foreignKey:UserID"`
-	Ratings       []Rating       `gorm:"foreignKey:UserID"`
-	Payments      []Payment      `gorm:"foreignKey:UserID"`
-	CreatedAt     time.Time      `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
-	UpdatedAt     time.Time      `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
-	DeletedAt     gorm.DeletedAt `gorm:"index"`
+	UserID          uint   `gorm:"primaryKey;autoIncrement"`
+	EntityID        uint   `gorm:"not null"`                                // Explicitly define the foreign key field
+	Entity          Entity `gorm:"foreignKey:EntityID;references:EntityID"` // Fix the reference
+	Email           string `gorm:"size:255;not null;unique"`
+	PasswordHash    string `gorm:"size:255;not null" json:"-"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+	AccessRevoked   bool
+	TokensRevokedAt *time.Time `json:"-"` // Set by signing out everywhere; tokens and sessions issued earlier are rejected
+	AuthType        string     `gorm:"size:50"`
+	Latitude        float64
+	Longitude       float64
+	Phone           *string
+	Address         *string
+	ProfileImage    *string
+	Reservations    []Reservation  `gorm:"foreignKey:UserID"`
+	Ratings         []Rating       `gorm:"foreignKey:UserID"`
+	Payments        []Payment      `gorm:"foreignKey:UserID"`
+	CreatedAt       time.Time      `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt       time.Time      `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+	DeletedAt       gorm.DeletedAt `gorm:"index"`
 }
 
 // Grab a user's payments based off signed in user session
@@ -99,13 +102,16 @@ func (user *User) UpdateAccountInformation(db *gorm.DB, firstName string, lastNa
 		return nil, err
 	}
 
-	// Then update the user
-	if err := tx.Model(user).Updates(
-		map[string]interface{}{
-			"email":   email,
-			"address": userAddress,
-			"phone":   phone,
-		}).Error; err != nil {
+	// Then update the user; a changed email address is no longer verified
+	updates := map[string]interface{}{
+		"email":   email,
+		"address": userAddress,
+		"phone":   phone,
+	}
+	if !strings.EqualFold(email, user.Email) {
+		updates["email_verified_at"] = nil
+	}
+	if err := tx.Model(user).Updates(updates).Error; err != nil {
 		tx.Rollback()
 		return nil, err
 	}
@@ -261,17 +267,21 @@ func (Customer) TableName() string {
 	return "customers"
 }
 
-// UserLogin represents a record of a user login.
+// UserLogin represents a record of a user login attempt, successful or not.
 type UserLogin struct {
-	LoginID    uint `gorm:"primaryKey;autoIncrement"`
-	UserID     uint `gorm:"not null;index"`
-	User       User `gorm:"foreignKey:UserID;references:UserID"`
-	ClientID   *uint
-	RemoteAddr *string
-	UserAgent  *string
-	CreatedAt  time.Time      `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
-	UpdatedAt  time.Time      `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
-	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	LoginID       uint    `gorm:"primaryKey;autoIncrement"`
+	UserID        *uint   `gorm:"index"` // Nil when the email did not match a user
+	User          *User   `gorm:"foreignKey:UserID;references:UserID" json:"-"`
+	Email         string  `gorm:"size:255;index"`
+	Succeeded     bool    `gorm:"not null;default:false"`
+	FailureReason *string `gorm:"size:50"`
+	FamilyID      *string `gorm:"size:64"` // Refresh token family issued by a successful login
+	ClientID      *uint
+	RemoteAddr    *string
+	UserAgent     *string
+	CreatedAt     time.Time      `gorm:"column:created_at;type:timestamp;not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt     time.Time      `gorm:"column:updated_at;type:timestamp;not null;default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
 }
 
 func (UserLogin) TableName() string {
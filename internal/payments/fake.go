@@ -0,0 +1,244 @@
+package payments
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Payment methods the fake provider declines, named like Stripe's test payment methods. Every other
+// payment method succeeds.
+const (
+	FakePaymentMethodDeclined          = "pm_card_chargeDeclined"
+	FakePaymentMethodInsufficientFunds = "pm_card_chargeDeclinedInsufficientFunds"
+)
+
+// FakeProvider keeps intents in memory and settles them at once, for tests and local development
+type FakeProvider struct {
+	mu         sync.Mutex
+	next       int
+	intents    map[string]*Intent
+	manual     map[string]bool     // Intents created for manual capture
+	refunded   map[string]int64    // Amount refunded of each intent
+	refunds    map[string][]Refund // Refunds of each intent, newest first
+	keys       map[string]string   // Intent created for each idempotency key
+	refundKeys map[string]string   // Refund created for each idempotency key
+
+	WebhookSecret string // Secret that webhooks must be signed with, as by SignWebhookPayload
+}
+
+// NewFakeProvider creates a provider with no intents
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		intents:    map[string]*Intent{},
+		manual:     map[string]bool{},
+		refunded:   map[string]int64{},
+		refunds:    map[string][]Refund{},
+		keys:       map[string]string{},
+		refundKeys: map[string]string{},
+	}
+}
+
+// Name identifies the fake in stored payments
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// newID returns a new identifier with the prefix. Call it with the lock held.
+func (p *FakeProvider) newID(prefix string) string {
+	p.next++
+	return fmt.Sprintf("%s_fake_%d", prefix, p.next)
+}
+
+// CreateIntent records a new intent awaiting a payment method
+func (p *FakeProvider) CreateIntent(ctx context.Context, params IntentParams) (*Intent, error) {
+	if params.Amount <= 0 {
+		return nil, fmt.Errorf("payments: amount must be positive")
+	}
+	secret := make([]byte, 12)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if id, ok := p.keys[params.IdempotencyKey]; ok && params.IdempotencyKey != "" {
+		intent := *p.intents[id]
+		return &intent, nil
+	}
+	intent := &Intent{
+		ID:       p.newID("pi"),
+		Amount:   params.Amount,
+		Currency: strings.ToLower(params.Currency),
+		Status:   IntentRequiresPaymentMethod,
+	}
+	intent.ClientSecret = intent.ID + "_secret_" + hex.EncodeToString(secret)
+	p.intents[intent.ID] = intent
+	p.manual[intent.ID] = params.ManualCapture
+	if params.IdempotencyKey != "" {
+		p.keys[params.IdempotencyKey] = intent.ID
+	}
+	result := *intent
+	return &result, nil
+}
+
+// Confirm settles the intent, or authorizes it when it was created for manual capture
+func (p *FakeProvider) Confirm(ctx context.Context, intentID, paymentMethod string) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if intent.Status != IntentRequiresPaymentMethod && intent.Status != IntentRequiresConfirmation {
+		return nil, ErrInvalidState
+	}
+
+	switch paymentMethod {
+	case FakePaymentMethodDeclined:
+		intent.LastError = "Your card was declined."
+		return nil, &CardError{Code: "card_declined", DeclineCode: "generic_decline", Message: intent.LastError}
+	case FakePaymentMethodInsufficientFunds:
+		intent.LastError = "Your card has insufficient funds."
+		return nil, &CardError{Code: "card_declined", DeclineCode: "insufficient_funds", Message: intent.LastError}
+	}
+	intent.LastError = ""
+	if p.manual[intent.ID] {
+		intent.Status = IntentRequiresCapture
+	} else {
+		intent.Status = IntentSucceeded
+		intent.AmountReceived = intent.Amount
+	}
+	result := *intent
+	return &result, nil
+}
+
+// Capture collects an authorized intent
+func (p *FakeProvider) Capture(ctx context.Context, intentID string, amount int64) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if intent.Status != IntentRequiresCapture || amount < 0 || amount > intent.Amount {
+		return nil, ErrInvalidState
+	}
+	if amount == 0 {
+		amount = intent.Amount
+	}
+	intent.Status = IntentSucceeded
+	intent.AmountReceived = amount
+	result := *intent
+	return &result, nil
+}
+
+// Cancel cancels an intent that has not succeeded
+func (p *FakeProvider) Cancel(ctx context.Context, intentID string) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if intent.Status == IntentSucceeded || intent.Status == IntentCanceled {
+		return nil, ErrInvalidState
+	}
+	intent.Status = IntentCanceled
+	result := *intent
+	return &result, nil
+}
+
+// Refund returns an amount of a succeeded intent at once
+func (p *FakeProvider) Refund(ctx context.Context, params RefundParams) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[params.IntentID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if id, ok := p.refundKeys[params.IdempotencyKey]; ok && params.IdempotencyKey != "" {
+		for _, refund := range p.refunds[params.IntentID] {
+			if refund.ID == id {
+				return &refund, nil
+			}
+		}
+	}
+	remaining := intent.AmountReceived - p.refunded[params.IntentID]
+	amount := params.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	if intent.Status != IntentSucceeded || amount <= 0 || amount > remaining {
+		return nil, ErrInvalidState
+	}
+	p.refunded[params.IntentID] += amount
+	refund := Refund{ID: p.newID("re"), IntentID: params.IntentID, Amount: amount, Status: RefundSucceeded, Metadata: params.Metadata}
+	p.refunds[params.IntentID] = append([]Refund{refund}, p.refunds[params.IntentID]...)
+	if params.IdempotencyKey != "" {
+		p.refundKeys[params.IdempotencyKey] = refund.ID
+	}
+	return &refund, nil
+}
+
+// ListRefunds returns the refunds of an intent, newest first
+func (p *FakeProvider) ListRefunds(ctx context.Context, intentID string) ([]Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.intents[intentID]; !ok {
+		return nil, ErrNotFound
+	}
+	return append([]Refund{}, p.refunds[intentID]...), nil
+}
+
+// GetIntent returns the intent as it is now
+func (p *FakeProvider) GetIntent(ctx context.Context, intentID string) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := *intent
+	return &result, nil
+}
+
+// ParseWebhook verifies a webhook signed with the fake's WebhookSecret and decodes its event
+func (p *FakeProvider) ParseWebhook(payload []byte, header http.Header) (*Event, error) {
+	return parseSignedEvent(payload, header, p.WebhookSecret)
+}
+
+// EventPayload returns the webhook payload Stripe would send about the intent's current state, for
+// simulating webhooks. Sign it with SignWebhookPayload.
+func (p *FakeProvider) EventPayload(eventID, eventType, intentID string) ([]byte, error) {
+	intent, err := p.GetIntent(context.Background(), intentID)
+	if err != nil {
+		return nil, err
+	}
+	object := stripeIntent{
+		ID:             intent.ID,
+		Amount:         intent.Amount,
+		AmountReceived: intent.AmountReceived,
+		Currency:       intent.Currency,
+		Status:         intent.Status,
+	}
+	if intent.LastError != "" {
+		object.LastPaymentError = &struct {
+			Message string `json:"message"`
+		}{intent.LastError}
+	}
+	event := map[string]any{
+		"id":      eventID,
+		"object":  "event",
+		"type":    eventType,
+		"created": time.Now().Unix(),
+		"data":    map[string]any{"object": object},
+	}
+	return json.Marshal(event)
+}
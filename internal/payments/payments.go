@@ -0,0 +1,163 @@
+// The payments package charges customers through a payment provider.
+//
+// The providers here are as follows:
+// - Provider
+// - StripeProvider
+// - FakeProvider
+// - Event
+// - SignWebhookPayload
+// - NewProviderFromEnv
+// - SetDefault
+// - Default
+
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Statuses of a payment intent. They are Stripe's, which the fake provider follows.
+const (
+	IntentRequiresPaymentMethod = "requires_payment_method" // New, or the last confirmation was declined
+	IntentRequiresConfirmation  = "requires_confirmation"
+	IntentRequiresAction        = "requires_action" // The customer must authenticate, e.g. 3-D Secure
+	IntentProcessing            = "processing"
+	IntentRequiresCapture       = "requires_capture" // Authorized; the funds are held until captured
+	IntentCanceled              = "canceled"
+	IntentSucceeded             = "succeeded"
+)
+
+// Statuses of a refund
+const (
+	RefundPending   = "pending"
+	RefundSucceeded = "succeeded"
+	RefundFailed    = "failed"
+)
+
+// Errors returned by providers
+var (
+	ErrNotFound     = errors.New("payments: payment intent not found")
+	ErrInvalidState = errors.New("payments: payment intent is not in a state that allows this")
+)
+
+// CardError is returned when the customer's payment method is declined
+type CardError struct {
+	Code        string // e.g. card_declined
+	DeclineCode string // e.g. insufficient_funds
+	Message     string // Safe to show to the customer
+}
+
+func (e *CardError) Error() string {
+	return "payments: " + e.Message
+}
+
+// IntentParams describes a payment to collect. Amounts are in the currency's minor unit, e.g. cents, and
+// currencies are ISO 4217 codes.
+type IntentParams struct {
+	Amount         int64
+	Currency       string
+	Description    string
+	ManualCapture  bool              // Only authorize on confirmation; Capture collects the funds
+	Metadata       map[string]string // Shown with the payment in the provider's dashboard
+	IdempotencyKey string            // Retries with the same key create a single intent
+}
+
+// Intent is a provider's record of a payment being collected
+type Intent struct {
+	ID             string
+	Amount         int64
+	AmountReceived int64
+	Currency       string
+	Status         string
+	ClientSecret   string // Lets the customer's browser confirm the intent itself
+	LastError      string // Why the last confirmation failed, if it did
+}
+
+// RefundParams describes a refund of a succeeded intent
+type RefundParams struct {
+	IntentID       string
+	Amount         int64             // Zero refunds what has not been refunded yet
+	Metadata       map[string]string // Returned with the refund, e.g. in webhooks
+	IdempotencyKey string            // Retries with the same key create a single refund
+}
+
+// Refund returns some or all of a succeeded payment to the customer
+type Refund struct {
+	ID            string
+	IntentID      string
+	Amount        int64
+	Status        string
+	FailureReason string            // Why a failed refund failed, e.g. expired_or_canceled_card
+	Metadata      map[string]string // As given in RefundParams
+}
+
+// Provider collects payments
+type Provider interface {
+	// Name identifies the provider in stored payments, e.g. "stripe"
+	Name() string
+	CreateIntent(ctx context.Context, params IntentParams) (*Intent, error)
+	// Confirm charges or authorizes the intent with a payment method. A decline is a *CardError.
+	Confirm(ctx context.Context, intentID, paymentMethod string) (*Intent, error)
+	// Capture collects an authorized amount; zero captures all of it
+	Capture(ctx context.Context, intentID string, amount int64) (*Intent, error)
+	// Cancel stops an intent that has not succeeded from being paid. Intents that succeeded, are
+	// processing or were canceled already are ErrInvalidState.
+	Cancel(ctx context.Context, intentID string) (*Intent, error)
+	Refund(ctx context.Context, params RefundParams) (*Refund, error)
+	// ListRefunds returns the refunds of an intent, newest first
+	ListRefunds(ctx context.Context, intentID string) ([]Refund, error)
+	GetIntent(ctx context.Context, intentID string) (*Intent, error)
+	// ParseWebhook verifies the signature of a webhook request and decodes its event. A forged or
+	// stale request is ErrInvalidSignature.
+	ParseWebhook(payload []byte, header http.Header) (*Event, error)
+}
+
+// NewProviderFromEnv chooses a provider from the environment. PAYMENTS_PROVIDER is stripe, the default,
+// which needs STRIPE_SECRET_KEY, or fake for the in-process fake, which collects no money and so must be
+// asked for. Either verifies webhooks with STRIPE_WEBHOOK_SECRET.
+func NewProviderFromEnv() (Provider, error) {
+	webhookSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
+	switch name := os.Getenv("PAYMENTS_PROVIDER"); name {
+	case "", "stripe":
+		key := os.Getenv("STRIPE_SECRET_KEY")
+		if key == "" {
+			return nil, errors.New("payments: STRIPE_SECRET_KEY is not set; set it, or set PAYMENTS_PROVIDER=fake to simulate payments")
+		}
+		if webhookSecret == "" {
+			log.Println("payments: STRIPE_WEBHOOK_SECRET is not set; payment webhooks will be rejected")
+		}
+		return &StripeProvider{SecretKey: key, BaseURL: os.Getenv("STRIPE_API_BASE"), WebhookSecret: webhookSecret}, nil
+	case "fake":
+		log.Println("payments: PAYMENTS_PROVIDER is fake; payments are simulated and no money is collected")
+		fake := NewFakeProvider()
+		fake.WebhookSecret = webhookSecret
+		return fake, nil
+	default:
+		return nil, fmt.Errorf("payments: unknown PAYMENTS_PROVIDER %q; use stripe or fake", name)
+	}
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultProvider Provider = NewFakeProvider()
+)
+
+// SetDefault replaces the provider used by the handlers
+func SetDefault(provider Provider) {
+	defaultMu.Lock()
+	defaultProvider = provider
+	defaultMu.Unlock()
+}
+
+// Default returns the provider used by the handlers
+func Default() Provider {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultProvider
+}
@@ -0,0 +1,265 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Address of Stripe's API when StripeProvider.BaseURL is not set
+const stripeAPIBase = "https://api.stripe.com"
+
+// StripeProvider collects payments through Stripe's REST API
+type StripeProvider struct {
+	SecretKey     string
+	BaseURL       string       // Defaults to Stripe's API; point it at stripe-mock for local testing
+	HTTPClient    *http.Client // Defaults to a client with a 30 second timeout
+	WebhookSecret string       // Signing secret of the webhook endpoint, whsec_...
+}
+
+// stripeIntent is the JSON form of a Stripe PaymentIntent
+type stripeIntent struct {
+	ID               string `json:"id"`
+	Amount           int64  `json:"amount"`
+	AmountReceived   int64  `json:"amount_received"`
+	Currency         string `json:"currency"`
+	Status           string `json:"status"`
+	ClientSecret     string `json:"client_secret"`
+	LastPaymentError *struct {
+		Message string `json:"message"`
+	} `json:"last_payment_error"`
+}
+
+func (s stripeIntent) intent() *Intent {
+	intent := &Intent{
+		ID:             s.ID,
+		Amount:         s.Amount,
+		AmountReceived: s.AmountReceived,
+		Currency:       s.Currency,
+		Status:         s.Status,
+		ClientSecret:   s.ClientSecret,
+	}
+	if s.LastPaymentError != nil {
+		intent.LastError = s.LastPaymentError.Message
+	}
+	return intent
+}
+
+// stripeRefund is the JSON form of a Stripe Refund
+type stripeRefund struct {
+	ID            string            `json:"id"`
+	Amount        int64             `json:"amount"`
+	PaymentIntent string            `json:"payment_intent"`
+	Status        string            `json:"status"`
+	FailureReason string            `json:"failure_reason"`
+	Metadata      map[string]string `json:"metadata"`
+}
+
+func (s stripeRefund) refund() *Refund {
+	status := s.Status
+	switch status {
+	case RefundSucceeded, RefundFailed:
+	case "canceled":
+		status = RefundFailed
+	default:
+		status = RefundPending // pending or requires_action
+	}
+	return &Refund{ID: s.ID, IntentID: s.PaymentIntent, Amount: s.Amount, Status: status, FailureReason: s.FailureReason, Metadata: s.Metadata}
+}
+
+// stripeError is the JSON form of a Stripe API error
+type stripeError struct {
+	Error struct {
+		Type        string `json:"type"`
+		Code        string `json:"code"`
+		DeclineCode string `json:"decline_code"`
+		Message     string `json:"message"`
+	} `json:"error"`
+}
+
+var stripeHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+// Name identifies Stripe in stored payments
+func (s *StripeProvider) Name() string {
+	return "stripe"
+}
+
+// call sends a request to the API and decodes a successful response into out
+func (s *StripeProvider) call(ctx context.Context, method, path string, form url.Values, idempotencyKey string, out any) error {
+	base := s.BaseURL
+	if base == "" {
+		base = stripeAPIBase
+	}
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(base, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.SecretKey, "")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	client := s.HTTPClient
+	if client == nil {
+		client = stripeHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("payments: stripe: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("payments: stripe: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		var apiErr stripeError
+		if err := json.Unmarshal(data, &apiErr); err != nil || apiErr.Error.Type == "" {
+			return fmt.Errorf("payments: stripe responded %d", resp.StatusCode)
+		}
+		switch {
+		case apiErr.Error.Type == "card_error":
+			return &CardError{Code: apiErr.Error.Code, DeclineCode: apiErr.Error.DeclineCode, Message: apiErr.Error.Message}
+		case apiErr.Error.Code == "resource_missing":
+			return ErrNotFound
+		case apiErr.Error.Code == "payment_intent_unexpected_state" || apiErr.Error.Code == "charge_already_refunded":
+			return fmt.Errorf("%w: %s", ErrInvalidState, apiErr.Error.Message)
+		}
+		return fmt.Errorf("payments: stripe: %s", apiErr.Error.Message)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("payments: stripe: decoding response: %w", err)
+	}
+	return nil
+}
+
+// intentPath returns the API path of an intent, or of an action on it
+func intentPath(intentID, action string) string {
+	path := "/v1/payment_intents/" + url.PathEscape(intentID)
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
+
+// CreateIntent creates a PaymentIntent that is confirmed on the server, so it never redirects
+func (s *StripeProvider) CreateIntent(ctx context.Context, params IntentParams) (*Intent, error) {
+	form := url.Values{
+		"amount":                             {strconv.FormatInt(params.Amount, 10)},
+		"currency":                           {strings.ToLower(params.Currency)},
+		"automatic_payment_methods[enabled]": {"true"},
+		"automatic_payment_methods[allow_redirects]": {"never"},
+	}
+	if params.Description != "" {
+		form.Set("description", params.Description)
+	}
+	if params.ManualCapture {
+		form.Set("capture_method", "manual")
+	}
+	for key, value := range params.Metadata {
+		form.Set("metadata["+key+"]", value)
+	}
+	var result stripeIntent
+	if err := s.call(ctx, http.MethodPost, "/v1/payment_intents", form, params.IdempotencyKey, &result); err != nil {
+		return nil, err
+	}
+	return result.intent(), nil
+}
+
+// Confirm confirms the intent with a payment method
+func (s *StripeProvider) Confirm(ctx context.Context, intentID, paymentMethod string) (*Intent, error) {
+	var result stripeIntent
+	form := url.Values{"payment_method": {paymentMethod}}
+	if err := s.call(ctx, http.MethodPost, intentPath(intentID, "confirm"), form, "", &result); err != nil {
+		return nil, err
+	}
+	return result.intent(), nil
+}
+
+// Capture captures an authorized intent
+func (s *StripeProvider) Capture(ctx context.Context, intentID string, amount int64) (*Intent, error) {
+	form := url.Values{}
+	if amount > 0 {
+		form.Set("amount_to_capture", strconv.FormatInt(amount, 10))
+	}
+	var result stripeIntent
+	if err := s.call(ctx, http.MethodPost, intentPath(intentID, "capture"), form, "", &result); err != nil {
+		return nil, err
+	}
+	return result.intent(), nil
+}
+
+// Cancel cancels the intent
+func (s *StripeProvider) Cancel(ctx context.Context, intentID string) (*Intent, error) {
+	var result stripeIntent
+	if err := s.call(ctx, http.MethodPost, intentPath(intentID, "cancel"), url.Values{}, "", &result); err != nil {
+		return nil, err
+	}
+	return result.intent(), nil
+}
+
+// Refund creates a refund of the intent
+func (s *StripeProvider) Refund(ctx context.Context, params RefundParams) (*Refund, error) {
+	form := url.Values{"payment_intent": {params.IntentID}}
+	if params.Amount > 0 {
+		form.Set("amount", strconv.FormatInt(params.Amount, 10))
+	}
+	for key, value := range params.Metadata {
+		form.Set("metadata["+key+"]", value)
+	}
+	var result stripeRefund
+	if err := s.call(ctx, http.MethodPost, "/v1/refunds", form, params.IdempotencyKey, &result); err != nil {
+		return nil, err
+	}
+	if result.PaymentIntent == "" {
+		result.PaymentIntent = params.IntentID
+	}
+	return result.refund(), nil
+}
+
+// ListRefunds lists the latest 100 refunds of the intent
+func (s *StripeProvider) ListRefunds(ctx context.Context, intentID string) ([]Refund, error) {
+	query := url.Values{"payment_intent": {intentID}, "limit": {"100"}}
+	var result struct {
+		Data []stripeRefund `json:"data"`
+	}
+	if err := s.call(ctx, http.MethodGet, "/v1/refunds?"+query.Encode(), nil, "", &result); err != nil {
+		return nil, err
+	}
+	refunds := make([]Refund, 0, len(result.Data))
+	for _, refund := range result.Data {
+		if refund.PaymentIntent == "" {
+			refund.PaymentIntent = intentID
+		}
+		refunds = append(refunds, *refund.refund())
+	}
+	return refunds, nil
+}
+
+// GetIntent retrieves the intent
+func (s *StripeProvider) GetIntent(ctx context.Context, intentID string) (*Intent, error) {
+	var result stripeIntent
+	if err := s.call(ctx, http.MethodGet, intentPath(intentID, ""), nil, "", &result); err != nil {
+		return nil, err
+	}
+	return result.intent(), nil
+}
+
+// ParseWebhook verifies a webhook sent by Stripe and decodes its event
+func (s *StripeProvider) ParseWebhook(payload []byte, header http.Header) (*Event, error) {
+	return parseSignedEvent(payload, header, s.WebhookSecret)
+}
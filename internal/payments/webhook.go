@@ -0,0 +1,118 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header carrying the signature of a webhook payload. The fake provider signs its events the same way.
+const SignatureHeader = "Stripe-Signature"
+
+// How far a webhook's signed timestamp may be from now, which limits replaying captured events
+const WebhookTolerance = 5 * time.Minute
+
+// ErrInvalidSignature is returned for webhooks that were not signed with the webhook secret
+var ErrInvalidSignature = errors.New("payments: webhook signature is invalid")
+
+// Event is a webhook notification from a provider about one of its objects
+type Event struct {
+	ID      string
+	Type    string // e.g. payment_intent.succeeded
+	Created time.Time
+	Intent  *Intent // The intent the event is about, for payment_intent.* events
+	Refund  *Refund // The refund the event is about, for refund.* and charge.refund.* events
+}
+
+// stripeEvent is the JSON form of a Stripe Event
+type stripeEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// SignWebhookPayload returns the signature header value for a payload sent at the given time, in Stripe's
+// format: t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<payload>">
+func SignWebhookPayload(payload []byte, secret string, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + webhookSignature(payload, secret, timestamp)
+}
+
+func webhookSignature(payload []byte, secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyWebhookSignature checks a signature header made by SignWebhookPayload
+func verifyWebhookSignature(payload []byte, header, secret string, now time.Time) error {
+	if secret == "" {
+		return fmt.Errorf("%w: no webhook secret is configured", ErrInvalidSignature)
+	}
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(sent, 0)); age > WebhookTolerance || age < -WebhookTolerance {
+		return fmt.Errorf("%w: timestamp is outside the tolerance", ErrInvalidSignature)
+	}
+	expected := []byte(webhookSignature(payload, secret, timestamp))
+	for _, signature := range signatures {
+		if hmac.Equal(expected, []byte(signature)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// parseSignedEvent verifies a Stripe-format webhook and decodes its event
+func parseSignedEvent(payload []byte, header http.Header, secret string) (*Event, error) {
+	if err := verifyWebhookSignature(payload, header.Get(SignatureHeader), secret, time.Now()); err != nil {
+		return nil, err
+	}
+	var raw stripeEvent
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, fmt.Errorf("payments: decoding webhook: %w", err)
+	}
+	if raw.ID == "" || raw.Type == "" {
+		return nil, fmt.Errorf("payments: webhook has no event ID or type")
+	}
+	event := &Event{ID: raw.ID, Type: raw.Type, Created: time.Unix(raw.Created, 0).UTC()}
+	if strings.HasPrefix(raw.Type, "payment_intent.") {
+		var intent stripeIntent
+		if err := json.Unmarshal(raw.Data.Object, &intent); err != nil || intent.ID == "" {
+			return nil, fmt.Errorf("payments: webhook %s has no payment intent", raw.ID)
+		}
+		event.Intent = intent.intent()
+	}
+	if strings.HasPrefix(raw.Type, "refund.") || strings.HasPrefix(raw.Type, "charge.refund.") {
+		var refund stripeRefund
+		if err := json.Unmarshal(raw.Data.Object, &refund); err != nil || refund.ID == "" {
+			return nil, fmt.Errorf("payments: webhook %s has no refund", raw.ID)
+		}
+		event.Refund = refund.refund()
+	}
+	return event, nil
+}
@@ -4,12 +4,28 @@
 // - AuthRoutes
 // - Login
 // - Logout
+// - RefreshToken
+// - JWKS
+// - GetLoginHistory
+// - GetSessions
+// - LogoutEverywhere
+// - RequestEmailVerification
+// - VerifyEmail
+// - RequestPasswordReset
+// - ResetPassword
+// - VerifyLoginTwoFactor
+// - SetUpLoginTwoFactor
+// - SetUpTwoFactor
+// - EnableTwoFactor
+// - DisableTwoFactor
+// - RegenerateRecoveryCodes
 // .. more to be added later
 
 package routes
 
 import (
 	"waitress-backend/internal/handlers"
+	"waitress-backend/internal/utilities"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -19,7 +35,24 @@ import (
 func AuthRoutes(router *gin.Engine, db *gorm.DB) {
 	auth := router.Group("api/auth")
 	{
-		auth.POST("/login", handlers.Login(db, router))
+		auth.POST("/login", utilities.ClientSignatureOptional(db), handlers.Login(db, router))
+		auth.POST("/login/2fa", utilities.ClientSignatureOptional(db), handlers.VerifyLoginTwoFactor(db, router))
+		auth.POST("/login/2fa/setup", handlers.SetUpLoginTwoFactor(db, router))
 		auth.POST("/logout", handlers.Logout(db))
+		auth.POST("/refresh", handlers.RefreshToken(db, router))
+		auth.GET("/logins", utilities.RequirePermission("account:manage"), handlers.GetLoginHistory(db, router))
+		auth.GET("/sessions", utilities.RequirePermission("account:manage"), handlers.GetSessions(db, router))
+		auth.POST("/logout-all", utilities.RequirePermission("account:manage"), handlers.LogoutEverywhere(db, router))
+		auth.POST("/verify-email/request", utilities.RequirePermission("account:manage"), handlers.RequestEmailVerification(db, router))
+		auth.POST("/verify-email", handlers.VerifyEmail(db, router))
+		auth.POST("/password-reset/request", handlers.RequestPasswordReset(db, router))
+		auth.POST("/password-reset", handlers.ResetPassword(db, router))
+		auth.POST("/2fa/setup", utilities.RequirePermission("account:manage"), handlers.SetUpTwoFactor(db, router))
+		auth.POST("/2fa/enable", utilities.RequirePermission("account:manage"), handlers.EnableTwoFactor(db, router))
+		auth.POST("/2fa/disable", utilities.RequirePermission("account:manage"), handlers.DisableTwoFactor(db, router))
+		auth.POST("/2fa/recovery-codes", utilities.RequirePermission("account:manage"), handlers.RegenerateRecoveryCodes(db, router))
 	}
+
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", handlers.JWKS())
 }
@@ -0,0 +1,27 @@
+// This file contains the routes for managing API clients
+//
+// The routes here are as follows:
+// - ClientRoutes
+
+package routes
+
+import (
+	"waitress-backend/internal/handlers"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ClientRoutes sets up the routes for registering API clients and rotating or revoking their secrets
+func ClientRoutes(router *gin.Engine, db *gorm.DB) {
+	clients := router.Group("api/clients")
+	{
+		// Signed by the client itself rather than called by a user
+		clients.GET("/self", utilities.ClientSignatureRequired(db), handlers.GetCurrentAPIClient(db, router))
+		clients.GET("", utilities.RequirePermission("client:manage"), handlers.GetAPIClients(db, router))
+		clients.POST("", utilities.RequirePermission("client:manage"), handlers.CreateAPIClient(db, router))
+		clients.POST("/:clientId/rotate", utilities.RequirePermission("client:manage"), handlers.RotateAPIClientSecret(db, router))
+		clients.POST("/:clientId/revoke", utilities.RequirePermission("client:manage"), handlers.RevokeAPIClient(db, router))
+	}
+}
@@ -0,0 +1,29 @@
+// This file contains the routes for the payments provider and payment operations
+//
+// The routes here are as follows:
+// - PaymentRoutes
+
+package routes
+
+import (
+	"waitress-backend/internal/handlers"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PaymentRoutes sets up the routes for provider webhooks, reconciling payments and refunds. Paying an order is
+// under the restaurant routes.
+func PaymentRoutes(router *gin.Engine, db *gorm.DB) {
+	paymentRoutes := router.Group("api/payments")
+	{
+		// Called by the provider, which is authenticated by the webhook signature
+		paymentRoutes.POST("/webhook", handlers.HandlePaymentWebhook(db, router))
+		paymentRoutes.POST("/reconcile", utilities.RequirePermission("payment:reconcile"), handlers.ReconcilePayments(db, router))
+
+		// Refunds of completed payments, for the managers of the payment's restaurant
+		paymentRoutes.GET("/:paymentId/refunds", utilities.RequirePermission("payment:refund"), handlers.GetPaymentRefunds(db, router))
+		paymentRoutes.POST("/:paymentId/refunds", utilities.RequirePermission("payment:refund"), utilities.Idempotent(), handlers.CreatePaymentRefund(db, router))
+	}
+}
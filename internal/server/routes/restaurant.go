@@ -8,6 +8,7 @@ package routes
 
 import (
 	"waitress-backend/internal/handlers"
+	"waitress-backend/internal/models"
 	"waitress-backend/internal/utilities"
 
 	"github.com/gin-gonic/gin"
@@ -16,24 +17,81 @@ import (
 
 // RestaurantRoutes sets up the routes for the restaurant endpoints
 func RestaurantRoutes(router *gin.Engine, db *gorm.DB) {
-	userGroups := utilities.NewUserGroups()           // Initialize your user groups
-	authGroups := utilities.NewAuthGroups(userGroups) // Create the auth groups from user groups
+	// Roles within the restaurant named by :restaurantId, checked after RequirePermission
+	ownerRequired := utilities.RestaurantRoleRequired(db, models.StaffRoleOwner)
+	managementRequired := utilities.RestaurantRoleRequired(db, utilities.RestaurantManagementRoles...)
+	frontOfHouseRequired := utilities.RestaurantRoleRequired(db, utilities.RestaurantFrontOfHouseRoles...)
+	kitchenRequired := utilities.RestaurantRoleRequired(db, utilities.RestaurantKitchenRoles...)
+	anyRoleRequired := utilities.RestaurantRoleRequired(db, utilities.RestaurantAllRoles...)
+	// Ordering and paying need a verified email address, checked after RequirePermission
+	verifiedEmailRequired := utilities.EmailVerificationRequired(db)
 
 	restaurantRoutes := router.Group("api/restaurant")
 	{
-		// Use AuthGroups to apply middleware
-		restaurantRoutes.POST("/create", utilities.UserRequired(authGroups, "Admin", "all"), handlers.CreateRestaurant(db, router))
-		restaurantRoutes.POST("/edit", utilities.UserRequired(authGroups, "Admin", "all"), handlers.EditRestaurant(db, router))
-
-		// Applying more specific or different groups as needed
-		restaurantRoutes.POST("/local", utilities.UserRequired(authGroups, "Customer", "all"), handlers.GetLocalRestaurants(db, router))
-		restaurantRoutes.POST("/reservations/:restaurantId/get", utilities.UserRequired(authGroups, "Staff", "all"), handlers.GetReservations(db, router))
-		restaurantRoutes.POST("/:restaurantId/get", utilities.UserRequired(authGroups, "Customer", "all"), handlers.GetSingleRestaurant(db, router))
-		restaurantRoutes.GET("/avgrating/:restaurantId", utilities.UserRequired(authGroups, "Customer", "all"), handlers.GetAvgRating(db, router))
-		restaurantRoutes.POST("/top10restaurants/", utilities.UserRequired(authGroups, "Customer", "all"), handlers.GetGlobalTopRestaurants(db, router))
-		restaurantRoutes.POST("/:restaurantId/favorites", utilities.UserRequired(authGroups, "Customer", "all"), handlers.UserToFavorites(db, router))
+		restaurantRoutes.POST("/create", utilities.RequirePermission("restaurant:create"), handlers.CreateRestaurant(db, router))
+		restaurantRoutes.PATCH("/:restaurantId", utilities.RequirePermission("restaurant:edit"), ownerRequired, handlers.EditRestaurant(db, router))
+
+		restaurantRoutes.POST("/local", utilities.RequirePermission("restaurant:read"), handlers.GetLocalRestaurants(db, router))
+		restaurantRoutes.POST("/reservations/:restaurantId/get", utilities.RequirePermission("reservation:read"), frontOfHouseRequired, handlers.GetReservations(db, router))
+		restaurantRoutes.POST("/:restaurantId/get", utilities.RequirePermission("restaurant:read"), handlers.GetSingleRestaurant(db, router))
+		restaurantRoutes.GET("/avgrating/:restaurantId", utilities.RequirePermission("restaurant:read"), handlers.GetAvgRating(db, router))
+		restaurantRoutes.POST("/top10restaurants/", utilities.RequirePermission("restaurant:read"), handlers.GetGlobalTopRestaurants(db, router))
+		restaurantRoutes.POST("/:restaurantId/favorites", utilities.RequirePermission("favorite:write"), handlers.UserToFavorites(db, router))
 
 		// Enhanced table selection API - our new feature!
-		restaurantRoutes.GET("/:restaurantId/tables/available", utilities.UserRequired(authGroups, "Customer", "all"), handlers.GetAvailableTables(db, router))
+		restaurantRoutes.GET("/:restaurantId/tables/available", utilities.RequirePermission("restaurant:read"), handlers.GetAvailableTables(db, router))
+
+		// Reservation booking. Creation endpoints replay their first response to retries with the same Idempotency-Key.
+		restaurantRoutes.POST("/:restaurantId/reservations", utilities.RequirePermission("reservation:book"), utilities.Idempotent(), handlers.CreateReservation(db, router))
+		restaurantRoutes.PATCH("/:restaurantId/reservations/:reservationId", utilities.RequirePermission("reservation:book"), handlers.UpdateReservation(db, router))
+		restaurantRoutes.DELETE("/:restaurantId/reservations/:reservationId", utilities.RequirePermission("reservation:book"), handlers.CancelReservation(db, router))
+
+		// Menu management
+		restaurantRoutes.GET("/:restaurantId/menu", utilities.RequirePermission("restaurant:read"), handlers.GetMenu(db, router))
+		restaurantRoutes.GET("/:restaurantId/menu/search", utilities.RequirePermission("restaurant:read"), handlers.SearchMenu(db, router))
+		restaurantRoutes.POST("/:restaurantId/menu/sections", utilities.RequirePermission("menu:edit"), managementRequired, handlers.CreateMenuSection(db, router))
+		restaurantRoutes.PUT("/:restaurantId/menu/sections/order", utilities.RequirePermission("menu:edit"), managementRequired, handlers.ReorderMenuSections(db, router))
+		restaurantRoutes.PUT("/:restaurantId/menu/sections/:sectionId", utilities.RequirePermission("menu:edit"), managementRequired, handlers.UpdateMenuSection(db, router))
+		restaurantRoutes.DELETE("/:restaurantId/menu/sections/:sectionId", utilities.RequirePermission("menu:edit"), managementRequired, handlers.DeleteMenuSection(db, router))
+		restaurantRoutes.PUT("/:restaurantId/menu/sections/:sectionId/items/order", utilities.RequirePermission("menu:edit"), managementRequired, handlers.ReorderMenuItems(db, router))
+		restaurantRoutes.POST("/:restaurantId/menu/items", utilities.RequirePermission("menu:edit"), managementRequired, handlers.CreateMenuItem(db, router))
+		restaurantRoutes.PUT("/:restaurantId/menu/items/:menuId", utilities.RequirePermission("menu:edit"), managementRequired, handlers.UpdateMenuItem(db, router))
+		restaurantRoutes.DELETE("/:restaurantId/menu/items/:menuId", utilities.RequirePermission("menu:edit"), managementRequired, handlers.DeleteMenuItem(db, router))
+		restaurantRoutes.PUT("/:restaurantId/menu/items/:menuId/availability", utilities.RequirePermission("menu:edit"), managementRequired, handlers.SetMenuItemAvailability(db, router))
+		restaurantRoutes.POST("/:restaurantId/menu/modifier-groups", utilities.RequirePermission("menu:edit"), managementRequired, handlers.CreateModifierGroup(db, router))
+		restaurantRoutes.PUT("/:restaurantId/menu/modifier-groups/:groupId", utilities.RequirePermission("menu:edit"), managementRequired, handlers.UpdateModifierGroup(db, router))
+		restaurantRoutes.DELETE("/:restaurantId/menu/modifier-groups/:groupId", utilities.RequirePermission("menu:edit"), managementRequired, handlers.DeleteModifierGroup(db, router))
+
+		// Orders placed against a reservation
+		restaurantRoutes.POST("/:restaurantId/orders", utilities.RequirePermission("order:place"), verifiedEmailRequired, utilities.Idempotent(), handlers.CreateOrder(db, router))
+		restaurantRoutes.GET("/:restaurantId/orders", utilities.RequirePermission("order:place"), handlers.GetOrders(db, router))
+		restaurantRoutes.GET("/:restaurantId/orders/:orderId", utilities.RequirePermission("order:place"), handlers.GetOrder(db, router))
+		restaurantRoutes.POST("/:restaurantId/orders/:orderId/items", utilities.RequirePermission("order:place"), verifiedEmailRequired, utilities.Idempotent(), handlers.AddOrderItems(db, router))
+		restaurantRoutes.DELETE("/:restaurantId/orders/:orderId/items/:itemId", utilities.RequirePermission("order:place"), handlers.RemoveOrderItem(db, router))
+		restaurantRoutes.PATCH("/:restaurantId/orders/:orderId/status", utilities.RequirePermission("order:place"), handlers.UpdateOrderStatus(db, router))
+		restaurantRoutes.POST("/:restaurantId/orders/:orderId/payments", utilities.RequirePermission("order:place"), verifiedEmailRequired, utilities.Idempotent(), handlers.CreateOrderPayment(db, router))
+		restaurantRoutes.GET("/:restaurantId/orders/:orderId/payments/:paymentId", utilities.RequirePermission("order:place"), handlers.GetOrderPayment(db, router))
+		restaurantRoutes.POST("/:restaurantId/orders/:orderId/payments/:paymentId/confirm", utilities.RequirePermission("order:place"), verifiedEmailRequired, utilities.Idempotent(), handlers.ConfirmOrderPayment(db, router))
+
+		// Kitchen display
+		restaurantRoutes.GET("/:restaurantId/kitchen/stream", utilities.RequirePermission("kitchen:use"), anyRoleRequired, handlers.StreamKitchenEvents(db, router))
+		restaurantRoutes.PATCH("/:restaurantId/kitchen/items/:itemId", utilities.RequirePermission("kitchen:use"), anyRoleRequired, handlers.UpdateOrderItemStatus(db, router))
+		restaurantRoutes.PUT("/:restaurantId/kitchen/menu/:menuId/availability", utilities.RequirePermission("kitchen:use"), kitchenRequired, handlers.SetMenuItemAvailability(db, router))
+
+		// Staff memberships and roles
+		restaurantRoutes.GET("/:restaurantId/staff", utilities.RequirePermission("staff:manage"), managementRequired, handlers.GetRestaurantStaff(db, router))
+		restaurantRoutes.POST("/:restaurantId/staff", utilities.RequirePermission("staff:manage"), managementRequired, handlers.AddRestaurantStaff(db, router))
+		restaurantRoutes.PATCH("/:restaurantId/staff/:staffId", utilities.RequirePermission("staff:manage"), managementRequired, handlers.UpdateRestaurantStaff(db, router))
+		restaurantRoutes.DELETE("/:restaurantId/staff/:staffId", utilities.RequirePermission("staff:manage"), managementRequired, handlers.RemoveRestaurantStaff(db, router))
+
+		// Opening hours, service periods and holiday closures
+		restaurantRoutes.GET("/:restaurantId/hours", utilities.RequirePermission("restaurant:read"), handlers.GetRestaurantHours(db, router))
+		restaurantRoutes.PUT("/:restaurantId/hours", utilities.RequirePermission("hours:edit"), managementRequired, handlers.ReplaceOpeningHours(db, router))
+		restaurantRoutes.POST("/:restaurantId/hours/service-periods", utilities.RequirePermission("hours:edit"), managementRequired, handlers.CreateServicePeriod(db, router))
+		restaurantRoutes.PUT("/:restaurantId/hours/service-periods/:periodId", utilities.RequirePermission("hours:edit"), managementRequired, handlers.UpdateServicePeriod(db, router))
+		restaurantRoutes.DELETE("/:restaurantId/hours/service-periods/:periodId", utilities.RequirePermission("hours:edit"), managementRequired, handlers.DeleteServicePeriod(db, router))
+		restaurantRoutes.POST("/:restaurantId/hours/exceptions", utilities.RequirePermission("hours:edit"), managementRequired, handlers.CreateHoursException(db, router))
+		restaurantRoutes.PUT("/:restaurantId/hours/exceptions/:exceptionId", utilities.RequirePermission("hours:edit"), managementRequired, handlers.UpdateHoursException(db, router))
+		restaurantRoutes.DELETE("/:restaurantId/hours/exceptions/:exceptionId", utilities.RequirePermission("hours:edit"), managementRequired, handlers.DeleteHoursException(db, router))
 	}
 }
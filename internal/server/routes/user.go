@@ -8,6 +8,7 @@ package routes
 
 import (
 	"waitress-backend/internal/handlers"
+	"waitress-backend/internal/utilities"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -17,9 +18,10 @@ func UserRoutes(router *gin.Engine, db *gorm.DB) {
 	user := router.Group("api/users")
 	{
 		user.POST("/create", handlers.CreateUser(db))
-		user.POST("/get", handlers.GetUser(db))
-		user.POST("/update-user-location", handlers.UpdateUserLocation(db))
-		user.POST("/update-account-info", handlers.UpdateUserAccountInformation(db))
+		user.GET("", utilities.RequirePermission("user:list"), handlers.ListUsers(db))
+		user.POST("/update-user-location", utilities.RequirePermission("account:manage"), handlers.UpdateUserLocation(db))
+		user.POST("/update-account-info", utilities.RequirePermission("account:manage"), handlers.UpdateUserAccountInformation(db))
+		user.POST("/:userId/unlock-login", utilities.RequirePermission("user:unlock"), handlers.UnlockLogin(db, router))
 		// user.POST("/", handlers.CreateUser)
 		// user.GET("/:id", handlers.GetUser)
 		// user.PUT("/:id", handlers.UpdateUser)
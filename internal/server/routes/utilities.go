@@ -15,13 +15,11 @@ import (
 
 // UtilitiesRoutes sets up the routes for the utilities endpoints
 func UtilitiesRoutes(router *gin.Engine, db *gorm.DB) {
-	userGroups := utilities.NewUserGroups()           // Initialize your user groups
-	authGroups := utilities.NewAuthGroups(userGroups) // Create the auth groups from user groups
 	database := router.Group("api/db")
 	{
-		database.GET("/seed", utilities.UserRequired(authGroups, "Dev", "all"), handlers.Seed(db))
-		database.GET("/seedd", handlers.Seed(db))
-		database.GET("/run-all", handlers.RunAll(db))
-		database.GET("/migrate", handlers.MigrateDb(db))
+		// Migrating changes and drops columns, so it is as restricted as seeding
+		database.GET("/seed", utilities.RequirePermission("database:seed"), handlers.Seed(db))
+		database.GET("/run-all", utilities.RequirePermission("database:seed"), handlers.RunAll(db))
+		database.GET("/migrate", utilities.RequirePermission("database:seed"), handlers.MigrateDb(db))
 	}
 }
@@ -9,8 +9,12 @@ import (
 	"os"
 	"strconv"
 	"time"
+	"waitress-backend/internal/handlers"
+	"waitress-backend/internal/mail"
 	"waitress-backend/internal/models"
+	"waitress-backend/internal/payments"
 	"waitress-backend/internal/server/routes"
+	"waitress-backend/internal/utilities"
 
 	"github.com/gin-contrib/cors"
 	gormsessions "github.com/gin-contrib/sessions/gorm"
@@ -29,15 +33,24 @@ type Server struct {
 	router *gin.Engine // Add the Gin Engine to the Server struct
 }
 
-// NewServer initializes the server and the Gin Engine
-func NewServer() *http.Server {
-	port, _ := strconv.Atoi(os.Getenv("PORT")) // Port for the server
-	db, err := gorm.Open(mysql.Open(os.Getenv("DSN")), &gorm.Config{ // Database connection
+// OpenDatabase connects to the database named by the DSN environment variable
+func OpenDatabase() (*gorm.DB, error) {
+	return gorm.Open(mysql.Open(os.Getenv("DSN")), &gorm.Config{ // Database connection
 		// Logger: logger.Default.LogMode(logger.Info),
 		NamingStrategy: schema.NamingStrategy{
 			SingularTable: true,
 		},
+		// Store every timestamp in UTC; restaurant-local times are derived from Restaurant.TimeZone
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
 	})
+}
+
+// NewServer initializes the server and the Gin Engine
+func NewServer() *http.Server {
+	port, _ := strconv.Atoi(os.Getenv("PORT")) // Port for the server
+	db, err := OpenDatabase()
 	if err != nil {
 		log.Fatalf("failed to connect to database: %v", err)
 	}
@@ -52,8 +65,8 @@ func NewServer() *http.Server {
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:3000", "http://127.0.0.1:5173", "http://localhost:5173"}, // Update with your frontend URL
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * 60 * 60, // Preflight cache duration in seconds (12 hours)
 	}))
@@ -72,6 +85,63 @@ func NewServer() *http.Server {
 		router: router,  // Initialize the Gin Engine here
 	}
 
+	// Keys for signing and verifying JWTs
+	keys, err := utilities.LoadKeySetFromEnv()
+	if err != nil {
+		log.Fatalf("failed to load JWT signing keys: %v", err)
+	}
+	utilities.SetSigningKeys(keys)
+
+	// Permissions checked by the routes, which fail to register if the policy lacks one
+	policy, err := utilities.LoadPolicyFromEnv()
+	if err != nil {
+		log.Fatalf("failed to load authorization policy: %v", err)
+	}
+	utilities.SetPolicy(policy)
+
+	// Bearer tokens revoked on logout or refresh token reuse are rejected by RequirePermission
+	utilities.TokenRevocations = utilities.NewDBTokenRevocationStore(db)
+
+	// Responses replayed to retried POSTs are shared by every server instance
+	utilities.IdempotencyKeys = utilities.NewDBIdempotencyStore(db)
+
+	// Nonces of signed client requests are shared too, so a request cannot be replayed on another instance
+	utilities.ClientNonces = utilities.NewDBClientNonceStore(db)
+
+	// Failed logins are counted across instances, so guessing cannot be spread over them
+	utilities.LoginLimiter = utilities.NewLoginThrottle(utilities.NewDBLimiterStore(db))
+
+	// Auth types that must log in with a TOTP code, e.g. "dev,admin_super,admin"
+	requiredRoles, err := utilities.ParseUserTypes(os.Getenv("TWO_FACTOR_REQUIRED_ROLES"))
+	if err != nil {
+		log.Fatalf("invalid TWO_FACTOR_REQUIRED_ROLES: %v", err)
+	}
+	utilities.TwoFactorRequiredRoles = requiredRoles
+
+	// Sender for verification and password reset emails
+	mailer, err := mail.NewSenderFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure mail: %v", err)
+	}
+	mail.SetDefault(mailer)
+
+	// Provider that collects order payments; payments are only simulated when PAYMENTS_PROVIDER=fake
+	provider, err := payments.NewProviderFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure payments: %v", err)
+	}
+	payments.SetDefault(provider)
+
+	// Pending payments whose outcome never arrived by webhook are checked with the provider and flagged
+	pendingThreshold := handlers.PaymentPendingThreshold
+	if value := os.Getenv("PAYMENT_PENDING_THRESHOLD"); value != "" {
+		pendingThreshold, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalf("invalid PAYMENT_PENDING_THRESHOLD: %v", err)
+		}
+	}
+	handlers.StartPaymentReconciliation(db, handlers.PaymentReconcileInterval, pendingThreshold)
+
 	// Setup route groups
 	routes.UserRoutes(newServer.router, db)
 	routes.AuthRoutes(newServer.router, db)
@@ -79,6 +149,8 @@ func NewServer() *http.Server {
 	routes.UtilitiesRoutes(newServer.router, db)
 	routes.CategoryRoutes(newServer.router, db)
 	routes.AdminRoutes(newServer.router, db)
+	routes.ClientRoutes(newServer.router, db)
+	routes.PaymentRoutes(newServer.router, db)
 	// ... include other route groups as needed
 
 	// Configure the HTTP server
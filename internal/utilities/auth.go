@@ -2,17 +2,18 @@
 //
 // The utilities here are as follows:
 // - UserType
-// - UserGroups
-// - AuthGroups
+// - AllUserTypes
 // - CheckPasswordHash
+// - CheckPasswordHashOfNoUser
 // - HashPassword
 // - getClientFromRequest
-// - getAuthTypeFromSession
+// - getIdentityFromSession
+// - authenticateUser
+// - GetAuthenticatedUserID
+// - GetAuthenticatedAuthType
+// - CanActOnUser
+// - userTypeRank
 // - ClientRequired
-// - UserRequired
-// - mergeSets
-// - NewUserGroups
-// - NewAuthGroups
 // - printSessionValues
 
 package utilities
@@ -21,8 +22,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
 	"time"
 	"waitress-backend/internal/models"
 
@@ -32,25 +33,19 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-// JWT secret key for token validation
-var jwtSecretKey = []byte(os.Getenv("JWT_SECRET"))
-
 // JWTClaims represents the claims stored in JWT tokens
 type JWTClaims struct {
 	UserID   uint   `json:"userID"`
 	Email    string `json:"email"`
 	AuthType string `json:"authType"`
+	// SessionID is the refresh token family the token was issued with
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// validateJWTToken verifies and parses a JWT token, returning the claims
+// validateJWTToken verifies a JWT token against the signing key named by its kid, returning the claims
 func validateJWTToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtSecretKey, nil
-	})
+	token, err := SigningKeys().Parse(tokenString, &JWTClaims{})
 
 	if err != nil {
 		return nil, err
@@ -81,19 +76,33 @@ func extractJWTFromHeader(c *gin.Context) (string, error) {
 	return strings.TrimPrefix(authHeader, "Bearer "), nil
 }
 
-// getAuthTypeFromJWT extracts auth type from JWT token
-func getAuthTypeFromJWT(c *gin.Context) (string, error) {
+// getIdentityFromJWT extracts the user ID and auth type from JWT token
+func getIdentityFromJWT(c *gin.Context) (uint, string, error) {
 	tokenString, err := extractJWTFromHeader(c)
 	if err != nil {
-		return "", err
+		return 0, "", err
 	}
 
 	claims, err := validateJWTToken(tokenString)
 	if err != nil {
-		return "", err
+		return 0, "", err
+	}
+
+	if TokenRevocations != nil {
+		revoked, err := TokenRevocations.IsRevoked(claims.ID, claims.SessionID)
+		if err != nil {
+			return 0, "", err
+		}
+		if revoked {
+			return 0, "", ErrTokenRevoked
+		}
+		if claims.IssuedAt == nil || issuedBeforeRevocation(claims.UserID, claims.IssuedAt.Time) {
+			return 0, "", ErrTokenRevoked
+		}
 	}
 
-	return claims.AuthType, nil
+	c.Set(ContextSessionIDKey, claims.SessionID)
+	return claims.UserID, claims.AuthType, nil
 }
 
 // UserType defines the different types of users in the system.
@@ -109,41 +118,8 @@ const (
 	Customer   UserType = "customer"
 )
 
-// UserGroups holds sets of UserType for different groups.
-type UserGroups struct {
-	Dev        map[UserType]struct{}
-	Admin      map[UserType]struct{}
-	Staff      map[UserType]struct{}
-	All        map[UserType]struct{}
-	AllOrdered []UserType
-}
-
-// Transform the map keys into a slice of strings for easier access.
-func (ug *UserGroups) GetAdminTypes() []string {
-	adminTypes := make([]string, 0, len(ug.Admin))
-	for k := range ug.Admin {
-		adminTypes = append(adminTypes, string(k))
-	}
-	return adminTypes
-}
-
-// Transform the map keys into a slice of strings for easier access.
-func (ug *UserGroups) GetStaffTypes() []string {
-	staffTypes := make([]string, 0, len(ug.Staff))
-	for k := range ug.Staff {
-		staffTypes = append(staffTypes, string(k))
-	}
-	return staffTypes
-}
-
-// AuthGroups defines the permission hierarchy for each user type.
-type AuthGroups struct {
-	Dev      map[string]map[UserType]struct{}
-	Admin    map[string]map[UserType]struct{}
-	Staff    map[string]map[UserType]struct{}
-	Customer map[string]map[UserType]struct{}
-	All      map[UserType]struct{}
-}
+// AllUserTypes lists every user type, most privileged first
+var AllUserTypes = []UserType{Dev, AdminSuper, Admin, StaffSuper, Staff, Customer}
 
 // printSessionValues prints the values stored in the session for debugging purposes.
 func printSessionValues(c *gin.Context) {
@@ -155,58 +131,24 @@ func printSessionValues(c *gin.Context) {
 	fmt.Println("User AuthType in session:", userAuthType)
 }
 
-// NewUserGroups initializes and returns a new UserGroups with predefined user types.
-func NewUserGroups() UserGroups {
-	allUsers := map[UserType]struct{}{
-		Dev: {}, AdminSuper: {}, Admin: {}, StaffSuper: {}, Staff: {}, Customer: {},
-	}
-	return UserGroups{
-		Dev:        map[UserType]struct{}{Dev: {}},
-		Admin:      map[UserType]struct{}{AdminSuper: {}, Admin: {}},
-		Staff:      map[UserType]struct{}{StaffSuper: {}, Staff: {}},
-		All:        allUsers,
-		AllOrdered: []UserType{Dev, AdminSuper, Admin, StaffSuper, Staff, Customer},
-	}
-}
-
-// NewAuthGroups initializes and returns a new AuthGroups with permission hierarchies.
-func NewAuthGroups(ug UserGroups) AuthGroups {
-	return AuthGroups{
-		Dev: map[string]map[UserType]struct{}{
-			"all": ug.Dev,
-		},
-		Admin: map[string]map[UserType]struct{}{
-			"super": mergeSets(ug.Dev, map[UserType]struct{}{AdminSuper: {}}),
-			"all":   mergeSets(ug.Dev, ug.Admin),
-		},
-		Staff: map[string]map[UserType]struct{}{
-			"super": mergeSets(mergeSets(ug.Dev, ug.Admin), map[UserType]struct{}{StaffSuper: {}}),
-			"all":   mergeSets(mergeSets(ug.Dev, ug.Admin), ug.Staff),
-		},
-		Customer: map[string]map[UserType]struct{}{
-			"all": mergeSets(mergeSets(mergeSets(ug.Dev, ug.Admin), ug.Staff), ug.All),
-		},
-		All: ug.All,
-	}
-}
-
-// mergeSets combines multiple sets of UserType into a single set.
-func mergeSets(sets ...map[UserType]struct{}) map[UserType]struct{} {
-	result := make(map[UserType]struct{})
-	for _, set := range sets {
-		for key := range set {
-			result[key] = struct{}{}
-		}
-	}
-	return result
-}
-
 // CheckPasswordHash compares a password with its hash and returns true if they match.
 func CheckPasswordHash(password, hash string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
 
+// dummyPasswordHash is compared against when there is no user, so that an unknown email takes as long
+// to reject as a wrong password
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("not the password of any user")
+	return hash
+})
+
+// CheckPasswordHashOfNoUser spends the time of a password check without a user to check against.
+func CheckPasswordHashOfNoUser(password string) {
+	CheckPasswordHash(password, dummyPasswordHash())
+}
+
 // HashPassword generates a hashed password from a plaintext password.
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
@@ -223,17 +165,86 @@ func getClientFromRequest(c *gin.Context) (models.APIClient, error) {
 	return client, nil
 }
 
-// getAuthTypeFromSession retrieves the auth type from the Gin session.
-func getAuthTypeFromSession(c *gin.Context) (string, error) {
+// getIdentityFromSession retrieves the user ID and auth type from the Gin session.
+func getIdentityFromSession(c *gin.Context) (uint, string, error) {
 	session := sessions.Default(c)
 	authType, ok := session.Get("authType").(string)
 	if !ok || authType == "" {
-		return "", errors.New("auth type not found in session")
+		return 0, "", errors.New("auth type not found in session")
 	}
-	return authType, nil
+	userID, ok := session.Get("userID").(uint)
+	if !ok {
+		return 0, "", errors.New("user ID not found in session")
+	}
+	if TokenRevocations != nil {
+		loggedInAt, _ := session.Get("loggedInAt").(int64)
+		if issuedBeforeRevocation(userID, time.Unix(loggedInAt, 0)) {
+			return 0, "", errors.New("session has been revoked")
+		}
+	}
+	return userID, authType, nil
 }
 
-// DEPRECATED: Use UserRequired instead. This will be updated to handle both client and user authentication.
+// Context keys under which RequirePermission stores the authenticated caller.
+const (
+	ContextUserIDKey    = "authUserID"
+	ContextAuthTypeKey  = "authType"
+	ContextSessionIDKey = "authSessionID" // Refresh token family of a bearer token
+)
+
+// issuedBeforeRevocation reports whether a token or session issued at issuedAt predates the user
+// signing out everywhere. Lookup errors are treated as revoked.
+func issuedBeforeRevocation(userID uint, issuedAt time.Time) bool {
+	revokedAt, err := TokenRevocations.UserTokensRevokedAt(userID)
+	if err != nil {
+		fmt.Println("Error checking token revocation:", err)
+		return true
+	}
+	// Token issue times have second precision
+	return revokedAt != nil && issuedAt.Before(revokedAt.Truncate(time.Second))
+}
+
+// GetAuthenticatedUserID returns the ID of the user authenticated by RequirePermission.
+func GetAuthenticatedUserID(c *gin.Context) (uint, bool) {
+	userID, ok := c.Get(ContextUserIDKey)
+	if !ok {
+		return 0, false
+	}
+	id, ok := userID.(uint)
+	return id, ok && id != 0
+}
+
+// CanActOnUser reports whether the authenticated user may change the account of userID, whose auth type is
+// userType: their own, or with the user:manage permission anyone's whose type does not rank above theirs.
+func CanActOnUser(c *gin.Context, userID uint, userType string) bool {
+	callerID, ok := GetAuthenticatedUserID(c)
+	if !ok {
+		return false
+	}
+	if callerID == userID {
+		return true
+	}
+	authType := GetAuthenticatedAuthType(c)
+	return HasPermission(authType, PermissionUserManage) && userTypeRank(userType) >= userTypeRank(authType)
+}
+
+// userTypeRank returns a user type's position in AllUserTypes, so lower ranks are more privileged.
+// Unknown types rank below every known one.
+func userTypeRank(userType string) int {
+	for i, t := range AllUserTypes {
+		if string(t) == userType {
+			return i
+		}
+	}
+	return len(AllUserTypes)
+}
+
+// GetAuthenticatedAuthType returns the auth type of the user authenticated by RequirePermission.
+func GetAuthenticatedAuthType(c *gin.Context) string {
+	return c.GetString(ContextAuthTypeKey)
+}
+
+// DEPRECATED: Use RequirePermission for users and ClientSignatureRequired for API clients.
 // Gin middleware that ensures the request is made by an authorized client.
 func ClientRequired(clientTypes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -264,63 +275,25 @@ func ClientRequired(clientTypes ...string) gin.HandlerFunc {
 	}
 }
 
-// Gin middleware that ensures the request is made by an authorized user.
-// Supports both JWT token authentication (mobile) and session authentication (web)
-func UserRequired(authGroups AuthGroups, group, subgroup string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var authType string
-		var err error
-
-		// Try JWT authentication first (for mobile apps)
-		authType, err = getAuthTypeFromJWT(c)
+// authenticateUser identifies the caller by their bearer token (mobile) or session (web). It responds
+// and aborts the request when neither authenticates them.
+func authenticateUser(c *gin.Context) (uint, string, bool) {
+	// Try JWT authentication first (for mobile apps)
+	userID, authType, err := getIdentityFromJWT(c)
+	if errors.Is(err, ErrTokenRevoked) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+		c.Abort()
+		return 0, "", false
+	}
+	if err != nil {
+		// JWT authentication failed, try session authentication (for web)
+		userID, authType, err = getIdentityFromSession(c)
 		if err != nil {
-			// JWT authentication failed, try session authentication (for web)
-			authType, err = getAuthTypeFromSession(c)
-			if err != nil {
-				fmt.Println("Both JWT and session authentication failed:", err)
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
-				c.Abort()
-				return
-			}
-			fmt.Println("Authenticated via session with auth type:", authType)
-		} else {
-			fmt.Println("Authenticated via JWT with auth type:", authType)
-		}
-
-		var allowedUsers map[UserType]struct{}
-		var ok bool
-
-		switch group {
-		case "Dev":
-			allowedUsers, ok = authGroups.Dev[subgroup]
-		case "Admin":
-			allowedUsers, ok = authGroups.Admin[subgroup]
-		case "Staff":
-			allowedUsers, ok = authGroups.Staff[subgroup]
-		case "Customer":
-			allowedUsers, ok = authGroups.Customer[subgroup]
-		default:
-			fmt.Println("Invalid user group:", group)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user group"})
-			c.Abort()
-			return
-		}
-
-		if !ok {
-			fmt.Println("Invalid subgroup configuration:", subgroup)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid subgroup configuration"})
+			fmt.Println("Both JWT and session authentication failed:", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
 			c.Abort()
-			return
+			return 0, "", false
 		}
-
-		if _, permitted := allowedUsers[UserType(authType)]; !permitted {
-			fmt.Println("User type not permitted:", authType)
-			c.JSON(http.StatusForbidden, gin.H{"error": "User type not permitted"})
-			c.Abort()
-			return
-		}
-
-		fmt.Println("User is permitted, proceeding with auth type:", authType)
-		c.Next()
 	}
+	return userID, authType, true
 }
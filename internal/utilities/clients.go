@@ -0,0 +1,403 @@
+// This file contains utilities for authenticating API clients by request signature
+//
+// The utilities here are as follows:
+// - SignClientRequest
+// - ClientNonceStore
+// - MemoryClientNonceStore
+// - NewMemoryClientNonceStore
+// - DBClientNonceStore
+// - NewDBClientNonceStore
+// - VerifyClientSignature
+// - ClientSignatureRequired
+// - ClientSignatureOptional
+// - GetAuthenticatedClient
+// - ValidateAPIClient
+// - CreateAPIClient
+// - ListAPIClients
+// - RotateAPIClientSecret
+// - RevokeAPIClient
+
+package utilities
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"waitress-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Headers carrying a client's request signature
+const (
+	HeaderClientID        = "X-Client-ID"
+	HeaderClientTimestamp = "X-Client-Timestamp"
+	HeaderClientNonce     = "X-Client-Nonce"
+	HeaderClientSignature = "X-Client-Signature"
+)
+
+// ClientSignatureWindow is how far a request's timestamp may be from the server clock.
+// Older requests are rejected as replays; within the window each nonce is accepted once.
+const ClientSignatureWindow = 5 * time.Minute
+
+// ClientSecretGracePeriod is how long after a rotation the previous secret is still accepted
+const ClientSecretGracePeriod = 24 * time.Hour
+
+// Largest request body that is read to verify a signature
+const maxSignedBodyBytes = 10 << 20
+
+// LastUsedAt is only written when it is older than this, to avoid a write on every request
+const clientLastUsedResolution = time.Minute
+
+// ContextClientKey is where the client signature middleware stores the authenticated APIClient
+const ContextClientKey = "apiClient"
+
+// Errors returned by VerifyClientSignature
+var (
+	ErrClientSignatureMissing = errors.New("client signature headers are missing")
+	ErrClientUnknown          = errors.New("unknown client")
+	ErrClientRevoked          = errors.New("client access has been revoked")
+	ErrClientSignatureExpired = errors.New("client request timestamp is outside the allowed window")
+	ErrClientSignatureReplay  = errors.New("client request nonce has already been used")
+	ErrClientSignatureInvalid = errors.New("client signature is invalid")
+)
+
+// ErrClientNotFound is returned when managing a client that does not exist
+var ErrClientNotFound = errors.New("client not found")
+
+// clientSigningString is the canonical form of a request that clients sign
+func clientSigningString(clientID, timestamp, nonce, method, path string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	return strings.Join([]string{clientID, timestamp, nonce, strings.ToUpper(method), path, hex.EncodeToString(bodyHash[:])}, "\n")
+}
+
+// SignClientRequest returns the hex encoded HMAC-SHA256 signature of a request. path includes the
+// query string, e.g. "/api/auth/login?remember=1".
+func SignClientRequest(secret, clientID, timestamp, nonce, method, path string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(clientSigningString(clientID, timestamp, nonce, method, path, body)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// clientSecrets returns the secrets a client may currently sign with. The previous secret is
+// accepted for ClientSecretGracePeriod after a rotation.
+func clientSecrets(client models.APIClient, now time.Time) []string {
+	secrets := []string{client.Secret}
+	if client.PreviousSecret != nil && *client.PreviousSecret != "" && client.LastSecretRotation != nil &&
+		now.Before(client.LastSecretRotation.Add(ClientSecretGracePeriod)) {
+		secrets = append(secrets, *client.PreviousSecret)
+	}
+	return secrets
+}
+
+// ClientNonceStore remembers the nonces used within the signature window. Use must be atomic.
+type ClientNonceStore interface {
+	// Use records a nonce until expiresAt, reporting false when it is already recorded
+	Use(key string, expiresAt, now time.Time) (bool, error)
+}
+
+// ClientNonces is the store used by VerifyClientSignature. The server replaces it with a
+// DBClientNonceStore, so that a request cannot be replayed against another instance.
+var ClientNonces ClientNonceStore = NewMemoryClientNonceStore()
+
+// MemoryClientNonceStore is a ClientNonceStore local to this server instance
+type MemoryClientNonceStore struct {
+	mu     sync.Mutex
+	seen   map[string]time.Time
+	pruned time.Time
+}
+
+// NewMemoryClientNonceStore creates an empty in-memory store
+func NewMemoryClientNonceStore() *MemoryClientNonceStore {
+	return &MemoryClientNonceStore{seen: make(map[string]time.Time)}
+}
+
+// Use records a nonce, reporting false when it is already recorded
+func (s *MemoryClientNonceStore) Use(key string, expiresAt, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.pruned) > ClientSignatureWindow {
+		for k, expires := range s.seen {
+			if now.After(expires) {
+				delete(s.seen, k)
+			}
+		}
+		s.pruned = now
+	}
+	if expires, ok := s.seen[key]; ok && now.Before(expires) {
+		return false, nil
+	}
+	s.seen[key] = expiresAt
+	return true, nil
+}
+
+// DBClientNonceStore keeps nonces in the client_nonce table so every server instance sees them
+type DBClientNonceStore struct {
+	db *gorm.DB
+}
+
+// NewDBClientNonceStore creates a nonce store backed by the database
+func NewDBClientNonceStore(db *gorm.DB) *DBClientNonceStore {
+	return &DBClientNonceStore{db: db}
+}
+
+// Use inserts a nonce unless it is already recorded, clearing out nonces that have expired
+func (s *DBClientNonceStore) Use(key string, expiresAt, now time.Time) (bool, error) {
+	if err := s.db.Where("expires_at <= ?", now).Delete(&models.ClientNonce{}).Error; err != nil {
+		return false, err
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ClientNonce{NonceHash: key, ExpiresAt: expiresAt, CreatedAt: now})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// clientNonceKey identifies a client's nonce in a ClientNonceStore
+func clientNonceKey(clientID, nonce string) string {
+	sum := sha256.Sum256([]byte(clientID + "\n" + nonce))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyClientSignature authenticates the client that signed a request and returns it.
+// The request body is read and replaced so handlers can still bind it.
+func VerifyClientSignature(db *gorm.DB, r *http.Request, now time.Time) (*models.APIClient, error) {
+	clientID := r.Header.Get(HeaderClientID)
+	timestamp := r.Header.Get(HeaderClientTimestamp)
+	nonce := r.Header.Get(HeaderClientNonce)
+	signature := r.Header.Get(HeaderClientSignature)
+	if clientID == "" || timestamp == "" || nonce == "" || signature == "" {
+		return nil, ErrClientSignatureMissing
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrClientSignatureExpired
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > ClientSignatureWindow || skew < -ClientSignatureWindow {
+		return nil, ErrClientSignatureExpired
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBodyBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > maxSignedBodyBytes {
+			return nil, fmt.Errorf("request body is larger than %d bytes", maxSignedBodyBytes)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	var candidates []models.APIClient
+	if err := db.Where("public_uid = ?", clientID).Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, ErrClientUnknown
+	}
+
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return nil, ErrClientSignatureInvalid
+	}
+	for i := range candidates {
+		client := candidates[i]
+		for _, secret := range clientSecrets(client, now) {
+			expected, _ := hex.DecodeString(SignClientRequest(secret, clientID, timestamp, nonce, r.Method, r.URL.RequestURI(), body))
+			if !hmac.Equal(expected, given) {
+				continue
+			}
+			if client.AccessRevoked != nil && !now.Before(*client.AccessRevoked) {
+				return nil, ErrClientRevoked
+			}
+			fresh, err := ClientNonces.Use(clientNonceKey(clientID, nonce), now.Add(2*ClientSignatureWindow), now)
+			if err != nil {
+				return nil, err
+			}
+			if !fresh {
+				return nil, ErrClientSignatureReplay
+			}
+			if client.LastUsedAt == nil || now.Sub(*client.LastUsedAt) > clientLastUsedResolution {
+				if err := db.Model(&client).UpdateColumn("last_used_at", now).Error; err != nil {
+					fmt.Println("Error recording client use:", err)
+				}
+			}
+			return &client, nil
+		}
+	}
+	return nil, ErrClientSignatureInvalid
+}
+
+// respondClientSignatureError maps a failed signature check to an HTTP response
+func respondClientSignatureError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrClientRevoked):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrClientSignatureMissing), errors.Is(err, ErrClientUnknown),
+		errors.Is(err, ErrClientSignatureExpired), errors.Is(err, ErrClientSignatureReplay),
+		errors.Is(err, ErrClientSignatureInvalid):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		fmt.Println("Error verifying client signature:", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Client request could not be verified"})
+	}
+	c.Abort()
+}
+
+// clientTypePermitted reports whether the client's type is one of clientTypes, or clientTypes is empty
+func clientTypePermitted(client *models.APIClient, clientTypes []string) bool {
+	if len(clientTypes) == 0 {
+		return true
+	}
+	for _, t := range clientTypes {
+		if client.ClientType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Gin middleware that ensures the request is signed by an API client, optionally of one of clientTypes
+func ClientSignatureRequired(db *gorm.DB, clientTypes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client, err := VerifyClientSignature(db, c.Request, time.Now())
+		if err != nil {
+			respondClientSignatureError(c, err)
+			return
+		}
+		if !clientTypePermitted(client, clientTypes) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Client type not permitted"})
+			c.Abort()
+			return
+		}
+		c.Set(ContextClientKey, client)
+		c.Next()
+	}
+}
+
+// Gin middleware that verifies a client signature when the request carries one. Unsigned requests,
+// such as those from the first-party web app, pass through; badly signed ones are rejected.
+func ClientSignatureOptional(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader(HeaderClientID) == "" {
+			c.Next()
+			return
+		}
+		client, err := VerifyClientSignature(db, c.Request, time.Now())
+		if err != nil {
+			respondClientSignatureError(c, err)
+			return
+		}
+		c.Set(ContextClientKey, client)
+		c.Next()
+	}
+}
+
+// GetAuthenticatedClient returns the API client authenticated by the client signature middleware
+func GetAuthenticatedClient(c *gin.Context) (*models.APIClient, bool) {
+	value, ok := c.Get(ContextClientKey)
+	if !ok {
+		return nil, false
+	}
+	client, ok := value.(*models.APIClient)
+	return client, ok && client != nil
+}
+
+// ValidateAPIClient checks the name and type of a client being registered
+func ValidateAPIClient(name, clientType string) error {
+	if strings.TrimSpace(name) == "" || len(name) > 32 {
+		return errors.New("name is required and must be at most 32 characters")
+	}
+	if strings.TrimSpace(clientType) == "" || len(clientType) > 32 {
+		return errors.New("clientType is required and must be at most 32 characters")
+	}
+	return nil
+}
+
+// CreateAPIClient registers a client. The returned client holds its generated secret, which is
+// only ever shown to the caller at this point.
+func CreateAPIClient(db *gorm.DB, name, clientType string) (*models.APIClient, error) {
+	if err := ValidateAPIClient(name, clientType); err != nil {
+		return nil, err
+	}
+	client := models.APIClient{Name: strings.TrimSpace(name), ClientType: strings.TrimSpace(clientType)}
+	if err := db.Create(&client).Error; err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// ListAPIClients returns every registered client
+func ListAPIClients(db *gorm.DB) ([]models.APIClient, error) {
+	var clients []models.APIClient
+	err := db.Order("id").Find(&clients).Error
+	return clients, err
+}
+
+// lockAPIClient loads a client for update
+func lockAPIClient(tx *gorm.DB, id uint) (*models.APIClient, error) {
+	var client models.APIClient
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&client).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrClientNotFound
+	}
+	return &client, err
+}
+
+// RotateAPIClientSecret gives a client a new secret and returns it. The old secret becomes the
+// PreviousSecret and keeps working for ClientSecretGracePeriod.
+func RotateAPIClientSecret(db *gorm.DB, id uint, now time.Time) (*models.APIClient, string, error) {
+	var client *models.APIClient
+	var secret string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if client, err = lockAPIClient(tx, id); err != nil {
+			return err
+		}
+		if secret, err = client.RotateSecret(now); err != nil {
+			return err
+		}
+		return tx.Model(client).Updates(map[string]interface{}{
+			"secret":               client.Secret,
+			"previous_secret":      client.PreviousSecret,
+			"last_secret_rotation": client.LastSecretRotation,
+		}).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return client, secret, nil
+}
+
+// RevokeAPIClient stops a client from authenticating from now on
+func RevokeAPIClient(db *gorm.DB, id uint, now time.Time) (*models.APIClient, error) {
+	var client *models.APIClient
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if client, err = lockAPIClient(tx, id); err != nil {
+			return err
+		}
+		if client.AccessRevoked != nil && !now.Before(*client.AccessRevoked) {
+			return nil // Already revoked
+		}
+		client.AccessRevoked = &now
+		return tx.Model(client).Update("access_revoked", now).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
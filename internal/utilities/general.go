@@ -6,6 +6,8 @@
 // - ValidateTableFilters
 // - BuildTableQuery
 // - CheckTableAvailability
+// - CheckMultipleTablesAvailability
+// - LocalizeAvailability
 
 package utilities
 
@@ -29,15 +31,21 @@ type TableFilterParams struct {
 	MinCapacity int    `form:"minCapacity"` // minimum party size
 	MaxCapacity int    `form:"maxCapacity"` // maximum party size
 	Available   *bool  `form:"available"`   // filter by availability status
+	At          string `form:"at"`          // start of the requested window, RFC 3339 or restaurant-local, defaults to now
+	Duration    uint   `form:"duration"`    // length of the requested window in minutes
+	PartySize   uint   `form:"partySize"`   // tables must seat at least this many guests
+
+	Window ReservationWindow `form:"-"` // parsed from At and Duration by ValidateTableFilters
 }
 
 // TableAvailabilityStatus represents the detailed availability state of a table
 type TableAvailabilityStatus struct {
-	IsTableActive      bool       `json:"isTableActive"`               // Table exists and is operational
-	IsCurrentlyFree    bool       `json:"isCurrentlyFree"`             // Not currently reserved
-	NextAvailableTime  *time.Time `json:"nextAvailableTime,omitempty"` // When table becomes available (future use)
-	ReservationID      *uint      `json:"reservationId,omitempty"`     // Current reservation if any
-	AvailabilityReason string     `json:"reason"`                      // Human-readable explanation
+	IsTableActive      bool       `json:"isTableActive"`                    // Table exists and is operational
+	IsCurrentlyFree    bool       `json:"isCurrentlyFree"`                  // Free for the whole requested window
+	NextAvailableTime  *time.Time `json:"nextAvailableTime,omitempty"`      // Earliest start at which the requested duration fits
+	NextAvailableLocal *string    `json:"nextAvailableLocalTime,omitempty"` // NextAvailableTime as restaurant-local RFC 3339
+	ReservationID      *uint      `json:"reservationId,omitempty"`          // Reservation blocking the requested window if any
+	AvailabilityReason string     `json:"reason"`                           // Human-readable explanation
 }
 
 // Valid options for table filtering
@@ -45,8 +53,9 @@ var ValidTableZones = []string{"inside", "outside", "patio", "bar"}
 var ValidTableTypes = []string{"booth", "standard", "high-top", "bar-seat"}
 var ValidViewTypes = []string{"window", "garden", "street", "no-view", "kitchen", "entrance"}
 
-// ValidateTableFilters validates and parses table filtering query parameters
-func ValidateTableFilters(c *gin.Context) (*TableFilterParams, error) {
+// ValidateTableFilters validates and parses table filtering query parameters.
+// Times without an offset are read in loc, the restaurant's time zone.
+func ValidateTableFilters(c *gin.Context, loc *time.Location) (*TableFilterParams, error) {
 	var filters TableFilterParams
 
 	if err := c.ShouldBindQuery(&filters); err != nil {
@@ -75,68 +84,56 @@ func ValidateTableFilters(c *gin.Context) (*TableFilterParams, error) {
 		return nil, errors.New("minCapacity cannot be greater than maxCapacity")
 	}
 
-	return &filters, nil
-}
-
-// CheckTableAvailability determines if a table is available for reservation
-func CheckTableAvailability(db *gorm.DB, tableID uint) (*TableAvailabilityStatus, error) {
-	var table models.Table
-	if err := db.First(&table, tableID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return &TableAvailabilityStatus{
-				IsTableActive:      false,
-				IsCurrentlyFree:    false,
-				AvailabilityReason: "Table not found",
-			}, nil
+	start := time.Now().UTC()
+	if filters.At != "" {
+		parsed, err := ParseRestaurantTime(filters.At, loc)
+		if err != nil {
+			return nil, errors.New("invalid at. Expected RFC 3339 or a restaurant-local time such as 2024-05-01T19:00")
 		}
-		return nil, err
+		start = parsed
 	}
-
-	// Check if table is operationally available
-	if !table.IsAvailable {
-		return &TableAvailabilityStatus{
-			IsTableActive:      false,
-			IsCurrentlyFree:    false,
-			AvailabilityReason: "Table is currently out of service",
-		}, nil
-	}
-
-	// Check current reservation status
-	if table.IsReserved {
-		// Get current reservation details
-		var reservation models.Reservation
-		if err := db.Where("table_id = ?", tableID).First(&reservation).Error; err == nil {
-			return &TableAvailabilityStatus{
-				IsTableActive:      true,
-				IsCurrentlyFree:    false,
-				ReservationID:      &reservation.ReservationID,
-				AvailabilityReason: "Table is currently reserved",
-			}, nil
+	duration := DefaultReservationDuration
+	if filters.Duration > 0 {
+		duration = time.Duration(filters.Duration) * time.Minute
+		if duration < MinReservationDuration || duration > MaxReservationDuration {
+			return nil, errors.New("duration must be between " + strconv.Itoa(int(MinReservationDuration.Minutes())) +
+				" and " + strconv.Itoa(int(MaxReservationDuration.Minutes())) + " minutes")
 		}
 	}
+	filters.Window = ReservationWindow{Start: start, End: start.Add(duration)}
+
+	return &filters, nil
+}
 
-	return &TableAvailabilityStatus{
-		IsTableActive:      true,
-		IsCurrentlyFree:    true,
-		AvailabilityReason: "Table is available for reservation",
-	}, nil
+// CheckTableAvailability determines if a table is free for the requested window
+func CheckTableAvailability(db *gorm.DB, tableID uint, window ReservationWindow) (*TableAvailabilityStatus, error) {
+	statuses, err := CheckMultipleTablesAvailability(db, []uint{tableID}, window)
+	if err != nil {
+		return nil, err
+	}
+	return statuses[tableID], nil
 }
 
-// CheckMultipleTablesAvailability checks availability for multiple tables efficiently
-func CheckMultipleTablesAvailability(db *gorm.DB, tableIDs []uint) (map[uint]*TableAvailabilityStatus, error) {
+// CheckMultipleTablesAvailability checks availability of several tables for the requested window.
+// Availability comes from the booked reservations on each table, padded by DefaultTurnTime.
+func CheckMultipleTablesAvailability(db *gorm.DB, tableIDs []uint, window ReservationWindow) (map[uint]*TableAvailabilityStatus, error) {
 	var tables []models.Table
 	if err := db.Where("table_id IN ?", tableIDs).Find(&tables).Error; err != nil {
 		return nil, err
 	}
 
-	// Get active reservations for these tables
+	// Only bookings that end after the window could block it or push back the next free slot
 	var reservations []models.Reservation
-	db.Where("table_id IN ?", tableIDs).Find(&reservations)
+	if err := db.Where("table_id IN ? AND status = ? AND end_time > ?", tableIDs, models.ReservationStatusBooked, window.Start.Add(-DefaultTurnTime)).
+		Order("time").
+		Find(&reservations).Error; err != nil {
+		return nil, err
+	}
 
-	// Create lookup map for reservations
-	reservationMap := make(map[uint]models.Reservation)
+	// Group bookings by table, keeping them ordered by start time
+	reservationMap := make(map[uint][]models.Reservation)
 	for _, res := range reservations {
-		reservationMap[res.TableID] = res
+		reservationMap[res.TableID] = append(reservationMap[res.TableID], res)
 	}
 
 	// Build availability status for each table
@@ -166,20 +163,24 @@ func CheckMultipleTablesAvailability(db *gorm.DB, tableIDs []uint) (map[uint]*Ta
 			continue
 		}
 
-		if table.IsReserved {
-			if res, hasReservation := reservationMap[tableID]; hasReservation {
-				result[tableID] = &TableAvailabilityStatus{
-					IsTableActive:      true,
-					IsCurrentlyFree:    false,
-					ReservationID:      &res.ReservationID,
-					AvailabilityReason: "Table is currently reserved",
-				}
-			} else {
-				result[tableID] = &TableAvailabilityStatus{
-					IsTableActive:      true,
-					IsCurrentlyFree:    false,
-					AvailabilityReason: "Table marked as reserved but no active reservation found",
-				}
+		bookings := make([]ReservationWindow, 0, len(reservationMap[tableID]))
+		var blocking *models.Reservation
+		for i, res := range reservationMap[tableID] {
+			booking := ReservationWindow{Start: res.Time, End: res.EndTime}
+			bookings = append(bookings, booking)
+			if blocking == nil && ReservationWindowsOverlap(window.WithTurnTime(DefaultTurnTime), booking.WithTurnTime(DefaultTurnTime)) {
+				blocking = &reservationMap[tableID][i]
+			}
+		}
+
+		nextAvailable := NextAvailableTime(bookings, window, DefaultTurnTime)
+		if blocking != nil {
+			result[tableID] = &TableAvailabilityStatus{
+				IsTableActive:      true,
+				IsCurrentlyFree:    false,
+				NextAvailableTime:  &nextAvailable,
+				ReservationID:      &blocking.ReservationID,
+				AvailabilityReason: "Table is reserved during the requested time",
 			}
 			continue
 		}
@@ -187,6 +188,7 @@ func CheckMultipleTablesAvailability(db *gorm.DB, tableIDs []uint) (map[uint]*Ta
 		result[tableID] = &TableAvailabilityStatus{
 			IsTableActive:      true,
 			IsCurrentlyFree:    true,
+			NextAvailableTime:  &nextAvailable,
 			AvailabilityReason: "Table is available for reservation",
 		}
 	}
@@ -194,6 +196,17 @@ func CheckMultipleTablesAvailability(db *gorm.DB, tableIDs []uint) (map[uint]*Ta
 	return result, nil
 }
 
+// LocalizeAvailability fills in the restaurant-local next available time of each status
+func LocalizeAvailability(statuses map[uint]*TableAvailabilityStatus, loc *time.Location) {
+	for _, status := range statuses {
+		status.NextAvailableLocal = nil
+		if status.NextAvailableTime != nil {
+			local := status.NextAvailableTime.In(loc).Format(time.RFC3339)
+			status.NextAvailableLocal = &local
+		}
+	}
+}
+
 // BuildTableQuery constructs dynamic GORM query for table filtering
 func BuildTableQuery(db *gorm.DB, restaurantID uint, filters *TableFilterParams) *gorm.DB {
 	query := db.Model(&models.Table{}).Where("restaurant_id = ?", restaurantID)
@@ -225,10 +238,14 @@ func BuildTableQuery(db *gorm.DB, restaurantID uint, filters *TableFilterParams)
 	return query
 }
 
-// BuildAvailableTableQuery builds query specifically for available (non-reserved) tables
+// BuildAvailableTableQuery builds query specifically for operational tables that can seat the party.
+// Whether each table is free for the requested window is decided by CheckMultipleTablesAvailability.
 func BuildAvailableTableQuery(db *gorm.DB, restaurantID uint, filters *TableFilterParams) *gorm.DB {
 	query := BuildTableQuery(db, restaurantID, filters)
-	return query.Where("is_available = ? AND is_reserved = ?", true, false)
+	if filters.PartySize > 0 {
+		query = query.Where("capacity >= ?", filters.PartySize)
+	}
+	return query.Where("is_available = ?", true)
 }
 
 // TableQueryResult represents the structured response for table queries
@@ -241,13 +258,15 @@ type TableQueryResult struct {
 	ViewDescription     string `json:"viewDescription"`
 	TableType           string `json:"tableType"`
 	IsAvailable         bool   `json:"isAvailable"`
-	IsReserved          bool   `json:"isReserved"`
+	IsReserved          bool   `json:"isReserved"` // Reserved during the requested window
 	// Future visual fields
 	CoordinateX *float64 `json:"coordinateX,omitempty"`
 	CoordinateY *float64 `json:"coordinateY,omitempty"`
 	Width       *float64 `json:"width,omitempty"`
 	Height      *float64 `json:"height,omitempty"`
 	Rotation    *float64 `json:"rotation,omitempty"`
+	// Availability for the requested window
+	Availability *TableAvailabilityStatus `json:"availability,omitempty"`
 }
 
 // ConvertTableToResult converts Table model to API response format
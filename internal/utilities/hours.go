@@ -0,0 +1,292 @@
+// This file contains utilities for evaluating restaurant opening hours
+//
+// The utilities here are as follows:
+// - ParseClock
+// - ValidateClockRange
+// - RestaurantSchedule
+// - LoadRestaurantSchedule
+// - IsOpenFor
+// - NextOpening
+// - ApplyScheduleToAvailability
+
+package utilities
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"waitress-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Valid options for service periods
+var ValidServicePeriodNames = []string{"breakfast", "brunch", "lunch", "dinner", "late-night"}
+
+// How far ahead NextOpening searches for the next time the restaurant can take a booking
+const scheduleLookahead = 14
+
+// IsValidServicePeriodName checks a service period name against ValidServicePeriodNames
+func IsValidServicePeriodName(name string) bool {
+	return isValidOption(name, ValidServicePeriodNames)
+}
+
+// ParseClock converts an "HH:MM" wall clock time into minutes after midnight.
+// "24:00" is accepted as the end of the day.
+func ParseClock(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 || len(parts[0]) != 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	if hours < 0 || minutes < 0 || minutes > 59 || hours > 24 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return hours*60 + minutes, nil
+}
+
+// ValidateClockRange checks both ends of an opening interval. The end may be earlier than
+// the start for intervals that run past midnight, but the two may not be equal.
+func ValidateClockRange(start, end string) error {
+	startMinutes, err := ParseClock(start)
+	if err != nil {
+		return err
+	}
+	endMinutes, err := ParseClock(end)
+	if err != nil {
+		return err
+	}
+	if startMinutes == endMinutes {
+		return errors.New("opening and closing times cannot be equal")
+	}
+	return nil
+}
+
+// RestaurantSchedule holds everything needed to decide when a restaurant is open.
+// Times are evaluated as wall clock times in Location.
+type RestaurantSchedule struct {
+	Hours      []models.OpeningHours
+	Periods    []models.ServicePeriod
+	Exceptions []models.HoursException
+	Location   *time.Location
+}
+
+// LoadRestaurantSchedule loads the opening hours, service periods and exceptions of a restaurant,
+// evaluated in the restaurant's time zone
+func LoadRestaurantSchedule(db *gorm.DB, restaurant *models.Restaurant) (*RestaurantSchedule, error) {
+	restaurantID := restaurant.RestaurantId
+	schedule := &RestaurantSchedule{Location: restaurant.Location()}
+	if err := db.Where("restaurant_id = ?", restaurantID).Order("weekday, opens_at").Find(&schedule.Hours).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("restaurant_id = ?", restaurantID).Order("weekday, starts_at").Find(&schedule.Periods).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("restaurant_id = ?", restaurantID).Order("date").Find(&schedule.Exceptions).Error; err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// clockInterval anchors an "HH:MM"-"HH:MM" range to a calendar day
+func clockInterval(day time.Time, start, end string) (ReservationWindow, bool) {
+	startMinutes, err := ParseClock(start)
+	if err != nil {
+		return ReservationWindow{}, false
+	}
+	endMinutes, err := ParseClock(end)
+	if err != nil {
+		return ReservationWindow{}, false
+	}
+	if endMinutes <= startMinutes {
+		endMinutes += 24 * 60
+	}
+	opens := time.Date(day.Year(), day.Month(), day.Day(), 0, startMinutes, 0, 0, day.Location())
+	closes := time.Date(day.Year(), day.Month(), day.Day(), 0, endMinutes, 0, 0, day.Location())
+	return ReservationWindow{Start: opens, End: closes}, true
+}
+
+// exceptionFor returns the exception that applies to a day, preferring one-off dates over annual holidays
+func (s *RestaurantSchedule) exceptionFor(day time.Time) *models.HoursException {
+	date := day.Format("2006-01-02")
+	var annual *models.HoursException
+	for i := range s.Exceptions {
+		exception := &s.Exceptions[i]
+		if exception.Date == date {
+			return exception
+		}
+		if exception.RecursAnnually && len(exception.Date) == 10 && exception.Date[5:] == date[5:] {
+			annual = exception
+		}
+	}
+	return annual
+}
+
+// openIntervals returns the open intervals that start on the given day
+func (s *RestaurantSchedule) openIntervals(day time.Time) []ReservationWindow {
+	var intervals []ReservationWindow
+	if exception := s.exceptionFor(day); exception != nil {
+		if exception.IsClosed || exception.OpensAt == nil || exception.ClosesAt == nil {
+			return nil
+		}
+		if interval, ok := clockInterval(day, *exception.OpensAt, *exception.ClosesAt); ok {
+			intervals = append(intervals, interval)
+		}
+		return intervals
+	}
+	for _, hours := range s.Hours {
+		if time.Weekday(hours.Weekday) != day.Weekday() {
+			continue
+		}
+		if interval, ok := clockInterval(day, hours.OpensAt, hours.ClosesAt); ok {
+			intervals = append(intervals, interval)
+		}
+	}
+	return intervals
+}
+
+// servicePeriodsOn returns the service periods that start on the given day
+func (s *RestaurantSchedule) servicePeriodsOn(day time.Time) ([]models.ServicePeriod, []ReservationWindow) {
+	var periods []models.ServicePeriod
+	var intervals []ReservationWindow
+	for _, period := range s.Periods {
+		if time.Weekday(period.Weekday) != day.Weekday() {
+			continue
+		}
+		if interval, ok := clockInterval(day, period.StartsAt, period.EndsAt); ok {
+			periods = append(periods, period)
+			intervals = append(intervals, interval)
+		}
+	}
+	return periods, intervals
+}
+
+// location returns the time zone the schedule is evaluated in, defaulting to UTC
+func (s *RestaurantSchedule) location() *time.Location {
+	if s.Location == nil {
+		return time.UTC
+	}
+	return s.Location
+}
+
+// HasHours reports whether any opening hours are configured. Restaurants without hours are treated as always open.
+func (s *RestaurantSchedule) HasHours() bool {
+	return len(s.Hours) > 0 || len(s.Exceptions) > 0
+}
+
+// startOfDay truncates a time to midnight in its own location
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// ServicePeriodAt returns the service period a booking starting at t falls in, if any
+func (s *RestaurantSchedule) ServicePeriodAt(t time.Time) *models.ServicePeriod {
+	t = t.In(s.location())
+	// A period that started yesterday may still be running after midnight
+	for _, day := range []time.Time{startOfDay(t), startOfDay(t).AddDate(0, 0, -1)} {
+		periods, intervals := s.servicePeriodsOn(day)
+		for i, interval := range intervals {
+			if !t.Before(interval.Start) && t.Before(interval.End) {
+				return &periods[i]
+			}
+		}
+	}
+	return nil
+}
+
+// IsOpenFor reports whether the whole window falls inside one opening interval and, when the day
+// has service periods, whether it starts inside one that accepts reservations.
+// The returned reason explains a refusal.
+func (s *RestaurantSchedule) IsOpenFor(window ReservationWindow) (bool, string) {
+	start := window.Start.In(s.location())
+	end := window.End.In(s.location())
+
+	if s.HasHours() {
+		open := false
+		// An interval that opened yesterday may run past midnight
+		for _, day := range []time.Time{startOfDay(start).AddDate(0, 0, -1), startOfDay(start)} {
+			for _, interval := range s.openIntervals(day) {
+				if !start.Before(interval.Start) && !end.After(interval.End) {
+					open = true
+				}
+			}
+		}
+		if !open {
+			if exception := s.exceptionFor(startOfDay(start)); exception != nil && exception.IsClosed && exception.Reason != "" {
+				return false, "Restaurant is closed: " + exception.Reason
+			}
+			return false, "Restaurant is not open for the requested time"
+		}
+	}
+
+	// Days with service periods only take bookings that start inside a period accepting them
+	period := s.ServicePeriodAt(start)
+	if periods, _ := s.servicePeriodsOn(startOfDay(start)); len(periods) > 0 || period != nil {
+		if period == nil || !period.AcceptsReservations {
+			return false, "Reservations are not accepted outside of service periods"
+		}
+	}
+	return true, ""
+}
+
+// NextOpening returns the earliest start at or after window.Start at which a window of the
+// same length is allowed by the schedule, searching up to two weeks ahead.
+func (s *RestaurantSchedule) NextOpening(window ReservationWindow) (time.Time, bool) {
+	if ok, _ := s.IsOpenFor(window); ok {
+		return window.Start, true
+	}
+	duration := window.End.Sub(window.Start)
+	start := window.Start.In(s.location())
+	for offset := -1; offset <= scheduleLookahead; offset++ {
+		day := startOfDay(start).AddDate(0, 0, offset)
+		var candidates []time.Time
+		for _, interval := range s.openIntervals(day) {
+			candidates = append(candidates, interval.Start)
+		}
+		_, periods := s.servicePeriodsOn(day)
+		for _, interval := range periods {
+			candidates = append(candidates, interval.Start)
+		}
+		for _, candidate := range candidates {
+			if candidate.Before(start) {
+				candidate = start
+			}
+			if ok, _ := s.IsOpenFor(ReservationWindow{Start: candidate, End: candidate.Add(duration)}); ok {
+				return candidate, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// ApplyScheduleToAvailability marks every table as unavailable when the restaurant is closed for the window,
+// moving the next available time forward to the next opening.
+func ApplyScheduleToAvailability(statuses map[uint]*TableAvailabilityStatus, schedule *RestaurantSchedule, window ReservationWindow) {
+	open, reason := schedule.IsOpenFor(window)
+	if open {
+		return
+	}
+	nextOpening, found := schedule.NextOpening(window)
+	for _, status := range statuses {
+		if !status.IsTableActive {
+			continue
+		}
+		status.IsCurrentlyFree = false
+		status.ReservationID = nil
+		status.AvailabilityReason = reason
+		if !found {
+			status.NextAvailableTime = nil
+		} else if status.NextAvailableTime == nil || status.NextAvailableTime.Before(nextOpening) {
+			status.NextAvailableTime = &nextOpening
+		}
+	}
+}
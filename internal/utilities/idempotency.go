@@ -0,0 +1,296 @@
+// This file contains utilities for making retried requests safe with an Idempotency-Key header
+//
+// The utilities here are as follows:
+// - IdempotencyStore
+// - MemoryIdempotencyStore
+// - NewMemoryIdempotencyStore
+// - DBIdempotencyStore
+// - NewDBIdempotencyStore
+// - Idempotent
+
+package utilities
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"waitress-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed" // Set to "true" on responses replayed for a retry
+	IdempotencyKeyTTL        = 24 * time.Hour        // How long a response is replayed for
+)
+
+const (
+	maxIdempotencyKeyLength = 255
+	maxIdempotentBodySize   = 1 << 20     // Larger requests are rejected and larger responses not stored
+	idempotencyLockTimeout  = time.Minute // After this a request still in progress is taken to have died
+)
+
+// IdempotencyRecord is what an IdempotencyStore keeps for one key
+type IdempotencyRecord struct {
+	Fingerprint string // Hash of the request the key was first used with
+	StatusCode  int    // Zero while the first request is in progress
+	ContentType string
+	Body        []byte
+	ExpiresAt   time.Time // When the store may forget the record
+}
+
+// IdempotencyStore keeps the responses replayed by Idempotent. Reserve must be atomic.
+type IdempotencyStore interface {
+	// Reserve stores record under key unless an unexpired record is there already, which it returns instead
+	Reserve(key string, record IdempotencyRecord, now time.Time) (*IdempotencyRecord, error)
+	Save(key string, record IdempotencyRecord) error
+	Delete(key string) error
+}
+
+// IdempotencyKeys is the store used by Idempotent. The server replaces it with a DBIdempotencyStore.
+var IdempotencyKeys IdempotencyStore = NewMemoryIdempotencyStore()
+
+// MemoryIdempotencyStore is an IdempotencyStore local to this server instance
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+	pruned  time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]IdempotencyRecord)}
+}
+
+// How often the memory store sweeps expired records
+const idempotencyPruneInterval = 10 * time.Minute
+
+// Reserve stores record under key unless an unexpired record is there already
+func (s *MemoryIdempotencyStore) Reserve(key string, record IdempotencyRecord, now time.Time) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.pruned) > idempotencyPruneInterval {
+		for k, r := range s.records {
+			if now.After(r.ExpiresAt) {
+				delete(s.records, k)
+			}
+		}
+		s.pruned = now
+	}
+	if existing, ok := s.records[key]; ok && now.Before(existing.ExpiresAt) {
+		return &existing, nil
+	}
+	s.records[key] = record
+	return nil, nil
+}
+
+// Save replaces the record under key
+func (s *MemoryIdempotencyStore) Save(key string, record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = record
+	return nil
+}
+
+// Delete forgets a key
+func (s *MemoryIdempotencyStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// DBIdempotencyStore keeps records in the idempotency_key table so every server instance sees them
+type DBIdempotencyStore struct {
+	db *gorm.DB
+}
+
+// NewDBIdempotencyStore creates an idempotency store backed by the database
+func NewDBIdempotencyStore(db *gorm.DB) *DBIdempotencyStore {
+	return &DBIdempotencyStore{db: db}
+}
+
+// Reserve inserts record under key unless an unexpired record is there already, clearing out records
+// that have expired
+func (s *DBIdempotencyStore) Reserve(key string, record IdempotencyRecord, now time.Time) (*IdempotencyRecord, error) {
+	if err := s.db.Where("expires_at <= ?", now).Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, err
+	}
+	row := models.IdempotencyKey{
+		KeyHash:     key,
+		Fingerprint: record.Fingerprint,
+		StatusCode:  record.StatusCode,
+		ContentType: record.ContentType,
+		Body:        record.Body,
+		ExpiresAt:   record.ExpiresAt,
+		CreatedAt:   now,
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := s.db.Where("key_hash = ?", key).First(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &IdempotencyRecord{
+		Fingerprint: existing.Fingerprint,
+		StatusCode:  existing.StatusCode,
+		ContentType: existing.ContentType,
+		Body:        existing.Body,
+		ExpiresAt:   existing.ExpiresAt,
+	}, nil
+}
+
+// Save replaces the record under key
+func (s *DBIdempotencyStore) Save(key string, record IdempotencyRecord) error {
+	return s.db.Model(&models.IdempotencyKey{}).Where("key_hash = ?", key).Updates(map[string]interface{}{
+		"fingerprint":  record.Fingerprint,
+		"status_code":  record.StatusCode,
+		"content_type": record.ContentType,
+		"body":         record.Body,
+		"expires_at":   record.ExpiresAt,
+	}).Error
+}
+
+// Delete forgets a key
+func (s *DBIdempotencyStore) Delete(key string) error {
+	return s.db.Where("key_hash = ?", key).Delete(&models.IdempotencyKey{}).Error
+}
+
+// idempotencyRecorder keeps a copy of the response written by the handlers
+type idempotencyRecorder struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	overflow bool // The response was too large to store
+}
+
+func (w *idempotencyRecorder) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyRecorder) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *idempotencyRecorder) record(data []byte) {
+	if w.overflow || w.body.Len()+len(data) > maxIdempotentBodySize {
+		w.overflow = true
+		return
+	}
+	w.body.Write(data)
+}
+
+// hashIdempotencyParts hashes parts separated so that no two lists of parts hash alike
+func hashIdempotencyParts(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(strconv.Itoa(len(part))))
+		h.Write([]byte{0})
+		h.Write([]byte(part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Idempotent is Gin middleware that makes a route safe to retry. The first response to a request with an
+// Idempotency-Key header is stored per key, user and route for IdempotencyKeyTTL, and replayed to retries
+// without running the handler again. Reusing a key with a different request is rejected, as is a retry
+// while the first request is still running. Server errors are not stored, so a retry after one runs
+// again. Requests without the header are handled as usual. Use it after RequirePermission.
+func Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be at most %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength)})
+			c.Abort()
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxIdempotentBodySize+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Error reading request body"})
+			c.Abort()
+			return
+		}
+		if len(body) > maxIdempotentBodySize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body is too large"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		store := IdempotencyKeys
+		userID, _ := GetAuthenticatedUserID(c)
+		storeKey := hashIdempotencyParts(key, strconv.FormatUint(uint64(userID), 10), c.Request.Method, c.FullPath())
+		fingerprint := hashIdempotencyParts(c.Request.Method, c.Request.URL.RequestURI(), string(body))
+		now := time.Now()
+		existing, err := store.Reserve(storeKey, IdempotencyRecord{Fingerprint: fingerprint, ExpiresAt: now.Add(idempotencyLockTimeout)}, now)
+		if err != nil {
+			fmt.Println("Error reserving idempotency key:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking " + IdempotencyKeyHeader})
+			c.Abort()
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.Fingerprint != fingerprint:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": IdempotencyKeyHeader + " was already used with a different request"})
+			case existing.StatusCode == 0:
+				c.JSON(http.StatusConflict, gin.H{"error": "A request with this " + IdempotencyKeyHeader + " is still being processed; retry later"})
+			default:
+				c.Header(IdempotentReplayedHeader, "true")
+				c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		recorder := &idempotencyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		saved := false
+		defer func() {
+			// Let retries run again when the response was not stored, including when a handler panicked
+			if !saved {
+				if err := store.Delete(storeKey); err != nil {
+					fmt.Println("Error releasing idempotency key:", err)
+				}
+			}
+		}()
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError || recorder.overflow {
+			return
+		}
+		err = store.Save(storeKey, IdempotencyRecord{
+			Fingerprint: fingerprint,
+			StatusCode:  status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+			ExpiresAt:   time.Now().Add(IdempotencyKeyTTL),
+		})
+		if err != nil {
+			fmt.Println("Error storing idempotent response:", err)
+			return
+		}
+		saved = true
+	}
+}
@@ -0,0 +1,361 @@
+// This file contains utilities for the keys that sign and verify JWTs
+//
+// The utilities here are as follows:
+// - SigningKey
+// - NewHMACKey
+// - NewRSAKey
+// - NewEd25519Key
+// - KeySet
+// - NewKeySet
+// - LoadKeySetFromEnv
+// - SetSigningKeys
+// - SigningKeys
+// - JWK
+
+package utilities
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms supported for JWTs
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// LegacyKeyID identifies the JWT_SECRET key when no key file is configured. Tokens without a
+// kid header were signed by it.
+const LegacyKeyID = "default"
+
+// SigningKey is one key of the key set. Retired keys only verify tokens, and stop doing so once
+// ValidUntil has passed; the gap between retiring a key and ValidUntil is the rotation grace period.
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	ValidUntil time.Time // Zero while the key has not been retired
+
+	signingKey interface{} // Nil for keys that can only verify
+	verifyKey  interface{}
+}
+
+// NewHMACKey creates an HS256 key from a shared secret
+func NewHMACKey(id string, secret []byte) SigningKey {
+	return SigningKey{ID: id, Algorithm: AlgorithmHS256, signingKey: secret, verifyKey: secret}
+}
+
+// NewRSAKey creates an RS256 key. A nil private key gives a key that only verifies.
+func NewRSAKey(id string, private *rsa.PrivateKey, public *rsa.PublicKey) SigningKey {
+	key := SigningKey{ID: id, Algorithm: AlgorithmRS256, verifyKey: public}
+	if private != nil {
+		key.signingKey = private
+		key.verifyKey = &private.PublicKey
+	}
+	return key
+}
+
+// NewEd25519Key creates an EdDSA key. A nil private key gives a key that only verifies.
+func NewEd25519Key(id string, private ed25519.PrivateKey, public ed25519.PublicKey) SigningKey {
+	key := SigningKey{ID: id, Algorithm: AlgorithmEdDSA, verifyKey: public}
+	if private != nil {
+		key.signingKey = private
+		key.verifyKey = private.Public()
+	}
+	return key
+}
+
+// method returns the jwt signing method of the key's algorithm
+func (k SigningKey) method() jwt.SigningMethod {
+	switch k.Algorithm {
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+// KeySet holds the key new tokens are signed with and every key tokens may still be verified with
+type KeySet struct {
+	current string
+	keys    map[string]SigningKey
+}
+
+// NewKeySet creates a key set that signs with the key identified by current
+func NewKeySet(current string, keys ...SigningKey) (*KeySet, error) {
+	set := &KeySet{current: current, keys: make(map[string]SigningKey, len(keys))}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("signing keys must have an ID")
+		}
+		if _, duplicate := set.keys[key.ID]; duplicate {
+			return nil, fmt.Errorf("duplicate signing key ID %q", key.ID)
+		}
+		if key.verifyKey == nil {
+			return nil, fmt.Errorf("signing key %q has no key material", key.ID)
+		}
+		set.keys[key.ID] = key
+	}
+	signer, ok := set.keys[current]
+	if !ok {
+		return nil, fmt.Errorf("current signing key %q is not in the key set", current)
+	}
+	if signer.signingKey == nil || !signer.ValidUntil.IsZero() {
+		return nil, fmt.Errorf("current signing key %q cannot sign", current)
+	}
+	return set, nil
+}
+
+// Sign signs claims with the current key, naming it in the kid header
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key := s.keys[s.current]
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signingKey)
+}
+
+// Parse verifies a token against the key named by its kid header and parses it into claims
+func (s *KeySet) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, s.keyFor)
+}
+
+// keyFor picks the verification key for a token, rejecting unknown or retired keys and algorithm mismatches
+func (s *KeySet) keyFor(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = LegacyKeyID
+	}
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	if !key.ValidUntil.IsZero() && time.Now().After(key.ValidUntil) {
+		return nil, fmt.Errorf("signing key %q has been retired", kid)
+	}
+	return key.verifyKey, nil
+}
+
+// JWK is the public form of a key as published in a JSON Web Key Set
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS returns the public keys of the set that still verify tokens. HMAC secrets are never published.
+func (s *KeySet) JWKS() []JWK {
+	now := time.Now()
+	jwks := []JWK{}
+	for _, key := range s.keys {
+		if !key.ValidUntil.IsZero() && now.After(key.ValidUntil) {
+			continue
+		}
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwks = append(jwks, JWK{
+				KeyType:   "RSA",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.Algorithm,
+				N:         base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks = append(jwks, JWK{
+				KeyType:   "OKP",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.Algorithm,
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return jwks
+}
+
+// keyFileEntry is one key of the JWT_KEYS_FILE configuration
+type keyFileEntry struct {
+	ID             string     `json:"kid"`
+	Algorithm      string     `json:"alg"`
+	SecretEnv      string     `json:"secretEnv"`      // HS256: environment variable holding the secret
+	PrivateKeyFile string     `json:"privateKeyFile"` // RS256/EdDSA: PEM encoded PKCS#8 or PKCS#1 private key
+	PublicKeyFile  string     `json:"publicKeyFile"`  // RS256/EdDSA: PEM encoded public key of a key that only verifies
+	ValidUntil     *time.Time `json:"validUntil"`     // Set when the key is retired
+}
+
+// keyFile is the JWT_KEYS_FILE configuration
+type keyFile struct {
+	Current string         `json:"current"`
+	Keys    []keyFileEntry `json:"keys"`
+}
+
+// LoadKeySetFromEnv builds the key set from the JSON file named by JWT_KEYS_FILE. Without one, the
+// key set holds a single HS256 key read from JWT_SECRET.
+//
+// To rotate, add the new key, make it current and set validUntil on the old key to at least
+// AccessTokenTTL from now, so tokens it already signed keep working until they expire. When moving
+// off JWT_SECRET, keep it in the file as kid "default" with secretEnv "JWT_SECRET" for the grace period.
+func LoadKeySetFromEnv() (*KeySet, error) {
+	path := os.Getenv("JWT_KEYS_FILE")
+	if path == "" {
+		return NewKeySet(LegacyKeyID, NewHMACKey(LegacyKeyID, []byte(os.Getenv("JWT_SECRET"))))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWT key file: %w", err)
+	}
+	var config keyFile
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parsing JWT key file: %w", err)
+	}
+
+	keys := make([]SigningKey, 0, len(config.Keys))
+	for _, entry := range config.Keys {
+		key, err := entry.signingKey()
+		if err != nil {
+			return nil, fmt.Errorf("signing key %q: %w", entry.ID, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(config.Current, keys...)
+}
+
+// signingKey loads the key material of a configured key
+func (e keyFileEntry) signingKey() (SigningKey, error) {
+	var key SigningKey
+	switch e.Algorithm {
+	case AlgorithmHS256:
+		secret := os.Getenv(e.SecretEnv)
+		if e.SecretEnv == "" || secret == "" {
+			return SigningKey{}, errors.New("secretEnv must name a non-empty environment variable")
+		}
+		key = NewHMACKey(e.ID, []byte(secret))
+	case AlgorithmRS256, AlgorithmEdDSA:
+		private, public, err := readKeyFiles(e.PrivateKeyFile, e.PublicKeyFile)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		if key, err = asymmetricKey(e.ID, e.Algorithm, private, public); err != nil {
+			return SigningKey{}, err
+		}
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported algorithm %q", e.Algorithm)
+	}
+	if e.ValidUntil != nil {
+		key.ValidUntil = *e.ValidUntil
+	}
+	return key, nil
+}
+
+// readKeyFiles parses a PEM private key, or a PEM public key when no private key file is given
+func readKeyFiles(privatePath, publicPath string) (crypto.PrivateKey, crypto.PublicKey, error) {
+	if privatePath != "" {
+		block, err := readPEM(privatePath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+			return key, nil, nil
+		}
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, errors.New("private key is neither PKCS#8 nor PKCS#1")
+		}
+		return key, nil, nil
+	}
+	if publicPath != "" {
+		block, err := readPEM(publicPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		return nil, key, err
+	}
+	return nil, nil, errors.New("privateKeyFile or publicKeyFile is required")
+}
+
+// readPEM reads the first PEM block of a file
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM block", path)
+	}
+	return block, nil
+}
+
+// asymmetricKey checks parsed key material against the configured algorithm
+func asymmetricKey(id, algorithm string, private crypto.PrivateKey, public crypto.PublicKey) (SigningKey, error) {
+	switch algorithm {
+	case AlgorithmRS256:
+		if key, ok := private.(*rsa.PrivateKey); ok {
+			return NewRSAKey(id, key, nil), nil
+		}
+		if key, ok := public.(*rsa.PublicKey); ok {
+			return NewRSAKey(id, nil, key), nil
+		}
+	case AlgorithmEdDSA:
+		if key, ok := private.(ed25519.PrivateKey); ok {
+			return NewEd25519Key(id, key, nil), nil
+		}
+		if key, ok := public.(ed25519.PublicKey); ok {
+			return NewEd25519Key(id, nil, key), nil
+		}
+	}
+	return SigningKey{}, fmt.Errorf("key material does not match algorithm %s", algorithm)
+}
+
+var (
+	signingKeys     *KeySet
+	signingKeysOnce sync.Once
+	signingKeysMu   sync.RWMutex
+)
+
+// SetSigningKeys replaces the key set used to sign and verify tokens
+func SetSigningKeys(keys *KeySet) {
+	signingKeysOnce.Do(func() {})
+	signingKeysMu.Lock()
+	signingKeys = keys
+	signingKeysMu.Unlock()
+}
+
+// SigningKeys returns the key set used to sign and verify tokens. Until the server sets one,
+// it falls back to the JWT_SECRET key.
+func SigningKeys() *KeySet {
+	signingKeysOnce.Do(func() {
+		keys, _ := NewKeySet(LegacyKeyID, NewHMACKey(LegacyKeyID, []byte(os.Getenv("JWT_SECRET"))))
+		signingKeys = keys
+	})
+	signingKeysMu.RLock()
+	defer signingKeysMu.RUnlock()
+	return signingKeys
+}
@@ -0,0 +1,188 @@
+// This file contains utilities for streaming events to restaurant kitchen displays
+//
+// The utilities here are as follows:
+// - KitchenBroker
+// - NewKitchenBroker
+// - Subscribe
+// - Notify
+// - PublishKitchenEvent
+// - LatestKitchenEventID
+// - KitchenEventStored
+// - KitchenResync
+// - KitchenEventsSince
+
+package utilities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"waitress-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Upper bound on events replayed to a display at once. A display further behind is sent a resync event.
+const kitchenReplayLimit = 500
+
+// How long kitchen events are kept for displays to replay
+const KitchenEventRetention = 24 * time.Hour
+
+// How often a stream checks for events stored by other instances. Events published on the same instance
+// are picked up right away.
+const KitchenPollInterval = 2 * time.Second
+
+// KitchenBroker wakes the displays connected to this instance for a restaurant when it stores an event
+// for them. The events themselves are read from the database, which every instance shares.
+type KitchenBroker struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[chan struct{}]struct{}
+}
+
+// KitchenEvents is the broker shared by the order, menu and kitchen handlers
+var KitchenEvents = NewKitchenBroker()
+
+// NewKitchenBroker creates a broker with no subscribers
+func NewKitchenBroker() *KitchenBroker {
+	return &KitchenBroker{subscribers: make(map[uint]map[chan struct{}]struct{})}
+}
+
+// Subscribe registers a display for a restaurant's notifications. Notifications that arrive while one is
+// waiting are merged into it. The returned function must be called when the display disconnects; it
+// closes the channel.
+func (b *KitchenBroker) Subscribe(restaurantID uint) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	if b.subscribers[restaurantID] == nil {
+		b.subscribers[restaurantID] = make(map[chan struct{}]struct{})
+	}
+	b.subscribers[restaurantID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[restaurantID], ch)
+			if len(b.subscribers[restaurantID]) == 0 {
+				delete(b.subscribers, restaurantID)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+// Notify tells every display of a restaurant that new events are stored, without blocking
+func (b *KitchenBroker) Notify(restaurantID uint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers[restaurantID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// PublishKitchenEvent stores an event for the restaurant's displays and wakes the ones connected here.
+// The restaurant is locked while the event is stored, so its events are committed in ID order and a
+// display that has read up to an ID cannot miss a lower one committed later. Events older than
+// KitchenEventRetention are pruned on the way. Failures are logged rather than returned so they never
+// fail the request that triggered the event.
+func PublishKitchenEvent(db *gorm.DB, broker *KitchenBroker, restaurantID uint, eventType string, orderID, menuID *uint, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		fmt.Printf("Error encoding kitchen event %s: %v\n", eventType, err)
+		return
+	}
+	event := models.KitchenEvent{
+		RestaurantID: restaurantID,
+		Type:         eventType,
+		OrderID:      orderID,
+		MenuID:       menuID,
+		Payload:      string(data),
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var restaurant models.Restaurant
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("restaurant_id").First(&restaurant, restaurantID).Error; err != nil {
+			return err
+		}
+		err := tx.Where("restaurant_id = ? AND created_at < ?", restaurantID, time.Now().Add(-KitchenEventRetention)).
+			Delete(&models.KitchenEvent{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&event).Error
+	})
+	if err != nil {
+		fmt.Printf("Error storing kitchen event %s: %v\n", eventType, err)
+		return
+	}
+	broker.Notify(restaurantID)
+}
+
+// LatestKitchenEventID returns the ID of a restaurant's newest stored event, or 0 if it has none
+func LatestKitchenEventID(db *gorm.DB, restaurantID uint) (uint, error) {
+	var latest uint
+	err := db.Model(&models.KitchenEvent{}).
+		Where("restaurant_id = ?", restaurantID).
+		Select("COALESCE(MAX(kitchen_event_id), 0)").
+		Scan(&latest).Error
+	return latest, err
+}
+
+// KitchenEventStored reports whether a restaurant's event is still stored. A display reconnecting after
+// an event that has been pruned may have missed events that are gone too.
+func KitchenEventStored(db *gorm.DB, restaurantID, eventID uint) (bool, error) {
+	var event models.KitchenEvent
+	err := db.Select("kitchen_event_id").Where("kitchen_event_id = ? AND restaurant_id = ?", eventID, restaurantID).First(&event).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// KitchenResync returns a resync event carrying the ID of the restaurant's newest event. It tells a
+// display that events were skipped, so it must reload the restaurant's orders and carry on from there.
+func KitchenResync(db *gorm.DB, restaurantID uint) (models.KitchenEvent, error) {
+	latest, err := LatestKitchenEventID(db, restaurantID)
+	if err != nil {
+		return models.KitchenEvent{}, err
+	}
+	data, err := json.Marshal(map[string]uint{"lastEventId": latest})
+	if err != nil {
+		return models.KitchenEvent{}, err
+	}
+	return models.KitchenEvent{
+		KitchenEventID: latest,
+		RestaurantID:   restaurantID,
+		Type:           models.KitchenEventResync,
+		Payload:        string(data),
+	}, nil
+}
+
+// KitchenEventsSince returns a restaurant's stored events after the given event ID, oldest first. When more
+// than kitchenReplayLimit follow it, a single resync event is returned instead.
+func KitchenEventsSince(db *gorm.DB, restaurantID, lastEventID uint) ([]models.KitchenEvent, error) {
+	var events []models.KitchenEvent
+	err := db.Where("restaurant_id = ? AND kitchen_event_id > ?", restaurantID, lastEventID).
+		Order("kitchen_event_id").
+		Limit(kitchenReplayLimit + 1).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > kitchenReplayLimit {
+		resync, err := KitchenResync(db, restaurantID)
+		if err != nil {
+			return nil, err
+		}
+		return []models.KitchenEvent{resync}, nil
+	}
+	return events, nil
+}
@@ -0,0 +1,365 @@
+// This file contains utilities for throttling failed login attempts
+//
+// The utilities here are as follows:
+// - LimiterStore
+// - MemoryLimiterStore
+// - NewMemoryLimiterStore
+// - DBLimiterStore
+// - NewDBLimiterStore
+// - LimiterPolicy
+// - LoginThrottle
+// - NewLoginThrottle
+// - Check
+// - Failure
+// - Success
+// - Unlock
+// - PasswordReset
+
+package utilities
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"waitress-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LimiterState is what a LimiterStore keeps for one account or address
+type LimiterState struct {
+	Failures     int
+	LastFailure  time.Time
+	BlockedUntil time.Time // Zero when attempts are allowed
+	ExpiresAt    time.Time // When the store may forget the state
+}
+
+// LimiterStore keeps the failure counts of the login throttle. Update must apply fn atomically.
+type LimiterStore interface {
+	Get(key string, now time.Time) (LimiterState, error)
+	Update(key string, now time.Time, fn func(*LimiterState)) (LimiterState, error)
+	Delete(key string) error
+}
+
+// MemoryLimiterStore is a LimiterStore local to this server instance
+type MemoryLimiterStore struct {
+	mu     sync.Mutex
+	states map[string]LimiterState
+	pruned time.Time
+}
+
+// NewMemoryLimiterStore creates an empty in-memory store
+func NewMemoryLimiterStore() *MemoryLimiterStore {
+	return &MemoryLimiterStore{states: make(map[string]LimiterState)}
+}
+
+// How often the memory store sweeps expired states
+const limiterPruneInterval = 10 * time.Minute
+
+// Get returns the state of a key, or the zero state if there is none
+func (s *MemoryLimiterStore) Get(key string, now time.Time) (LimiterState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.live(key, now), nil
+}
+
+// Update applies fn to the state of a key and stores the result
+func (s *MemoryLimiterStore) Update(key string, now time.Time, fn func(*LimiterState)) (LimiterState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.pruned) > limiterPruneInterval {
+		for k, state := range s.states {
+			if now.After(state.ExpiresAt) {
+				delete(s.states, k)
+			}
+		}
+		s.pruned = now
+	}
+	state := s.live(key, now)
+	fn(&state)
+	s.states[key] = state
+	return state, nil
+}
+
+// Delete forgets a key
+func (s *MemoryLimiterStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	return nil
+}
+
+// live returns the unexpired state of a key. The caller holds the lock.
+func (s *MemoryLimiterStore) live(key string, now time.Time) LimiterState {
+	state, ok := s.states[key]
+	if !ok || now.After(state.ExpiresAt) {
+		return LimiterState{}
+	}
+	return state
+}
+
+// DBLimiterStore keeps states in the login_limit table so every server instance sees them
+type DBLimiterStore struct {
+	db *gorm.DB
+}
+
+// NewDBLimiterStore creates a limiter store backed by the database
+func NewDBLimiterStore(db *gorm.DB) *DBLimiterStore {
+	return &DBLimiterStore{db: db}
+}
+
+// limiterKeyHash keeps keys, which hold email addresses, to a fixed length
+func limiterKeyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// limiterStateOf returns the state a row holds, or the zero state if it has expired
+func limiterStateOf(row models.LoginLimit, now time.Time) LimiterState {
+	if now.After(row.ExpiresAt) {
+		return LimiterState{}
+	}
+	state := LimiterState{Failures: row.Failures, ExpiresAt: row.ExpiresAt}
+	if row.LastFailure != nil {
+		state.LastFailure = *row.LastFailure
+	}
+	if row.BlockedUntil != nil {
+		state.BlockedUntil = *row.BlockedUntil
+	}
+	return state
+}
+
+// Get returns the state of a key, or the zero state if there is none
+func (s *DBLimiterStore) Get(key string, now time.Time) (LimiterState, error) {
+	var row models.LoginLimit
+	err := s.db.Where("key_hash = ?", limiterKeyHash(key)).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return LimiterState{}, nil
+	}
+	if err != nil {
+		return LimiterState{}, err
+	}
+	return limiterStateOf(row, now), nil
+}
+
+// Update applies fn to the state of a key with its row locked and stores the result, clearing out
+// states that have expired
+func (s *DBLimiterStore) Update(key string, now time.Time, fn func(*LimiterState)) (LimiterState, error) {
+	if err := s.db.Where("expires_at < ?", now).Delete(&models.LoginLimit{}).Error; err != nil {
+		return LimiterState{}, err
+	}
+	var state LimiterState
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Make sure there is a row to lock, so that concurrent failures of a key wait for each other
+		row := models.LoginLimit{KeyHash: limiterKeyHash(key), ExpiresAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key_hash = ?", row.KeyHash).First(&row).Error; err != nil {
+			return err
+		}
+		state = limiterStateOf(row, now)
+		fn(&state)
+
+		updates := map[string]interface{}{"failures": state.Failures, "last_failure": nil, "blocked_until": nil, "expires_at": state.ExpiresAt}
+		if !state.LastFailure.IsZero() {
+			updates["last_failure"] = state.LastFailure
+		}
+		if !state.BlockedUntil.IsZero() {
+			updates["blocked_until"] = state.BlockedUntil
+		}
+		return tx.Model(&models.LoginLimit{}).Where("key_hash = ?", row.KeyHash).Updates(updates).Error
+	})
+	return state, err
+}
+
+// Delete forgets a key
+func (s *DBLimiterStore) Delete(key string) error {
+	return s.db.Where("key_hash = ?", limiterKeyHash(key)).Delete(&models.LoginLimit{}).Error
+}
+
+// LimiterPolicy describes how failures against one account or address are throttled. After FreeAttempts
+// failures each further attempt waits BaseDelay, doubling per failure up to MaxDelay. LockoutAfter failures
+// lock the key out for LockoutDuration. Failures are forgotten after ResetAfter without one.
+type LimiterPolicy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	ResetAfter      time.Duration
+}
+
+// Default policies. Addresses are shared by many users behind a NAT, so they get more room.
+var (
+	DefaultAccountPolicy = LimiterPolicy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutAfter:    10,
+		LockoutDuration: 30 * time.Minute,
+		ResetAfter:      24 * time.Hour,
+	}
+	DefaultAddressPolicy = LimiterPolicy{
+		FreeAttempts:    20,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    100,
+		LockoutDuration: time.Hour,
+		ResetAfter:      time.Hour,
+	}
+	// Every password reset request counts, so these allow a few emails an hour to an account and more from
+	// an address
+	DefaultResetAccountPolicy = LimiterPolicy{
+		FreeAttempts: 3,
+		BaseDelay:    5 * time.Minute,
+		MaxDelay:     time.Hour,
+		ResetAfter:   time.Hour,
+	}
+	DefaultResetAddressPolicy = LimiterPolicy{
+		FreeAttempts: 10,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		ResetAfter:   time.Hour,
+	}
+)
+
+// blockFor is how long a key with the given number of failures is blocked
+func (p LimiterPolicy) blockFor(failures int) time.Duration {
+	if p.LockoutAfter > 0 && failures >= p.LockoutAfter {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// LoginThrottle slows down password guessing against an account and from an address, and requests for
+// password reset emails, which are counted apart from failed logins
+type LoginThrottle struct {
+	Store        LimiterStore
+	Account      LimiterPolicy
+	Address      LimiterPolicy
+	ResetAccount LimiterPolicy
+	ResetAddress LimiterPolicy
+}
+
+// NewLoginThrottle creates a throttle with the default policies
+func NewLoginThrottle(store LimiterStore) *LoginThrottle {
+	return &LoginThrottle{
+		Store:        store,
+		Account:      DefaultAccountPolicy,
+		Address:      DefaultAddressPolicy,
+		ResetAccount: DefaultResetAccountPolicy,
+		ResetAddress: DefaultResetAddressPolicy,
+	}
+}
+
+// LoginLimiter is the throttle used by the login handler. The server replaces it with one backed by a
+// DBLimiterStore.
+var LoginLimiter = NewLoginThrottle(NewMemoryLimiterStore())
+
+func accountLimiterKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func addressLimiterKey(address string) string {
+	return "address:" + address
+}
+
+// limiterKey is a key of the store with the policy its attempts are counted under
+type limiterKey struct {
+	key    string
+	policy LimiterPolicy
+}
+
+// wait returns how long the caller must wait before another attempt at any of the keys
+func (t *LoginThrottle) wait(keys []limiterKey, now time.Time) (time.Duration, error) {
+	var wait time.Duration
+	for _, k := range keys {
+		state, err := t.Store.Get(k.key, now)
+		if err != nil {
+			return 0, err
+		}
+		if remaining := state.BlockedUntil.Sub(now); remaining > wait {
+			wait = remaining
+		}
+	}
+	return wait, nil
+}
+
+// record counts an attempt at each of the keys
+func (t *LoginThrottle) record(keys []limiterKey, now time.Time) error {
+	for _, k := range keys {
+		policy := k.policy
+		_, err := t.Store.Update(k.key, now, func(state *LimiterState) {
+			if now.Sub(state.LastFailure) > policy.ResetAfter {
+				state.Failures = 0
+			}
+			state.Failures++
+			state.LastFailure = now
+			state.BlockedUntil = now.Add(policy.blockFor(state.Failures))
+			state.ExpiresAt = now.Add(policy.ResetAfter)
+			if state.BlockedUntil.After(state.ExpiresAt) {
+				state.ExpiresAt = state.BlockedUntil
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *LoginThrottle) loginKeys(email, address string) []limiterKey {
+	return []limiterKey{{accountLimiterKey(email), t.Account}, {addressLimiterKey(address), t.Address}}
+}
+
+// Check returns how long the caller must wait before another login attempt for the account from the
+// address. Zero means the attempt may proceed.
+func (t *LoginThrottle) Check(email, address string, now time.Time) (time.Duration, error) {
+	return t.wait(t.loginKeys(email, address), now)
+}
+
+// Failure records a failed attempt against the account and from the address
+func (t *LoginThrottle) Failure(email, address string, now time.Time) error {
+	return t.record(t.loginKeys(email, address), now)
+}
+
+// Success clears the account's failures. The address keeps its count, so that logging into an account
+// the caller owns does not reset guessing at others.
+func (t *LoginThrottle) Success(email string) error {
+	return t.Store.Delete(accountLimiterKey(email))
+}
+
+// Unlock lifts a lockout on an account
+func (t *LoginThrottle) Unlock(email string) error {
+	return t.Store.Delete(accountLimiterKey(email))
+}
+
+// PasswordReset counts a request for a password reset email for the account from the address, unless
+// either has asked too often, in which case it returns how long the caller must wait. Accounts are
+// counted whether or not they exist, so the limit does not reveal who has one.
+func (t *LoginThrottle) PasswordReset(email, address string, now time.Time) (time.Duration, error) {
+	keys := []limiterKey{
+		{"reset:" + accountLimiterKey(email), t.ResetAccount},
+		{"reset:" + addressLimiterKey(address), t.ResetAddress},
+	}
+	wait, err := t.wait(keys, now)
+	if err != nil || wait > 0 {
+		return wait, err
+	}
+	return 0, t.record(keys, now)
+}
@@ -0,0 +1,235 @@
+// This file contains utilities for validating menus and the choices made when ordering from them
+//
+// The utilities here are as follows:
+// - ValidateMenuPrice
+// - ValidateModifierGroupLimits
+// - ValidateModifierSelection
+// - NormalizeMenuTags
+// - ValidateMenuItemTags
+// - MenuFilter
+// - ParseMenuFilter
+// - SplitListParam
+
+package utilities
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"waitress-backend/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Valid options for allergen tags, following the major food allergens
+var ValidAllergens = []string{
+	"gluten", "crustaceans", "molluscs", "eggs", "fish", "peanuts", "tree-nuts",
+	"soy", "dairy", "celery", "mustard", "sesame", "sulphites", "lupin",
+}
+
+// Valid options for dietary tags
+var ValidDietaryTags = []string{"vegetarian", "vegan", "pescatarian", "gluten-free", "dairy-free", "halal", "kosher"}
+
+// Allergen names accepted in filters that stand for several allergens
+var allergenAliases = map[string][]string{
+	"nuts":      {"peanuts", "tree-nuts"},
+	"shellfish": {"crustaceans", "molluscs"},
+}
+
+// Allergens a dietary tag rules out, used to reject contradictory tagging
+var dietaryExclusions = map[string][]string{
+	"vegetarian":  {"fish", "crustaceans", "molluscs"},
+	"vegan":       {"fish", "crustaceans", "molluscs", "eggs", "dairy"},
+	"pescatarian": {},
+	"gluten-free": {"gluten"},
+	"dairy-free":  {"dairy"},
+}
+
+// ValidateMenuPrice checks that a price or price adjustment is a non-negative amount in the menu's currency
+func ValidateMenuPrice(field string, value models.Money, currency string) error {
+	if value.Currency != currency {
+		return fmt.Errorf("%s must be in %s", field, currency)
+	}
+	if value.IsNegative() {
+		return fmt.Errorf("%s cannot be negative", field)
+	}
+	return nil
+}
+
+// ValidateModifierGroupLimits checks a group's selection limits. A MaxSelections of 0 means unlimited.
+func ValidateModifierGroupLimits(minSelections, maxSelections uint, modifierCount int) error {
+	if maxSelections != 0 && minSelections > maxSelections {
+		return fmt.Errorf("minSelections cannot be greater than maxSelections")
+	}
+	if int(minSelections) > modifierCount {
+		return fmt.Errorf("minSelections cannot be greater than the number of modifiers")
+	}
+	return nil
+}
+
+// ValidateModifierSelection checks the modifiers chosen for an item against the item's modifier groups.
+// Every chosen modifier must belong to one of the groups and be available, and each group's
+// minimum and maximum number of selections must be respected. The chosen modifiers are returned.
+func ValidateModifierSelection(groups []models.ModifierGroup, selected []uint) ([]models.Modifier, error) {
+	type owner struct {
+		group    *models.ModifierGroup
+		modifier models.Modifier
+	}
+	byID := make(map[uint]owner)
+	for i := range groups {
+		for _, modifier := range groups[i].Modifiers {
+			byID[modifier.ModifierID] = owner{group: &groups[i], modifier: modifier}
+		}
+	}
+
+	counts := make(map[uint]uint)
+	seen := make(map[uint]struct{})
+	chosen := make([]models.Modifier, 0, len(selected))
+	for _, id := range selected {
+		if _, duplicate := seen[id]; duplicate {
+			return nil, fmt.Errorf("modifier %d was chosen more than once", id)
+		}
+		seen[id] = struct{}{}
+		match, ok := byID[id]
+		if !ok {
+			return nil, fmt.Errorf("modifier %d is not offered for this item", id)
+		}
+		if !match.modifier.IsAvailable {
+			return nil, fmt.Errorf("%s is not available", match.modifier.Name)
+		}
+		counts[match.group.ModifierGroupID]++
+		chosen = append(chosen, match.modifier)
+	}
+
+	for _, group := range groups {
+		count := counts[group.ModifierGroupID]
+		if count < group.MinSelections {
+			return nil, fmt.Errorf("%s requires at least %d selection(s)", group.Name, group.MinSelections)
+		}
+		if group.MaxSelections != 0 && count > group.MaxSelections {
+			return nil, fmt.Errorf("%s allows at most %d selection(s)", group.Name, group.MaxSelections)
+		}
+	}
+	return chosen, nil
+}
+
+// NormalizeMenuTags lowercases and de-duplicates tags, expanding allergen aliases such as "nuts".
+// Every tag must be one of the valid options.
+func NormalizeMenuTags(kind string, tags []string) ([]string, error) {
+	valid := ValidAllergens
+	if kind == models.MenuItemTagDietary {
+		valid = ValidDietaryTags
+	}
+	seen := make(map[string]struct{})
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		expanded := []string{tag}
+		if aliases, ok := allergenAliases[tag]; ok && kind == models.MenuItemTagAllergen {
+			expanded = aliases
+		}
+		for _, name := range expanded {
+			if !isValidOption(name, valid) {
+				return nil, fmt.Errorf("invalid %s %q, must be one of: %s", kind, name, strings.Join(valid, ", "))
+			}
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			normalized = append(normalized, name)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// ValidateMenuItemTags checks that an item's dietary labels do not contradict its allergens,
+// e.g. a vegan item cannot contain dairy
+func ValidateMenuItemTags(allergens, dietary []string) error {
+	contains := make(map[string]struct{}, len(allergens))
+	for _, allergen := range allergens {
+		contains[allergen] = struct{}{}
+	}
+	for _, label := range dietary {
+		for _, excluded := range dietaryExclusions[label] {
+			if _, ok := contains[excluded]; ok {
+				return fmt.Errorf("an item containing %s cannot be tagged %s", excluded, label)
+			}
+		}
+	}
+	return nil
+}
+
+// MenuFilter restricts menu items to those free of every excluded allergen and carrying every required dietary tag
+type MenuFilter struct {
+	ExcludeAllergens []string
+	RequireDietary   []string
+}
+
+// IsEmpty reports whether the filter has no constraints
+func (f MenuFilter) IsEmpty() bool {
+	return len(f.ExcludeAllergens) == 0 && len(f.RequireDietary) == 0
+}
+
+// ParseMenuFilter validates the "must not contain" allergens and "must be" dietary tags of a search
+func ParseMenuFilter(excludeAllergens, requireDietary []string) (MenuFilter, error) {
+	var filter MenuFilter
+	var err error
+	if filter.ExcludeAllergens, err = NormalizeMenuTags(models.MenuItemTagAllergen, excludeAllergens); err != nil {
+		return MenuFilter{}, err
+	}
+	if filter.RequireDietary, err = NormalizeMenuTags(models.MenuItemTagDietary, requireDietary); err != nil {
+		return MenuFilter{}, err
+	}
+	return filter, nil
+}
+
+// SplitListParam splits a comma separated query parameter such as "peanuts,gluten"
+func SplitListParam(value string) []string {
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// TableName returns the table db maps a model to, e.g. "menu_item" under the server's singular table
+// names. It panics for a type that is not a model.
+func TableName(db *gorm.DB, model interface{}) string {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		panic(fmt.Sprintf("TableName: %v", err))
+	}
+	return stmt.Schema.Table
+}
+
+// SQL returns a condition matching menu items that satisfy the filter. column is the
+// menu item ID column to match against, e.g. "mi.menu_id".
+func (f MenuFilter) SQL(db *gorm.DB, column string) (string, []interface{}) {
+	tags := TableName(db, &models.MenuItemTag{})
+	var conditions []string
+	var args []interface{}
+	if len(f.ExcludeAllergens) > 0 {
+		conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM "+tags+" t WHERE t.menu_id = "+column+" AND t.kind = ? AND t.tag IN ?)")
+		args = append(args, models.MenuItemTagAllergen, f.ExcludeAllergens)
+	}
+	for _, label := range f.RequireDietary {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM "+tags+" t WHERE t.menu_id = "+column+" AND t.kind = ? AND t.tag = ?)")
+		args = append(args, models.MenuItemTagDietary, label)
+	}
+	if len(conditions) == 0 {
+		return "1 = 1", nil
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// Apply restricts a query on menu items to the items matching the filter
+func (f MenuFilter) Apply(query *gorm.DB) *gorm.DB {
+	if f.IsEmpty() {
+		return query
+	}
+	condition, args := f.SQL(query, TableName(query, &models.MenuItem{})+".menu_id")
+	return query.Where(condition, args...)
+}
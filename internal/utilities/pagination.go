@@ -0,0 +1,57 @@
+// This file contains utilities for paginating list endpoints
+//
+// The utilities here are as follows:
+// - Page
+// - ParsePage
+// - EscapeLike
+
+package utilities
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Page sizes of list endpoints
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// Page is a 1-based page of a list
+type Page struct {
+	Number int `json:"page"`
+	Size   int `json:"pageSize"`
+}
+
+// Offset returns how many rows precede the page
+func (p Page) Offset() int {
+	return (p.Number - 1) * p.Size
+}
+
+// ParsePage reads the page and pageSize query parameters, which default to the first page of
+// DefaultPageSize rows
+func ParsePage(page, pageSize string) (Page, error) {
+	result := Page{Number: 1, Size: DefaultPageSize}
+	if page != "" {
+		number, err := strconv.Atoi(page)
+		if err != nil || number < 1 {
+			return result, fmt.Errorf("page must be a positive number")
+		}
+		result.Number = number
+	}
+	if pageSize != "" {
+		size, err := strconv.Atoi(pageSize)
+		if err != nil || size < 1 || size > MaxPageSize {
+			return result, fmt.Errorf("pageSize must be between 1 and %d", MaxPageSize)
+		}
+		result.Size = size
+	}
+	return result, nil
+}
+
+// EscapeLike escapes the wildcards of a LIKE pattern so a search term matches literally
+func EscapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+}
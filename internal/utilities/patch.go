@@ -0,0 +1,54 @@
+// This file contains utilities for JSON Merge Patch (RFC 7396) request bodies
+//
+// The utilities here are as follows:
+// - PatchField
+// - DecodeMergePatch
+
+package utilities
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// PatchField is one member of a merge patch. It tells apart a member that was left out (leave the
+// value unchanged), one sent as null (clear the value) and one sent with a value (replace it).
+type PatchField[T any] struct {
+	Set   bool // The member was present in the patch
+	Null  bool // The member was present and null
+	Value T
+}
+
+// UnmarshalJSON records that the member was present and decodes its value unless it is null
+func (p *PatchField[T]) UnmarshalJSON(data []byte) error {
+	p.Set = true
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		p.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &p.Value)
+}
+
+// HasValue reports whether the patch sets a new, non-null value
+func (p PatchField[T]) HasValue() bool {
+	return p.Set && !p.Null
+}
+
+// DecodeMergePatch decodes a merge patch document into dest, rejecting members dest does not define
+// so that typos and read-only fields are reported instead of silently ignored
+func DecodeMergePatch(body io.Reader, dest interface{}) error {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dest); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errors.New("request body must be a JSON object")
+		}
+		return err
+	}
+	if decoder.More() {
+		return errors.New("request body must contain a single JSON object")
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+// This file contains utilities for the authorization policy that maps permissions to user types
+//
+// The utilities here are as follows:
+// - Policy
+// - ParsePolicy
+// - LoadPolicyFromEnv
+// - SetPolicy
+// - CurrentPolicy
+// - HasPermission
+// - RequirePermission
+
+package utilities
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permissions checked by handlers rather than on routes. A policy must define them.
+const (
+	PermissionRestaurantAdminister = "restaurant:administer" // Act as the owner of every restaurant
+	PermissionRestaurantTransfer   = "restaurant:transfer"   // Change a restaurant's owner
+	PermissionUserManage           = "user:manage"           // Change other users' accounts
+)
+
+var codePermissions = []string{PermissionRestaurantAdminister, PermissionRestaurantTransfer, PermissionUserManage}
+
+// defaultPolicy is used when AUTH_POLICY_FILE is not set
+//
+//go:embed policy.json
+var defaultPolicy []byte
+
+// Permission names are a resource and an action, e.g. reservation:read
+var permissionPattern = regexp.MustCompile(`^[a-z][a-z_]*:[a-z][a-z_]*$`)
+
+// policyFile is the JSON form of a policy. A role is granted every permission of the roles it inherits.
+type policyFile struct {
+	Roles map[string]struct {
+		Inherits []string `json:"inherits"`
+	} `json:"roles"`
+	Permissions map[string][]string `json:"permissions"`
+}
+
+// Policy says which user types hold each permission
+type Policy struct {
+	permissions map[string]map[UserType]struct{}
+}
+
+// ParsePolicy reads a JSON policy and checks that it only names known user types, that inheritance has
+// no cycles and that every permission the code checks is defined.
+func ParsePolicy(data []byte) (*Policy, error) {
+	var file policyFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	if len(file.Roles) == 0 {
+		return nil, fmt.Errorf("policy: no roles defined")
+	}
+
+	known := map[UserType]struct{}{}
+	for _, userType := range AllUserTypes {
+		known[userType] = struct{}{}
+	}
+	for role, def := range file.Roles {
+		if _, ok := known[UserType(role)]; !ok {
+			return nil, fmt.Errorf("policy: unknown role %q", role)
+		}
+		for _, parent := range def.Inherits {
+			if _, ok := file.Roles[parent]; !ok {
+				return nil, fmt.Errorf("policy: role %q inherits undefined role %q", role, parent)
+			}
+		}
+	}
+
+	// ancestors returns a role and every role it inherits from
+	var ancestors func(role string, path map[string]bool) (map[string]struct{}, error)
+	ancestors = func(role string, path map[string]bool) (map[string]struct{}, error) {
+		if path[role] {
+			return nil, fmt.Errorf("policy: role %q inherits from itself", role)
+		}
+		path[role] = true
+		defer delete(path, role)
+		result := map[string]struct{}{role: {}}
+		for _, parent := range file.Roles[role].Inherits {
+			inherited, err := ancestors(parent, path)
+			if err != nil {
+				return nil, err
+			}
+			for r := range inherited {
+				result[r] = struct{}{}
+			}
+		}
+		return result, nil
+	}
+
+	policy := &Policy{permissions: map[string]map[UserType]struct{}{}}
+	for permission, roles := range file.Permissions {
+		if !permissionPattern.MatchString(permission) {
+			return nil, fmt.Errorf("policy: invalid permission name %q", permission)
+		}
+		granted := map[string]struct{}{}
+		for _, role := range roles {
+			if _, ok := file.Roles[role]; !ok {
+				return nil, fmt.Errorf("policy: permission %q is granted to undefined role %q", permission, role)
+			}
+			granted[role] = struct{}{}
+		}
+		holders := map[UserType]struct{}{}
+		for role := range file.Roles {
+			inherited, err := ancestors(role, map[string]bool{})
+			if err != nil {
+				return nil, err
+			}
+			for r := range inherited {
+				if _, ok := granted[r]; ok {
+					holders[UserType(role)] = struct{}{}
+					break
+				}
+			}
+		}
+		policy.permissions[permission] = holders
+	}
+	for _, permission := range codePermissions {
+		if !policy.Defines(permission) {
+			return nil, fmt.Errorf("policy: permission %q is not defined", permission)
+		}
+	}
+	return policy, nil
+}
+
+// Defines reports whether the policy has the permission
+func (p *Policy) Defines(permission string) bool {
+	_, ok := p.permissions[permission]
+	return ok
+}
+
+// Allows reports whether users of the auth type hold the permission
+func (p *Policy) Allows(authType, permission string) bool {
+	_, ok := p.permissions[permission][UserType(authType)]
+	return ok
+}
+
+// Permissions returns the names of the policy's permissions in order
+func (p *Policy) Permissions() []string {
+	names := make([]string, 0, len(p.permissions))
+	for name := range p.permissions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadPolicyFromEnv reads the policy from the file named by AUTH_POLICY_FILE, or the built-in policy
+func LoadPolicyFromEnv() (*Policy, error) {
+	path := os.Getenv("AUTH_POLICY_FILE")
+	if path == "" {
+		return ParsePolicy(defaultPolicy)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	return ParsePolicy(data)
+}
+
+var (
+	policyMu      sync.RWMutex
+	policyOnce    sync.Once
+	currentPolicy *Policy
+)
+
+// SetPolicy replaces the policy used by RequirePermission and HasPermission. Routes registered before
+// the call keep checking the policy they were registered with.
+func SetPolicy(policy *Policy) {
+	policyOnce.Do(func() {})
+	policyMu.Lock()
+	currentPolicy = policy
+	policyMu.Unlock()
+}
+
+// CurrentPolicy returns the policy set by SetPolicy, or the built-in policy
+func CurrentPolicy() *Policy {
+	policyOnce.Do(func() {
+		policy, err := ParsePolicy(defaultPolicy)
+		if err != nil {
+			panic(err)
+		}
+		currentPolicy = policy
+	})
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	return currentPolicy
+}
+
+// HasPermission reports whether users of the auth type hold the permission under the current policy
+func HasPermission(authType, permission string) bool {
+	return CurrentPolicy().Allows(authType, permission)
+}
+
+// Gin middleware that authenticates the user and ensures they hold the permission. It panics when the
+// current policy does not define the permission, so a misspelt permission stops route registration.
+func RequirePermission(permission string) gin.HandlerFunc {
+	policy := CurrentPolicy()
+	if !policy.Defines(permission) {
+		panic(fmt.Sprintf("RequirePermission: permission %q is not defined by the authorization policy", permission))
+	}
+	return func(c *gin.Context) {
+		userID, authType, ok := authenticateUser(c)
+		if !ok {
+			return
+		}
+		if !policy.Allows(authType, permission) {
+			fmt.Printf("User type %s lacks permission %s\n", authType, permission)
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to do this"})
+			c.Abort()
+			return
+		}
+
+		c.Set(ContextUserIDKey, userID)
+		c.Set(ContextAuthTypeKey, authType)
+		c.Next()
+	}
+}
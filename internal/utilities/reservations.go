@@ -0,0 +1,139 @@
+// This file contains utilities for booking reservations against restaurant tables
+//
+// The utilities here are as follows:
+// - ReservationWindow
+// - ReservationWindowsOverlap
+// - NextAvailableTime
+// - FindReservationConflict
+// - ReserveTable
+
+package utilities
+
+import (
+	"errors"
+	"time"
+	"waitress-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Booking limits applied to reservation requests
+const (
+	DefaultReservationDuration = 90 * time.Minute
+	MinReservationDuration     = 15 * time.Minute
+	MaxReservationDuration     = 6 * time.Hour
+	DefaultTurnTime            = 15 * time.Minute // Time to clear and reset a table between seatings
+)
+
+// Errors returned while reserving a table
+var (
+	ErrTableNotFound      = errors.New("table not found for this restaurant")
+	ErrTableOutOfService  = errors.New("table is currently out of service")
+	ErrTableTooSmall      = errors.New("table cannot seat the requested party size")
+	ErrTableAlreadyBooked = errors.New("table is already booked for the requested time")
+	ErrNoTableAvailable   = errors.New("no table is available for the requested time and party size")
+)
+
+// ReservationWindow is the half-open interval [Start, End) a booking occupies a table.
+type ReservationWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// ReservationWindowsOverlap reports whether two half-open booking windows intersect.
+// Back-to-back bookings (one ending exactly when the other starts) do not overlap.
+func ReservationWindowsOverlap(a, b ReservationWindow) bool {
+	return a.Start.Before(b.End) && b.Start.Before(a.End)
+}
+
+// WithTurnTime extends the window by the time needed to turn the table for the next party.
+func (w ReservationWindow) WithTurnTime(turn time.Duration) ReservationWindow {
+	return ReservationWindow{Start: w.Start, End: w.End.Add(turn)}
+}
+
+// NextAvailableTime returns the earliest start at or after window.Start at which a booking of the
+// same length fits between the existing bookings, keeping turn time free after every seating.
+// bookings must be sorted by start time.
+func NextAvailableTime(bookings []ReservationWindow, window ReservationWindow, turn time.Duration) time.Time {
+	duration := window.End.Sub(window.Start)
+	start := window.Start
+	for _, booking := range bookings {
+		candidate := ReservationWindow{Start: start, End: start.Add(duration)}.WithTurnTime(turn)
+		if ReservationWindowsOverlap(candidate, booking.WithTurnTime(turn)) {
+			start = booking.End.Add(turn)
+		}
+	}
+	return start
+}
+
+// FindReservationConflict returns the first active reservation on a table that overlaps the window,
+// counting DefaultTurnTime after both bookings. excludeID lets a reservation being modified ignore
+// its own row; pass 0 when creating.
+func FindReservationConflict(tx *gorm.DB, tableID uint, window ReservationWindow, excludeID uint) (*models.Reservation, error) {
+	var conflict models.Reservation
+	query := tx.Where("table_id = ? AND status = ? AND time < ? AND end_time > ?",
+		tableID, models.ReservationStatusBooked, window.End.Add(DefaultTurnTime), window.Start.Add(-DefaultTurnTime))
+	if excludeID != 0 {
+		query = query.Where("reservation_id <> ?", excludeID)
+	}
+	err := query.Order("time").First(&conflict).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &conflict, nil
+}
+
+// ReserveTable locks and returns a table that can seat the party for the window.
+// When tableID is nil the smallest free table that fits the party is chosen.
+// It must be called inside a transaction so the row lock is held until the booking is written.
+func ReserveTable(tx *gorm.DB, restaurantID uint, tableID *uint, partySize uint, window ReservationWindow, excludeID uint) (*models.Table, error) {
+	if tableID != nil {
+		var table models.Table
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("table_id = ? AND restaurant_id = ?", *tableID, restaurantID).
+			First(&table).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTableNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !table.IsAvailable {
+			return nil, ErrTableOutOfService
+		}
+		if table.Capacity < partySize {
+			return nil, ErrTableTooSmall
+		}
+		conflict, err := FindReservationConflict(tx, table.TableID, window, excludeID)
+		if err != nil {
+			return nil, err
+		}
+		if conflict != nil {
+			return nil, ErrTableAlreadyBooked
+		}
+		return &table, nil
+	}
+
+	var candidates []models.Table
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("restaurant_id = ? AND is_available = ? AND capacity >= ?", restaurantID, true, partySize).
+		Order("capacity, table_id").
+		Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range candidates {
+		conflict, err := FindReservationConflict(tx, candidates[i].TableID, window, excludeID)
+		if err != nil {
+			return nil, err
+		}
+		if conflict == nil {
+			return &candidates[i], nil
+		}
+	}
+	return nil, ErrNoTableAvailable
+}
@@ -0,0 +1,122 @@
+// This file contains utilities for authorizing users against their role in a restaurant
+//
+// The utilities here are as follows:
+// - ValidStaffRoles
+// - IsValidStaffRole
+// - ResolveRestaurantRole
+// - HasRestaurantRole
+// - GetRestaurantRole
+// - RestaurantRoleRequired
+
+package utilities
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"waitress-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Valid options for a staff member's role in a restaurant
+var ValidStaffRoles = []string{
+	models.StaffRoleOwner, models.StaffRoleManager, models.StaffRoleHost, models.StaffRoleServer, models.StaffRoleKitchen,
+}
+
+// IsValidStaffRole checks if role is one of the valid staff roles
+func IsValidStaffRole(role string) bool {
+	return isValidOption(role, ValidStaffRoles)
+}
+
+// Role sets used to protect restaurant routes
+var (
+	RestaurantManagementRoles   = []string{models.StaffRoleOwner, models.StaffRoleManager}
+	RestaurantFrontOfHouseRoles = []string{models.StaffRoleOwner, models.StaffRoleManager, models.StaffRoleHost, models.StaffRoleServer}
+	RestaurantKitchenRoles      = []string{models.StaffRoleOwner, models.StaffRoleManager, models.StaffRoleKitchen}
+	RestaurantAllRoles          = ValidStaffRoles
+)
+
+// ContextRestaurantRoleKey is where RestaurantRoleRequired stores the caller's role in the restaurant
+const ContextRestaurantRoleKey = "restaurantRole"
+
+// ResolveRestaurantRole returns the authenticated user's role in a restaurant, or "" when they have none.
+// Users with the restaurant:administer permission act as owners of every restaurant, and the restaurant's
+// OwnerID is its owner even without a staff record. gorm.ErrRecordNotFound is returned when the restaurant does not exist.
+func ResolveRestaurantRole(db *gorm.DB, c *gin.Context, restaurantID uint) (string, error) {
+	var restaurant models.Restaurant
+	if err := db.Select("restaurant_id", "owner_id").First(&restaurant, restaurantID).Error; err != nil {
+		return "", err
+	}
+	userID, ok := GetAuthenticatedUserID(c)
+	if !ok {
+		return "", nil
+	}
+	if HasPermission(GetAuthenticatedAuthType(c), PermissionRestaurantAdminister) || restaurant.OwnerID == userID {
+		return models.StaffRoleOwner, nil
+	}
+
+	var staff models.Staff
+	err := db.Where("user_id = ? AND restaurant_id = ? AND is_active = ?", userID, restaurantID, true).First(&staff).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return staff.Role, nil
+}
+
+// HasRestaurantRole reports whether role is one of roles
+func HasRestaurantRole(role string, roles ...string) bool {
+	if role == "" {
+		return false
+	}
+	for _, allowed := range roles {
+		if role == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRestaurantRole returns the role resolved by RestaurantRoleRequired
+func GetRestaurantRole(c *gin.Context) string {
+	return c.GetString(ContextRestaurantRoleKey)
+}
+
+// Gin middleware that ensures the authenticated user holds one of roles in the restaurant named by the
+// :restaurantId route parameter. It must run after RequirePermission.
+func RestaurantRoleRequired(db *gorm.DB, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		restaurantID, err := strconv.ParseUint(c.Param("restaurantId"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid restaurant ID format"})
+			c.Abort()
+			return
+		}
+
+		role, err := ResolveRestaurantRole(db, c, uint(restaurantID))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found"})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			fmt.Println("Error resolving restaurant role:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking restaurant access"})
+			c.Abort()
+			return
+		}
+		if !HasRestaurantRole(role, roles...) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this restaurant"})
+			c.Abort()
+			return
+		}
+
+		c.Set(ContextRestaurantRoleKey, role)
+		c.Next()
+	}
+}
@@ -0,0 +1,63 @@
+// This file contains utilities for working with restaurant time zones
+//
+// The utilities here are as follows:
+// - ValidateTimeZone
+// - ParseRestaurantTime
+// - LocalizedTime
+// - Localize
+
+package utilities
+
+import (
+	"errors"
+	"time"
+)
+
+// Layouts accepted for wall clock times without an offset, interpreted in the restaurant's zone
+var restaurantTimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// ValidateTimeZone checks that a name is a known IANA time zone such as America/Toronto
+func ValidateTimeZone(name string) error {
+	if name == "" {
+		return errors.New("time zone is required")
+	}
+	if _, err := time.LoadLocation(name); err != nil {
+		return errors.New("unknown time zone " + name + ", expected an IANA name such as America/New_York")
+	}
+	return nil
+}
+
+// ParseRestaurantTime parses a timestamp sent by a client. RFC 3339 timestamps keep their offset;
+// wall clock times without an offset are read in the restaurant's location. The result is in UTC.
+func ParseRestaurantTime(value string, loc *time.Location) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed.UTC(), nil
+	}
+	for _, layout := range restaurantTimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return parsed.UTC(), nil
+		}
+	}
+	return time.Time{}, errors.New("invalid time " + value + ", expected RFC 3339 or a local time such as 2024-05-01T19:00")
+}
+
+// LocalizedTime carries an instant both in UTC and as the restaurant's local wall clock time
+type LocalizedTime struct {
+	UTC      time.Time `json:"utc"`
+	Local    string    `json:"local"` // RFC 3339 with the restaurant's offset
+	TimeZone string    `json:"timeZone"`
+}
+
+// Localize converts an instant into a LocalizedTime for the given location
+func Localize(t time.Time, loc *time.Location) LocalizedTime {
+	return LocalizedTime{
+		UTC:      t.UTC(),
+		Local:    t.In(loc).Format(time.RFC3339),
+		TimeZone: loc.String(),
+	}
+}
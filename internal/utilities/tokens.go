@@ -0,0 +1,207 @@
+// This file contains utilities for issuing, rotating and revoking authentication tokens
+//
+// The utilities here are as follows:
+// - NewTokenFamilyID
+// - NewAccessToken
+// - ParseAccessToken
+// - NewRefreshToken
+// - HashRefreshToken
+// - TokenRevocationStore
+// - NewDBTokenRevocationStore
+// - RevokeTokenFamily
+// - RevokeAccessToken
+// - RevokeUserTokens
+
+package utilities
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+	"waitress-backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// Lifetimes of issued tokens. Access tokens are short-lived; a device keeps its session
+// by exchanging its refresh token before the access token runs out.
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// ErrTokenRevoked is returned for an access token that was revoked before it expired
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// randomToken returns n random bytes encoded for use in URLs and headers
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewTokenFamilyID returns an identifier for a new refresh token family
+func NewTokenFamilyID() (string, error) {
+	return randomToken(24)
+}
+
+// NewAccessToken signs a short-lived access token for the user. familyID ties the token to the
+// refresh token family it was issued with, so revoking the family also revokes the token.
+func NewAccessToken(user models.User, familyID string) (string, error) {
+	jti, err := randomToken(24)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims := JWTClaims{
+		UserID:    user.UserID,
+		Email:     user.Email,
+		AuthType:  user.AuthType,
+		SessionID: familyID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+		},
+	}
+	return SigningKeys().Sign(claims)
+}
+
+// ParseAccessToken verifies an access token and returns its claims. Revocation is not checked.
+func ParseAccessToken(tokenString string) (*JWTClaims, error) {
+	return validateJWTToken(tokenString)
+}
+
+// NewRefreshToken returns a new refresh token and the hash to store for it
+func NewRefreshToken() (string, string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken returns the stored form of a refresh token
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenRevocationStore records access token IDs and token families revoked before they expire,
+// and when each user last signed out everywhere
+type TokenRevocationStore interface {
+	Revoke(tokenID string, expiresAt time.Time) error
+	IsRevoked(tokenIDs ...string) (bool, error)
+	UserTokensRevokedAt(userID uint) (*time.Time, error)
+}
+
+// TokenRevocations is checked by RequirePermission for every bearer token. It is nil until the server
+// configures it, in which case no revocation check is made.
+var TokenRevocations TokenRevocationStore
+
+// DBTokenRevocationStore keeps revocations in the revoked_token table so every server instance sees them
+type DBTokenRevocationStore struct {
+	db *gorm.DB
+}
+
+// NewDBTokenRevocationStore creates a revocation store backed by the database
+func NewDBTokenRevocationStore(db *gorm.DB) *DBTokenRevocationStore {
+	return &DBTokenRevocationStore{db: db}
+}
+
+// Revoke records tokenID as revoked until expiresAt, clearing out revocations that have lapsed
+func (s *DBTokenRevocationStore) Revoke(tokenID string, expiresAt time.Time) error {
+	if tokenID == "" {
+		return nil
+	}
+	now := time.Now()
+	if err := s.db.Where("expires_at <= ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return s.db.Save(&models.RevokedToken{TokenID: tokenID, ExpiresAt: expiresAt, CreatedAt: now}).Error
+}
+
+// IsRevoked reports whether any of tokenIDs has been revoked
+func (s *DBTokenRevocationStore) IsRevoked(tokenIDs ...string) (bool, error) {
+	ids := make([]string, 0, len(tokenIDs))
+	for _, id := range tokenIDs {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return false, nil
+	}
+	var count int64
+	err := s.db.Model(&models.RevokedToken{}).Where("token_id IN ? AND expires_at > ?", ids, time.Now()).Count(&count).Error
+	return count > 0, err
+}
+
+// UserTokensRevokedAt returns when the user last signed out everywhere, or nil if they never have
+func (s *DBTokenRevocationStore) UserTokensRevokedAt(userID uint) (*time.Time, error) {
+	var user models.User
+	if err := s.db.Select("user_id", "tokens_revoked_at").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return user.TokensRevokedAt, nil
+}
+
+// RevokeUserTokens signs a user out everywhere: every token family is revoked and tokens or
+// sessions issued before now are rejected
+func RevokeUserTokens(db *gorm.DB, userID uint) error {
+	if err := db.Model(&models.User{}).Where("user_id = ?", userID).Update("tokens_revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	var families []string
+	err := db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Distinct().Pluck("family_id", &families).Error
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		if err := RevokeTokenFamily(db, family); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeTokenFamily revokes every refresh token of a family and the access tokens issued with them
+func RevokeTokenFamily(db *gorm.DB, familyID string) error {
+	if familyID == "" {
+		return nil
+	}
+	now := time.Now()
+	err := db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+	if err != nil {
+		return err
+	}
+	if TokenRevocations == nil {
+		return nil
+	}
+	return TokenRevocations.Revoke(familyID, now.Add(AccessTokenTTL))
+}
+
+// RevokeAccessToken revokes a still valid access token together with the token family it was issued with
+func RevokeAccessToken(db *gorm.DB, tokenString string) error {
+	claims, err := ParseAccessToken(tokenString)
+	if err != nil {
+		return nil // Expired or invalid tokens cannot be used anyway
+	}
+	if err := RevokeTokenFamily(db, claims.SessionID); err != nil {
+		return err
+	}
+	if TokenRevocations == nil || claims.ExpiresAt == nil {
+		return nil
+	}
+	return TokenRevocations.Revoke(claims.ID, claims.ExpiresAt.Time)
+}
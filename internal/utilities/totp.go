@@ -0,0 +1,268 @@
+// This file contains utilities for TOTP two-factor authentication (RFC 6238)
+//
+// The utilities here are as follows:
+// - NewTOTPSecret
+// - TOTPCode
+// - TOTPURI
+// - TwoFactorRequired
+// - ParseUserTypes
+// - GetTwoFactor
+// - StartTwoFactorEnrollment
+// - VerifyTwoFactorCode
+// - NewRecoveryCodes
+// - DisableTwoFactor
+
+package utilities
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"waitress-backend/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Parameters of the codes, which are the defaults every authenticator app supports
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+	totpSkew   = 1 // Steps either side of the current one still accepted, for clock drift
+)
+
+// Name under which accounts appear in authenticator apps
+const TOTPIssuer = "Waitress"
+
+// Number of recovery codes issued at a time
+const RecoveryCodeCount = 10
+
+// Errors returned by two-factor authentication
+var (
+	ErrTwoFactorNotSetUp       = errors.New("two-factor authentication is not set up")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorCodeInvalid    = errors.New("two-factor code is invalid")
+)
+
+// TwoFactorRequiredRoles are the auth types that must use two-factor authentication to log in.
+// Set from TWO_FACTOR_REQUIRED_ROLES by the server; other users may opt in.
+var TwoFactorRequiredRoles = map[UserType]struct{}{}
+
+// TwoFactorRequired reports whether users of the auth type must use two-factor authentication
+func TwoFactorRequired(authType string) bool {
+	_, ok := TwoFactorRequiredRoles[UserType(authType)]
+	return ok
+}
+
+// ParseUserTypes parses a comma separated list of auth types, e.g. "dev,admin_super,admin"
+func ParseUserTypes(list string) (map[UserType]struct{}, error) {
+	known := map[UserType]struct{}{}
+	for _, userType := range AllUserTypes {
+		known[userType] = struct{}{}
+	}
+	types := map[UserType]struct{}{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := known[UserType(name)]; !ok {
+			return nil, fmt.Errorf("unknown auth type %q", name)
+		}
+		types[UserType(name)] = struct{}{}
+	}
+	return types, nil
+}
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret returns a random base32 secret for an authenticator app
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// totpStep returns the time step a moment falls in
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(totpPeriod/time.Second)
+}
+
+// totpCodeAt computes the code of a time step
+func totpCodeAt(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// decodeTOTPSecret accepts secrets as users type them, in any case and with or without padding
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.TrimRight(strings.ToUpper(strings.ReplaceAll(secret, " ", "")), "=")
+	return totpEncoding.DecodeString(secret)
+}
+
+// TOTPCode returns the code an authenticator app shows for the secret at a moment
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return totpCodeAt(key, totpStep(t)), nil
+}
+
+// matchTOTP returns the step of the code if it is valid at now and newer than lastUsedStep
+func matchTOTP(secret, code string, now time.Time, lastUsedStep int64) (int64, bool) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := totpStep(now)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastUsedStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCodeAt(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPURI returns the otpauth:// URI that authenticator apps import, usually as a QR code
+func TOTPURI(account, secret string) string {
+	query := url.Values{
+		"secret":    {secret},
+		"issuer":    {TOTPIssuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(int(totpPeriod / time.Second))},
+	}
+	label := url.PathEscape(TOTPIssuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// normalizeRecoveryCode accepts recovery codes in any case and with or without the separator
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// GetTwoFactor returns the user's two-factor settings, or nil if they never started enrolling
+func GetTwoFactor(db *gorm.DB, userID uint) (*models.TwoFactor, error) {
+	var twoFactor models.TwoFactor
+	err := db.Where("user_id = ?", userID).First(&twoFactor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &twoFactor, nil
+}
+
+// StartTwoFactorEnrollment gives the user a new pending secret, replacing any earlier pending one
+func StartTwoFactorEnrollment(db *gorm.DB, userID uint) (string, error) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var existing models.TwoFactor
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return tx.Omit("User").Create(&models.TwoFactor{UserID: userID, Secret: secret}).Error
+		case err != nil:
+			return err
+		case existing.EnabledAt != nil:
+			return ErrTwoFactorAlreadyEnabled
+		default:
+			return tx.Model(&existing).Updates(map[string]interface{}{"secret": secret, "last_used_step": 0}).Error
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// VerifyTwoFactorCode checks a TOTP code or unused recovery code of the user and consumes it. A valid
+// TOTP code completes a pending enrollment, which is reported by activated. Call it inside a transaction.
+func VerifyTwoFactorCode(tx *gorm.DB, userID uint, code string, now time.Time) (activated bool, err error) {
+	var twoFactor models.TwoFactor
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&twoFactor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, ErrTwoFactorNotSetUp
+	}
+	if err != nil {
+		return false, err
+	}
+
+	code = strings.TrimSpace(code)
+	if step, ok := matchTOTP(twoFactor.Secret, code, now, twoFactor.LastUsedStep); ok {
+		updates := map[string]interface{}{"last_used_step": step}
+		if twoFactor.EnabledAt == nil {
+			updates["enabled_at"] = now
+		}
+		if err := tx.Model(&twoFactor).Updates(updates).Error; err != nil {
+			return false, err
+		}
+		return twoFactor.EnabledAt == nil, nil
+	}
+
+	// Recovery codes only exist once enrollment is complete
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, HashRefreshToken(normalizeRecoveryCode(code))).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, ErrTwoFactorCodeInvalid
+	}
+	return false, nil
+}
+
+// NewRecoveryCodes replaces the user's recovery codes and returns the new ones; only hashes are stored
+func NewRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, RecoveryCodeCount)
+	rows := make([]models.RecoveryCode, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(totpEncoding.EncodeToString(b)) // 8 characters
+		codes = append(codes, raw[:4]+"-"+raw[4:])
+		rows = append(rows, models.RecoveryCode{UserID: userID, CodeHash: HashRefreshToken(raw)})
+	}
+	if err := tx.Omit("User").Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor removes the user's secret and recovery codes
+func DisableTwoFactor(tx *gorm.DB, userID uint) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.TwoFactor{}).Error
+}
@@ -0,0 +1,165 @@
+// This file contains utilities for the single-use tokens mailed to users
+//
+// The utilities here are as follows:
+// - UserTokenTTL
+// - IssueUserToken
+// - LookupUserToken
+// - RedeemUserToken
+// - EmailVerificationRequired
+// - AppURL
+
+package utilities
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+	"waitress-backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Lifetimes of the tokens given to users. A password reset grants access to the account, so it is short.
+const (
+	EmailVerificationTokenTTL = 48 * time.Hour
+	PasswordResetTokenTTL     = time.Hour
+	LoginChallengeTokenTTL    = 5 * time.Minute
+)
+
+// Errors returned when redeeming a user token
+var (
+	ErrUserTokenInvalid = errors.New("token is invalid or has already been used")
+	ErrUserTokenExpired = errors.New("token has expired")
+)
+
+// UserTokenTTL returns how long a token for the purpose stays valid
+func UserTokenTTL(purpose string) time.Duration {
+	switch purpose {
+	case models.UserTokenPurposePasswordReset:
+		return PasswordResetTokenTTL
+	case models.UserTokenPurposeLoginChallenge:
+		return LoginChallengeTokenTTL
+	default:
+		return EmailVerificationTokenTTL
+	}
+}
+
+// IssueUserToken creates a token for the purpose and returns it; only its hash is stored. Earlier
+// unused tokens of the user for the same purpose stop working.
+func IssueUserToken(db *gorm.DB, userID uint, purpose string, now time.Time) (string, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", now).Error
+		if err != nil {
+			return err
+		}
+		return tx.Omit("User").Create(&models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: HashRefreshToken(token),
+			ExpiresAt: now.Add(UserTokenTTL(purpose)),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// LookupUserToken returns an unused, unexpired token for the purpose without redeeming it
+func LookupUserToken(db *gorm.DB, token, purpose string, now time.Time) (*models.UserToken, error) {
+	var stored models.UserToken
+	err := db.Where("token_hash = ? AND purpose = ?", HashRefreshToken(token), purpose).First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored.UsedAt != nil {
+		return nil, ErrUserTokenInvalid
+	}
+	if now.After(stored.ExpiresAt) {
+		return nil, ErrUserTokenExpired
+	}
+	return &stored, nil
+}
+
+// RedeemUserToken marks a token for the purpose as used and returns it. Call it inside the transaction
+// that acts on the token so a token is redeemed at most once.
+func RedeemUserToken(tx *gorm.DB, token, purpose string, now time.Time) (*models.UserToken, error) {
+	var stored models.UserToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose = ?", HashRefreshToken(token), purpose).
+		First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored.UsedAt != nil {
+		return nil, ErrUserTokenInvalid
+	}
+	if now.After(stored.ExpiresAt) {
+		return nil, ErrUserTokenExpired
+	}
+	if err := tx.Model(&stored).Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// Gin middleware that lets only users who have verified their email address through. Use it after
+// RequirePermission, which authenticates the user.
+func EmailVerificationRequired(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := GetAuthenticatedUserID(c)
+		var user models.User
+		err := db.Select("user_id", "email_verified_at").First(&user, userID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			fmt.Println("Error checking email verification:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking email verification"})
+			c.Abort()
+			return
+		}
+		if user.EmailVerifiedAt == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email address first; the link was mailed to you when you signed up"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Base URL of the web app when APP_BASE_URL is not set
+const defaultAppBaseURL = "http://localhost:3000"
+
+// AppURL returns a link to a page of the web app, e.g. for links in emails
+func AppURL(path string, query url.Values) string {
+	base := os.Getenv("APP_BASE_URL")
+	if base == "" {
+		base = defaultAppBaseURL
+	}
+	link := strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(path, "/")
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+	return link
+}
@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"bytes"
+	"testing"
+	"time"
+	"waitress-backend/internal/cli"
+	"waitress-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIClientRotateSecret__keeps_the_old_secret_as_previous(t *testing.T) {
+	// Given
+	client := models.APIClient{Secret: "current-secret"}
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	// When
+	secret, err := client.RotateSecret(now)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+	assert.Equal(t, secret, client.Secret)
+	assert.NotEqual(t, "current-secret", secret)
+	assert.Equal(t, "current-secret", *client.PreviousSecret)
+	assert.Equal(t, now, *client.LastSecretRotation)
+}
+
+func TestRunClients__rejects_a_missing_or_invalid_client_id(t *testing.T) {
+	// Given
+	var out bytes.Buffer
+
+	// When
+	noCommand := cli.RunClients(nil, nil, &out)
+	badID := cli.RunClients(nil, []string{"rotate", "abc"}, &out)
+
+	// Then
+	assert.Equal(t, 2, noCommand)
+	assert.Equal(t, 1, badID)
+	assert.Contains(t, out.String(), `invalid CLIENT_ID "abc"`)
+}
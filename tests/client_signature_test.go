@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"waitress-backend/internal/utilities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignClientRequest__covers_the_body_and_path(t *testing.T) {
+	// Given
+	sign := func(path, body string) string {
+		return utilities.SignClientRequest("secret", "mobile", "1700000000", "n1", "POST", path, []byte(body))
+	}
+
+	// When
+	original := sign("/api/auth/login", `{"email":"a@example.com"}`)
+
+	// Then
+	assert.Equal(t, original, sign("/api/auth/login", `{"email":"a@example.com"}`))
+	assert.NotEqual(t, original, sign("/api/auth/login", `{"email":"b@example.com"}`))
+	assert.NotEqual(t, original, sign("/api/auth/logout", `{"email":"a@example.com"}`))
+}
+
+func TestVerifyClientSignature__rejects_requests_outside_the_time_window(t *testing.T) {
+	// Given
+	now := time.Now()
+	stale := strconv.FormatInt(now.Add(-utilities.ClientSignatureWindow-time.Minute).Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader("{}"))
+	req.Header.Set(utilities.HeaderClientID, "mobile")
+	req.Header.Set(utilities.HeaderClientTimestamp, stale)
+	req.Header.Set(utilities.HeaderClientNonce, "n1")
+	req.Header.Set(utilities.HeaderClientSignature, utilities.SignClientRequest("secret", "mobile", stale, "n1", "POST", "/api/auth/login", []byte("{}")))
+
+	// When
+	_, err := utilities.VerifyClientSignature(nil, req, now)
+
+	// Then
+	assert.ErrorIs(t, err, utilities.ErrClientSignatureExpired)
+}
+
+func TestVerifyClientSignature__requires_every_signature_header(t *testing.T) {
+	// Given
+	req := httptest.NewRequest(http.MethodGet, "/api/clients", nil)
+	req.Header.Set(utilities.HeaderClientID, "mobile")
+
+	// When
+	_, err := utilities.VerifyClientSignature(nil, req, time.Now())
+
+	// Then
+	assert.ErrorIs(t, err, utilities.ErrClientSignatureMissing)
+}
+
+func TestMemoryClientNonceStore__accepts_each_nonce_once_until_it_expires(t *testing.T) {
+	// Given
+	store := utilities.NewMemoryClientNonceStore()
+	now := time.Now()
+	expires := now.Add(2 * utilities.ClientSignatureWindow)
+	first, err := store.Use("mobile:n1", expires, now)
+
+	// When
+	replay, _ := store.Use("mobile:n1", expires, now.Add(time.Minute))
+	other, _ := store.Use("mobile:n2", expires, now.Add(time.Minute))
+	expired, _ := store.Use("mobile:n1", expires.Add(time.Minute), expires.Add(time.Second))
+
+	// Then
+	assert.NoError(t, err)
+	assert.True(t, first)
+	assert.False(t, replay)
+	assert.True(t, other)
+	assert.True(t, expired)
+}
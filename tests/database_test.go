@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// testDatabase opens an empty SQLite database, named as the app's database is, with tables for the models.
+// Connections are limited to one, so transactions run one after another.
+func testDatabase(t *testing.T, models ...interface{}) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		NamingStrategy:                           schema.NamingStrategy{SingularTable: true},
+		Logger:                                   logger.Default.LogMode(logger.Silent),
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	sqlDB, err := db.DB()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	// SQLite has no ON UPDATE clause; GORM sets updated_at itself. Related models are migrated along with
+	// the listed ones, so theirs is dropped too.
+	visited := make(map[*schema.Schema]bool)
+	for _, model := range models {
+		stmt := &gorm.Statement{DB: db}
+		if !assert.NoError(t, stmt.Parse(model)) {
+			t.FailNow()
+		}
+		trimOnUpdate(stmt.Schema, visited)
+	}
+	if !assert.NoError(t, db.AutoMigrate(models...)) {
+		t.FailNow()
+	}
+	return db
+}
+
+// trimOnUpdate drops the ON UPDATE clause from the defaults of a schema and the schemas related to it
+func trimOnUpdate(s *schema.Schema, visited map[*schema.Schema]bool) {
+	if s == nil || visited[s] {
+		return
+	}
+	visited[s] = true
+	for _, field := range s.Fields {
+		field.DefaultValue = strings.TrimSuffix(field.DefaultValue, " ON UPDATE CURRENT_TIMESTAMP")
+	}
+	for _, rel := range s.Relationships.Relations {
+		trimOnUpdate(rel.FieldSchema, visited)
+		trimOnUpdate(rel.JoinTable, visited)
+	}
+}
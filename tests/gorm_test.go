@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/migrator"
+	"gorm.io/gorm/schema"
+)
+
+// dryRunDialector lets GORM build statements without a database
+type dryRunDialector struct{}
+
+func (dryRunDialector) Name() string { return "dryrun" }
+
+func (dryRunDialector) Initialize(db *gorm.DB) error {
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{})
+	return nil
+}
+
+func (dryRunDialector) Migrator(db *gorm.DB) gorm.Migrator { return migrator.Migrator{} }
+
+func (dryRunDialector) DataTypeOf(*schema.Field) string { return "" }
+
+func (dryRunDialector) DefaultValueOf(*schema.Field) clause.Expression {
+	return clause.Expr{SQL: "DEFAULT"}
+}
+
+func (dryRunDialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v interface{}) {
+	writer.WriteByte('?')
+}
+
+func (dryRunDialector) QuoteTo(writer clause.Writer, str string) { writer.WriteString(str) }
+
+func (dryRunDialector) Explain(sql string, vars ...interface{}) string {
+	return logger.ExplainSQL(sql, nil, `'`, vars...)
+}
+
+// insertedValues returns the column values GORM would insert when creating value, as the app's
+// database is configured
+func insertedValues(t *testing.T, value interface{}) map[string]interface{} {
+	db, err := gorm.Open(dryRunDialector{}, &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		NamingStrategy:         schema.NamingStrategy{SingularTable: true},
+	})
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	stmt := db.Create(value).Statement
+	if !assert.NoError(t, stmt.Error) {
+		return nil
+	}
+
+	sql := stmt.SQL.String()
+	start, end := strings.Index(sql, "("), strings.Index(sql, ")")
+	if !assert.True(t, start >= 0 && end > start, sql) {
+		return nil
+	}
+	columns := strings.Split(sql[start+1:end], ",")
+	if !assert.Len(t, stmt.Vars, len(columns), sql) {
+		return nil
+	}
+	values := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		values[column] = stmt.Vars[i]
+	}
+	return values
+}
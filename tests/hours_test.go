@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"testing"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Fridays 17:00 - 02:00, Saturdays 11:00 - 23:00
+func weekendSchedule() *utilities.RestaurantSchedule {
+	return &utilities.RestaurantSchedule{
+		Hours: []models.OpeningHours{
+			{Weekday: uint(time.Friday), OpensAt: "17:00", ClosesAt: "02:00"},
+			{Weekday: uint(time.Saturday), OpensAt: "11:00", ClosesAt: "23:00"},
+		},
+		Location: time.UTC,
+	}
+}
+
+func TestIsOpenFor__restaurant_without_hours_is_always_open(t *testing.T) {
+	// Given
+	schedule := &utilities.RestaurantSchedule{Location: time.UTC}
+	fourAM := time.Date(2030, 5, 3, 4, 0, 0, 0, time.UTC)
+
+	// When
+	open, _ := schedule.IsOpenFor(window(fourAM, 90))
+
+	// Then
+	assert.True(t, open)
+}
+
+func TestIsOpenFor__booking_after_midnight_uses_previous_days_hours(t *testing.T) {
+	// Given
+	schedule := weekendSchedule()
+	saturdayHalfPastMidnight := time.Date(2030, 5, 4, 0, 30, 0, 0, time.UTC)
+
+	// When
+	open, _ := schedule.IsOpenFor(window(saturdayHalfPastMidnight, 60))
+
+	// Then
+	assert.True(t, open)
+}
+
+func TestIsOpenFor__booking_running_past_closing_is_refused(t *testing.T) {
+	// Given
+	schedule := weekendSchedule()
+	saturdayTenPM := time.Date(2030, 5, 4, 22, 0, 0, 0, time.UTC)
+
+	// When
+	open, reason := schedule.IsOpenFor(window(saturdayTenPM, 90))
+
+	// Then
+	assert.False(t, open)
+	assert.NotEmpty(t, reason)
+}
+
+func TestIsOpenFor__annual_holiday_closes_restaurant(t *testing.T) {
+	// Given
+	schedule := weekendSchedule()
+	schedule.Exceptions = []models.HoursException{
+		{Date: "2020-05-04", Kind: models.HoursExceptionHoliday, IsClosed: true, RecursAnnually: true, Reason: "Staff holiday"},
+	}
+	saturdayNoon := time.Date(2030, 5, 4, 12, 0, 0, 0, time.UTC)
+
+	// When
+	open, reason := schedule.IsOpenFor(window(saturdayNoon, 90))
+
+	// Then
+	assert.False(t, open)
+	assert.Equal(t, "Restaurant is closed: Staff holiday", reason)
+}
+
+func TestIsOpenFor__service_periods_restrict_booking_starts(t *testing.T) {
+	// Given
+	schedule := weekendSchedule()
+	schedule.Periods = []models.ServicePeriod{
+		{Name: "brunch", Weekday: uint(time.Saturday), StartsAt: "11:00", EndsAt: "15:00", AcceptsReservations: true},
+		{Name: "dinner", Weekday: uint(time.Saturday), StartsAt: "18:00", EndsAt: "22:00", AcceptsReservations: true},
+	}
+	saturdayBrunch := time.Date(2030, 5, 4, 12, 0, 0, 0, time.UTC)
+	saturdayAfternoon := time.Date(2030, 5, 4, 16, 0, 0, 0, time.UTC)
+
+	// When
+	brunchOpen, _ := schedule.IsOpenFor(window(saturdayBrunch, 90))
+	afternoonOpen, _ := schedule.IsOpenFor(window(saturdayAfternoon, 60))
+	next, found := schedule.NextOpening(window(saturdayAfternoon, 60))
+
+	// Then
+	assert.True(t, brunchOpen)
+	assert.False(t, afternoonOpen)
+	assert.True(t, found)
+	assert.Equal(t, time.Date(2030, 5, 4, 18, 0, 0, 0, time.UTC), next)
+}
+
+func TestIsOpenFor__hours_are_evaluated_in_restaurant_time_zone(t *testing.T) {
+	// Given
+	toronto, _ := time.LoadLocation("America/Toronto")
+	schedule := weekendSchedule()
+	schedule.Location = toronto
+	// 23:30 UTC on a Saturday is 19:30 in Toronto, but Sunday 03:30 is closed everywhere
+	saturdayEveningUTC := time.Date(2030, 5, 4, 23, 30, 0, 0, time.UTC)
+	sundayEarlyUTC := time.Date(2030, 5, 5, 7, 30, 0, 0, time.UTC)
+
+	// When
+	eveningOpen, _ := schedule.IsOpenFor(window(saturdayEveningUTC, 60))
+	earlyOpen, _ := schedule.IsOpenFor(window(sundayEarlyUTC, 60))
+
+	// Then
+	assert.True(t, eveningOpen)
+	assert.False(t, earlyOpen)
+}
+
+func TestParseRestaurantTime__local_wall_time_uses_restaurant_zone(t *testing.T) {
+	// Given
+	losAngeles, _ := time.LoadLocation("America/Los_Angeles")
+
+	// When
+	local, localErr := utilities.ParseRestaurantTime("2030-01-15T19:00", losAngeles)
+	withOffset, offsetErr := utilities.ParseRestaurantTime("2030-01-15T19:00:00-05:00", losAngeles)
+
+	// Then
+	assert.NoError(t, localErr)
+	assert.NoError(t, offsetErr)
+	assert.Equal(t, time.Date(2030, 1, 16, 3, 0, 0, 0, time.UTC), local)
+	assert.Equal(t, time.Date(2030, 1, 16, 0, 0, 0, 0, time.UTC), withOffset)
+}
+
+func TestHoursModels__explicit_false_flags_are_saved_as_false(t *testing.T) {
+	// When
+	period := insertedValues(t, &models.ServicePeriod{RestaurantID: 1, Name: "brunch", StartsAt: "10:00", EndsAt: "14:00"})
+	exception := insertedValues(t, &models.HoursException{RestaurantID: 1, Date: "2030-12-24", Kind: models.HoursExceptionException})
+
+	// Then
+	assert.Equal(t, false, period["accepts_reservations"])
+	assert.Equal(t, false, exception["is_closed"])
+}
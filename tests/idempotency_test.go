@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// idempotentRouter serves POST /orders behind Idempotent as user 7, counting the handler's runs
+func idempotentRouter(status *int) (*gin.Engine, *int) {
+	gin.SetMode(gin.TestMode)
+	utilities.IdempotencyKeys = utilities.NewMemoryIdempotencyStore()
+	runs := 0
+	router := gin.New()
+	router.POST("/orders/:orderId", func(c *gin.Context) {
+		c.Set(utilities.ContextUserIDKey, uint(7))
+	}, utilities.Idempotent(), func(c *gin.Context) {
+		runs++
+		c.JSON(*status, gin.H{"run": runs})
+	})
+	return router, &runs
+}
+
+func postIdempotent(router *gin.Engine, path, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(utilities.IdempotencyKeyHeader, key)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestIdempotent__replays_the_first_response_to_retries(t *testing.T) {
+	// Given
+	status := http.StatusCreated
+	router, runs := idempotentRouter(&status)
+	first := postIdempotent(router, "/orders/1", "retry-1", `{"items":[1]}`)
+
+	// When
+	retry := postIdempotent(router, "/orders/1", "retry-1", `{"items":[1]}`)
+	unkeyed := postIdempotent(router, "/orders/1", "", `{"items":[1]}`)
+
+	// Then
+	assert.Equal(t, 2, *runs)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get(utilities.IdempotentReplayedHeader))
+	assert.Empty(t, unkeyed.Header().Get(utilities.IdempotentReplayedHeader))
+}
+
+func TestIdempotent__rejects_a_key_reused_with_a_different_request(t *testing.T) {
+	// Given
+	status := http.StatusCreated
+	router, runs := idempotentRouter(&status)
+	postIdempotent(router, "/orders/1", "retry-1", `{"items":[1]}`)
+
+	// When
+	otherBody := postIdempotent(router, "/orders/1", "retry-1", `{"items":[2]}`)
+	otherOrder := postIdempotent(router, "/orders/2", "retry-1", `{"items":[1]}`)
+
+	// Then
+	assert.Equal(t, 1, *runs)
+	assert.Equal(t, http.StatusUnprocessableEntity, otherBody.Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, otherOrder.Code)
+}
+
+func TestIdempotent__runs_again_after_a_server_error(t *testing.T) {
+	// Given
+	status := http.StatusBadGateway
+	router, runs := idempotentRouter(&status)
+	postIdempotent(router, "/orders/1", "retry-1", `{}`)
+
+	// When
+	status = http.StatusCreated
+	retry := postIdempotent(router, "/orders/1", "retry-1", `{}`)
+
+	// Then
+	assert.Equal(t, 2, *runs)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Empty(t, retry.Header().Get(utilities.IdempotentReplayedHeader))
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+	"time"
+	"waitress-backend/internal/utilities"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func claimsFor(subject string) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{Subject: subject, ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+}
+
+func TestKeySet__verifies_tokens_of_a_retired_key_during_its_grace_period(t *testing.T) {
+	// Given
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	oldKey := utilities.NewHMACKey("2026-04", []byte("old secret"))
+	oldSet, err := utilities.NewKeySet("2026-04", oldKey)
+	assert.NoError(t, err)
+	oldToken, err := oldSet.Sign(claimsFor("old"))
+	assert.NoError(t, err)
+
+	inGrace, expired := oldKey, oldKey
+	inGrace.ValidUntil = time.Now().Add(time.Hour)
+	expired.ValidUntil = time.Now().Add(-time.Minute)
+	newKey := utilities.NewEd25519Key("2026-10", private, nil)
+	rotated, err := utilities.NewKeySet("2026-10", newKey, inGrace)
+	assert.NoError(t, err)
+	afterGrace, err := utilities.NewKeySet("2026-10", newKey, expired)
+	assert.NoError(t, err)
+
+	// When
+	newToken, signErr := rotated.Sign(claimsFor("new"))
+	_, newErr := rotated.Parse(newToken, &jwt.RegisteredClaims{})
+	_, graceErr := rotated.Parse(oldToken, &jwt.RegisteredClaims{})
+	_, expiredErr := afterGrace.Parse(oldToken, &jwt.RegisteredClaims{})
+
+	// Then
+	assert.NoError(t, signErr)
+	assert.NoError(t, newErr)
+	assert.NoError(t, graceErr)
+	assert.Error(t, expiredErr)
+}
+
+func TestKeySet__publishes_only_asymmetric_public_keys(t *testing.T) {
+	// Given
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	keys, err := utilities.NewKeySet("ed", utilities.NewEd25519Key("ed", private, nil), utilities.NewHMACKey("hs", []byte("secret")))
+	assert.NoError(t, err)
+
+	// When
+	jwks := keys.JWKS()
+
+	// Then
+	assert.Len(t, jwks, 1)
+	assert.Equal(t, "ed", jwks[0].KeyID)
+	assert.Equal(t, "OKP", jwks[0].KeyType)
+	assert.Equal(t, "EdDSA", jwks[0].Algorithm)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(public), jwks[0].X)
+}
+
+func TestKeySet__rejects_a_token_whose_algorithm_does_not_match_its_key(t *testing.T) {
+	// Given
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	keys, err := utilities.NewKeySet("ed", utilities.NewEd25519Key("ed", private, nil))
+	assert.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claimsFor("forged"))
+	forged.Header["kid"] = "ed"
+	tokenString, err := forged.SignedString([]byte("guess"))
+	assert.NoError(t, err)
+
+	// When
+	_, parseErr := keys.Parse(tokenString, &jwt.RegisteredClaims{})
+
+	// Then
+	assert.Error(t, parseErr)
+}
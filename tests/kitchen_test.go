@@ -0,0 +1,48 @@
+package tests
+
+import (
+	"testing"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKitchenBroker__notifies_only_the_events_restaurant(t *testing.T) {
+	// Given
+	broker := utilities.NewKitchenBroker()
+	first, unsubscribeFirst := broker.Subscribe(1)
+	other, unsubscribeOther := broker.Subscribe(2)
+	defer unsubscribeFirst()
+	defer unsubscribeOther()
+
+	// When
+	broker.Notify(1)
+	broker.Notify(1)
+
+	// Then
+	assert.Len(t, first, 1)
+	assert.Len(t, other, 0)
+}
+
+func TestKitchenBroker__unsubscribe_closes_the_channel(t *testing.T) {
+	// Given
+	broker := utilities.NewKitchenBroker()
+	notifications, unsubscribe := broker.Subscribe(1)
+
+	// When
+	unsubscribe()
+	unsubscribe()
+	broker.Notify(1)
+
+	// Then
+	_, open := <-notifications
+	assert.False(t, open)
+}
+
+func TestCanTransitionOrderItem__served_items_cannot_be_voided(t *testing.T) {
+	assert.True(t, models.CanTransitionOrderItem(models.OrderItemStatusPending, models.OrderItemStatusPreparing))
+	assert.True(t, models.CanTransitionOrderItem(models.OrderItemStatusReady, models.OrderItemStatusVoided))
+	assert.False(t, models.CanTransitionOrderItem(models.OrderItemStatusServed, models.OrderItemStatusVoided))
+	assert.False(t, models.CanTransitionOrderItem(models.OrderItemStatusPending, models.OrderItemStatusServed))
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"testing"
+	"time"
+	"waitress-backend/internal/utilities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginThrottle__backs_off_exponentially_then_locks_out(t *testing.T) {
+	// Given
+	throttle := utilities.NewLoginThrottle(utilities.NewMemoryLimiterStore())
+	throttle.Account = utilities.LimiterPolicy{
+		FreeAttempts:    2,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    5,
+		LockoutDuration: time.Hour,
+		ResetAfter:      24 * time.Hour,
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	waits := []time.Duration{}
+
+	// When
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, throttle.Failure("Guest@Example.com", "10.0.0.1", now))
+		wait, err := throttle.Check("guest@example.com", "10.0.0.2", now)
+		assert.NoError(t, err)
+		waits = append(waits, wait)
+	}
+
+	// Then
+	assert.Equal(t, []time.Duration{0, 0, time.Second, 2 * time.Second, time.Hour}, waits)
+}
+
+func TestLoginThrottle__unlock_and_success_clear_only_the_account(t *testing.T) {
+	// Given
+	throttle := utilities.NewLoginThrottle(utilities.NewMemoryLimiterStore())
+	throttle.Address.FreeAttempts = 1
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		throttle.Failure("guest@example.com", "10.0.0.1", now)
+	}
+	lockedOut, _ := throttle.Check("guest@example.com", "10.0.0.9", now)
+
+	// When
+	assert.NoError(t, throttle.Unlock("guest@example.com"))
+	afterUnlock, _ := throttle.Check("guest@example.com", "10.0.0.9", now)
+	fromSameAddress, _ := throttle.Check("other@example.com", "10.0.0.1", now)
+
+	// Then
+	assert.Equal(t, utilities.DefaultAccountPolicy.LockoutDuration, lockedOut)
+	assert.Zero(t, afterUnlock)
+	assert.Positive(t, fromSameAddress)
+}
+
+func TestLoginThrottle__forgets_failures_after_the_reset_period(t *testing.T) {
+	// Given
+	throttle := utilities.NewLoginThrottle(utilities.NewMemoryLimiterStore())
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 9; i++ {
+		throttle.Failure("guest@example.com", "10.0.0.1", start)
+	}
+
+	// When
+	later := start.Add(utilities.DefaultAccountPolicy.ResetAfter + time.Minute)
+	throttle.Failure("guest@example.com", "10.0.0.2", later)
+	wait, err := throttle.Check("guest@example.com", "10.0.0.3", later)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Zero(t, wait)
+}
+
+func TestLoginThrottle__limits_password_resets_apart_from_logins(t *testing.T) {
+	// Given
+	throttle := utilities.NewLoginThrottle(utilities.NewMemoryLimiterStore())
+	throttle.ResetAccount = utilities.LimiterPolicy{FreeAttempts: 2, BaseDelay: time.Minute, MaxDelay: time.Hour, ResetAfter: time.Hour}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	waits := []time.Duration{}
+
+	// When
+	for i := 0; i < 4; i++ {
+		wait, err := throttle.PasswordReset("victim@example.com", "10.0.0.1", now)
+		assert.NoError(t, err)
+		waits = append(waits, wait)
+	}
+	otherAccount, _ := throttle.PasswordReset("other@example.com", "10.0.0.1", now)
+	login, _ := throttle.Check("victim@example.com", "10.0.0.1", now)
+	later, _ := throttle.PasswordReset("victim@example.com", "10.0.0.1", now.Add(time.Minute))
+
+	// Then
+	assert.Equal(t, []time.Duration{0, 0, 0, time.Minute}, waits)
+	assert.Zero(t, otherAccount)
+	assert.Zero(t, login)
+	assert.Zero(t, later)
+}
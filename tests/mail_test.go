@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"waitress-backend/internal/mail"
+	"waitress-backend/internal/utilities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSender__writes_each_message_to_a_file(t *testing.T) {
+	// Given
+	dir := t.TempDir()
+	sender := &mail.FileSender{Dir: dir, From: "no-reply@example.com"}
+
+	// When
+	err := sender.Send(context.Background(), mail.Message{To: "guest@example.com", Subject: "Hello", Body: "Line one\nLine two"})
+
+	// Then
+	assert.NoError(t, err)
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.Len(t, files, 1)
+	content, _ := os.ReadFile(files[0])
+	assert.Contains(t, string(content), "To: guest@example.com\r\n")
+	assert.Contains(t, string(content), "Subject: Hello\r\n")
+	assert.Contains(t, string(content), "Line one\r\nLine two")
+}
+
+func TestMemorySender__rejects_header_injection(t *testing.T) {
+	// Given
+	sender := mail.NewMemorySender()
+
+	// When
+	err := sender.Send(context.Background(), mail.Message{To: "guest@example.com\r\nBcc: everyone@example.com", Subject: "Hello"})
+
+	// Then
+	assert.Error(t, err)
+	assert.Empty(t, sender.Messages())
+}
+
+func TestAppURL__joins_the_base_url_and_query(t *testing.T) {
+	// Given
+	t.Setenv("APP_BASE_URL", "https://app.example.com/")
+
+	// When
+	link := utilities.AppURL("/reset-password", url.Values{"token": {"a+b"}})
+
+	// Then
+	assert.Equal(t, "https://app.example.com/reset-password?token=a%2Bb", link)
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"testing"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// "Choose a side" (exactly one) and "Extras" (any number)
+func burgerModifierGroups() []models.ModifierGroup {
+	return []models.ModifierGroup{
+		{ModifierGroupID: 1, Name: "Choose a side", MinSelections: 1, MaxSelections: 1, Modifiers: []models.Modifier{
+			{ModifierID: 10, Name: "Fries", IsAvailable: true},
+			{ModifierID: 11, Name: "Salad", IsAvailable: true},
+		}},
+		{ModifierGroupID: 2, Name: "Extras", MinSelections: 0, MaxSelections: 0, Modifiers: []models.Modifier{
+			{ModifierID: 20, Name: "Extra cheese", PriceDelta: usd("1.50"), IsAvailable: true},
+			{ModifierID: 21, Name: "Bacon", PriceDelta: usd("2.00"), IsAvailable: false},
+		}},
+	}
+}
+
+func TestValidateModifierSelection__accepts_a_valid_selection(t *testing.T) {
+	// Given
+	groups := burgerModifierGroups()
+
+	// When
+	chosen, err := utilities.ValidateModifierSelection(groups, []uint{10, 20})
+
+	// Then
+	assert.NoError(t, err)
+	assert.Len(t, chosen, 2)
+	assert.Equal(t, usd("1.50"), chosen[1].PriceDelta)
+}
+
+func TestValidateModifierSelection__enforces_group_limits(t *testing.T) {
+	// Given
+	groups := burgerModifierGroups()
+
+	// When
+	_, missingSide := utilities.ValidateModifierSelection(groups, []uint{20})
+	_, twoSides := utilities.ValidateModifierSelection(groups, []uint{10, 11})
+
+	// Then
+	assert.EqualError(t, missingSide, "Choose a side requires at least 1 selection(s)")
+	assert.EqualError(t, twoSides, "Choose a side allows at most 1 selection(s)")
+}
+
+func TestValidateModifierSelection__rejects_foreign_and_unavailable_modifiers(t *testing.T) {
+	// Given
+	groups := burgerModifierGroups()
+
+	// When
+	_, foreign := utilities.ValidateModifierSelection(groups, []uint{10, 99})
+	_, unavailable := utilities.ValidateModifierSelection(groups, []uint{10, 21})
+
+	// Then
+	assert.Error(t, foreign)
+	assert.EqualError(t, unavailable, "Bacon is not available")
+}
+
+func TestValidateMenuPrice__rejects_negative_and_foreign_prices(t *testing.T) {
+	assert.NoError(t, utilities.ValidateMenuPrice("price", usd("0"), "USD"))
+	assert.NoError(t, utilities.ValidateMenuPrice("price", usd("12.50"), "USD"))
+	assert.Error(t, utilities.ValidateMenuPrice("price", usd("-0.01"), "USD"))
+	assert.Error(t, utilities.ValidateMenuPrice("price", models.MustParseMoney("12.50", "EUR"), "USD"))
+}
+
+func TestParseMenuFilter__expands_aliases_and_rejects_unknown_tags(t *testing.T) {
+	// When
+	filter, err := utilities.ParseMenuFilter([]string{" Nuts", "gluten", "peanuts"}, []string{"vegan"})
+	_, unknownErr := utilities.ParseMenuFilter([]string{"pollen"}, nil)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"gluten", "peanuts", "tree-nuts"}, filter.ExcludeAllergens)
+	assert.Equal(t, []string{"vegan"}, filter.RequireDietary)
+	assert.Error(t, unknownErr)
+}
+
+func TestMenuFilterSQL__builds_one_condition_per_constraint(t *testing.T) {
+	// Given
+	filter := utilities.MenuFilter{ExcludeAllergens: []string{"dairy"}, RequireDietary: []string{"halal", "gluten-free"}}
+
+	db, err := gorm.Open(nil, &gorm.Config{NamingStrategy: schema.NamingStrategy{SingularTable: true}})
+	assert.NoError(t, err)
+
+	// When
+	condition, args := filter.SQL(db, "mi.menu_id")
+
+	// Then the tables are named as the server names them
+	assert.Equal(t, "menu_item", utilities.TableName(db, &models.MenuItem{}))
+	assert.Contains(t, condition, "NOT EXISTS (SELECT 1 FROM menu_item_tag t WHERE t.menu_id = mi.menu_id")
+	assert.Len(t, args, 6)
+	assert.Equal(t, "gluten-free", args[5])
+}
+
+func TestValidateMenuItemTags__rejects_contradictory_dietary_tags(t *testing.T) {
+	assert.NoError(t, utilities.ValidateMenuItemTags([]string{"gluten"}, []string{"vegan", "halal"}))
+	assert.EqualError(t, utilities.ValidateMenuItemTags([]string{"dairy"}, []string{"vegan"}), "an item containing dairy cannot be tagged vegan")
+}
+
+func TestMenuModels__explicit_zero_values_are_saved(t *testing.T) {
+	// When
+	item := insertedValues(t, &models.MenuItem{RestaurantID: 1})
+	group := insertedValues(t, &models.ModifierGroup{RestaurantID: 1, Name: "Extras"})
+	modifier := insertedValues(t, &models.Modifier{ModifierGroupID: 1, Name: "Extra cheese"})
+
+	// Then
+	assert.Equal(t, false, item["is_available"])
+	assert.Equal(t, uint(0), group["max_selections"])
+	assert.Equal(t, false, modifier["is_available"])
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+	"waitress-backend/internal/database"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// usd returns an amount in US dollars, e.g. usd("12.50")
+func usd(amount string) models.Money {
+	return models.MustParseMoney(amount, "USD")
+}
+
+func TestParseMoney__reads_decimals_exactly(t *testing.T) {
+	assert.Equal(t, models.Money{Amount: 1999, Currency: "USD"}, usd("19.99"))
+	assert.Equal(t, models.Money{Amount: 1250, Currency: "USD"}, usd("12.5"))
+	assert.Equal(t, models.Money{Amount: -300, Currency: "USD"}, usd("-3"))
+	assert.Equal(t, models.Money{Amount: 1200, Currency: "JPY"}, models.MustParseMoney("1200", "JPY"))
+
+	for _, bad := range []string{"", "1.999", "1,50", "abc", "1e3", "99999999999999999999"} {
+		_, err := models.ParseMoney(bad, "USD")
+		assert.Error(t, err, bad)
+	}
+	_, err := models.ParseMoney("1.5", "JPY")
+	assert.Error(t, err)
+	_, err = models.ParseMoney("1", "XXX")
+	assert.Error(t, err)
+}
+
+func TestMoney__formats_with_the_currency_exponent(t *testing.T) {
+	assert.Equal(t, "0.05", usd("0.05").Decimal())
+	assert.Equal(t, "-12.50", usd("-12.5").Decimal())
+	assert.Equal(t, "1200", models.MustParseMoney("1200", "JPY").Decimal())
+	assert.Equal(t, "1.250 KWD", models.MustParseMoney("1.25", "KWD").String())
+}
+
+func TestMoney__arithmetic_is_exact_and_rounds_explicitly(t *testing.T) {
+	// Adding ten cents ten times makes exactly a dollar
+	total := usd("0")
+	for i := 0; i < 10; i++ {
+		total, _ = total.Add(usd("0.10"))
+	}
+	assert.Equal(t, usd("1.00"), total)
+
+	_, err := usd("1").Add(models.MustParseMoney("1", "EUR"))
+	assert.ErrorIs(t, err, models.ErrCurrencyMismatch)
+
+	// 8.875% tax on 10.00 is 0.8875, which rounds half away from zero
+	tax, err := usd("10.00").MulRatio(8875, 100000)
+	assert.NoError(t, err)
+	assert.Equal(t, usd("0.89"), tax)
+	half, _ := usd("0.05").MulRatio(1, 2)
+	assert.Equal(t, usd("0.03"), half)
+	negativeHalf, _ := usd("-0.05").MulRatio(1, 2)
+	assert.Equal(t, usd("-0.03"), negativeHalf)
+
+	shares, err := usd("10.00").Split(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Money{usd("3.34"), usd("3.33"), usd("3.33")}, shares)
+	shares, _ = usd("-0.05").Split(2)
+	assert.Equal(t, []models.Money{usd("-0.03"), usd("-0.02")}, shares)
+}
+
+func TestMoney__encodes_json_as_a_decimal_string(t *testing.T) {
+	// When
+	data, err := json.Marshal(usd("12.50"))
+
+	// Then
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount": "12.50", "currency": "USD"}`, string(data))
+
+	// Numbers are read from their digits and bare amounts are in the default currency
+	var price struct {
+		Price models.Money  `json:"price"`
+		Tip   *models.Money `json:"tip"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(`{"price": 19.99, "tip": {"amount": "2", "currency": "usd"}}`), &price))
+	assert.Equal(t, usd("19.99"), price.Price)
+	assert.Equal(t, usd("2.00"), *price.Tip)
+	assert.Error(t, json.Unmarshal([]byte(`{"price": 1.999}`), &price))
+	assert.Error(t, json.Unmarshal([]byte(`{"price": 1e2}`), &price))
+}
+
+// paymentDuringMoneyMigration is the payment table once AutoMigrate has added the money columns, before
+// MigrateMoneyColumns has dropped the float amount and its currency
+type paymentDuringMoneyMigration struct {
+	PaymentID      uint `gorm:"primaryKey"`
+	Amount         *float64
+	Currency       string
+	AmountMinor    *int64
+	AmountCurrency *string
+}
+
+func TestMigrateMoneyColumns__copies_rows_not_copied_yet_and_can_run_again(t *testing.T) {
+	// Given
+	db := testDatabase(t)
+	payments := db.Table(utilities.TableName(db, &models.Payment{}))
+	assert.NoError(t, payments.AutoMigrate(&paymentDuringMoneyMigration{}))
+	copiedMinor, copiedCurrency := int64(1250), "USD"
+	copied, pending := 12.5, 7.25
+	assert.NoError(t, payments.Create(&[]paymentDuringMoneyMigration{
+		{Amount: &copied, Currency: "usd", AmountMinor: &copiedMinor, AmountCurrency: &copiedCurrency},
+		{Amount: &pending, Currency: "usd"},
+	}).Error)
+
+	// When
+	first := database.MigrateMoneyColumns(db)
+	again := database.MigrateMoneyColumns(db)
+
+	// Then
+	assert.NoError(t, first)
+	assert.NoError(t, again)
+	assert.False(t, db.Migrator().HasColumn(&models.Payment{}, "amount"))
+	assert.False(t, db.Migrator().HasColumn(&models.Payment{}, "currency"))
+	var amounts []models.Money
+	assert.NoError(t, db.Model(&models.Payment{}).Order("payment_id").
+		Select("amount_minor AS minor", "amount_currency AS currency").Scan(&amounts).Error)
+	assert.Equal(t, []models.Money{usd("12.50"), usd("7.25")}, amounts)
+}
+
+func TestMigrateMoneyColumns__finishes_a_table_whose_float_column_is_dropped(t *testing.T) {
+	// Given
+	db := testDatabase(t)
+	payments := db.Table(utilities.TableName(db, &models.Payment{}))
+	assert.NoError(t, payments.AutoMigrate(&paymentDuringMoneyMigration{}))
+	assert.NoError(t, db.Migrator().DropColumn(&models.Payment{}, "amount"))
+
+	// When
+	err := database.MigrateMoneyColumns(db)
+
+	// Then
+	assert.NoError(t, err)
+	assert.False(t, db.Migrator().HasColumn(&models.Payment{}, "currency"))
+	assert.True(t, db.Migrator().HasColumn(&models.Payment{}, "amount_minor"))
+}
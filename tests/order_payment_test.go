@@ -0,0 +1,226 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"waitress-backend/internal/handlers"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/payments"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// Users of the order tests: the restaurant's owner and the customer who ordered
+const (
+	orderOwnerID    uint = 1
+	orderCustomerID uint = 2
+)
+
+// orderDatabase creates a restaurant with an order of the customer in the status, with one pending item
+// and a payment of its total in paymentStatus, if given
+func orderDatabase(t *testing.T, status string, isPaid bool, paymentStatus string) (*gorm.DB, *models.Order) {
+	db := testDatabase(t, &models.Restaurant{}, &models.Staff{}, &models.Order{}, &models.OrderItem{},
+		&models.OrderItemModifier{}, &models.Payment{}, &models.Refund{}, &models.PaymentEvent{}, &models.KitchenEvent{})
+	restaurant := models.Restaurant{OwnerID: orderOwnerID, Name: "Trattoria"}
+	assert.NoError(t, db.Omit("Owner").Create(&restaurant).Error)
+
+	price := models.MustParseMoney("12.50", models.DefaultCurrency)
+	order := models.Order{
+		ReservationID: 1,
+		RestaurantID:  restaurant.RestaurantId,
+		UserID:        orderCustomerID,
+		Status:        status,
+		Total:         price,
+		IsPaid:        isPaid,
+		Items: []models.OrderItem{
+			{MenuID: 1, NameOfItem: "Margherita", Quantity: 1, UnitPrice: price, Status: models.OrderItemStatusPending},
+		},
+	}
+	assert.NoError(t, db.Omit("Reservation").Create(&order).Error)
+	if paymentStatus != "" {
+		assert.NoError(t, db.Omit("Restaurant").Create(&models.Payment{
+			UserID:           orderCustomerID,
+			RestaurantID:     restaurant.RestaurantId,
+			OrderID:          &order.OrderID,
+			Provider:         "fake",
+			StripePaymentKey: "pi_test",
+			Amount:           price,
+			Status:           paymentStatus,
+			PaymentMethod:    "card",
+		}).Error)
+	}
+	return db, &order
+}
+
+// orderRequest makes a request to the order endpoints as the user
+func orderRequest(db *gorm.DB, userID uint, method, path, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	restaurant := router.Group("/api/restaurant/:restaurantId", func(c *gin.Context) {
+		c.Set(utilities.ContextUserIDKey, userID)
+		c.Set(utilities.ContextAuthTypeKey, string(utilities.Customer))
+	})
+	restaurant.POST("/orders/:orderId/items", handlers.AddOrderItems(db, router))
+	restaurant.DELETE("/orders/:orderId/items/:itemId", handlers.RemoveOrderItem(db, router))
+	restaurant.PATCH("/orders/:orderId/status", handlers.UpdateOrderStatus(db, router))
+	restaurant.PATCH("/kitchen/items/:itemId", handlers.UpdateOrderItemStatus(db, router))
+	restaurant.POST("/orders/:orderId/payments/:paymentId/confirm", handlers.ConfirmOrderPayment(db, router))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return recorder
+}
+
+func orderPath(order *models.Order, suffix string) string {
+	return fmt.Sprintf("/api/restaurant/%d/orders/%d%s", order.RestaurantID, order.OrderID, suffix)
+}
+
+// fakePayments makes a fake provider the handlers' provider for the test
+func fakePayments(t *testing.T) *payments.FakeProvider {
+	provider := payments.NewFakeProvider()
+	provider.WebhookSecret = "whsec_test"
+	previous := payments.Default()
+	payments.SetDefault(provider)
+	t.Cleanup(func() { payments.SetDefault(previous) })
+	return provider
+}
+
+// pendingPayment adds a pending payment of the order's total to the database and the provider
+func pendingPayment(t *testing.T, db *gorm.DB, provider *payments.FakeProvider, order *models.Order) *models.Payment {
+	intent, err := provider.CreateIntent(context.Background(), payments.IntentParams{Amount: order.Total.Amount, Currency: order.Total.Currency})
+	assert.NoError(t, err)
+	payment := &models.Payment{
+		UserID:           order.UserID,
+		RestaurantID:     order.RestaurantID,
+		OrderID:          &order.OrderID,
+		Provider:         provider.Name(),
+		StripePaymentKey: intent.ID,
+		Amount:           order.Total,
+		Status:           models.PaymentStatusPending,
+		PaymentMethod:    "card",
+	}
+	assert.NoError(t, db.Omit("Restaurant").Create(payment).Error)
+	return payment
+}
+
+func TestUpdateOrderStatus__paid_orders_cannot_be_recalled(t *testing.T) {
+	// Given
+	db, order := orderDatabase(t, models.OrderStatusSubmitted, true, models.PaymentStatusCompleted)
+
+	// When
+	response := orderRequest(db, orderCustomerID, http.MethodPatch, orderPath(order, "/status"), `{"status":"open"}`)
+
+	// Then
+	assert.Equal(t, http.StatusConflict, response.Code)
+	var stored models.Order
+	assert.NoError(t, db.First(&stored, order.OrderID).Error)
+	assert.Equal(t, models.OrderStatusSubmitted, stored.Status)
+}
+
+func TestAddOrderItems__refused_while_a_payment_is_pending(t *testing.T) {
+	// Given
+	db, order := orderDatabase(t, models.OrderStatusOpen, false, models.PaymentStatusPending)
+
+	// When
+	response := orderRequest(db, orderCustomerID, http.MethodPost, orderPath(order, "/items"), `{"items":[{"menuId":1,"quantity":1}]}`)
+
+	// Then
+	assert.Equal(t, http.StatusConflict, response.Code)
+	var items int64
+	assert.NoError(t, db.Model(&models.OrderItem{}).Where("order_id = ?", order.OrderID).Count(&items).Error)
+	assert.Equal(t, int64(1), items)
+}
+
+func TestRemoveOrderItem__items_of_a_paid_order_stay(t *testing.T) {
+	// Given
+	db, order := orderDatabase(t, models.OrderStatusSubmitted, true, models.PaymentStatusCompleted)
+	path := orderPath(order, fmt.Sprintf("/items/%d", order.Items[0].OrderItemID))
+
+	// When
+	customer := orderRequest(db, orderCustomerID, http.MethodDelete, path, "")
+	owner := orderRequest(db, orderOwnerID, http.MethodDelete, path, "")
+	kitchen := orderRequest(db, orderOwnerID, http.MethodPatch,
+		fmt.Sprintf("/api/restaurant/%d/kitchen/items/%d", order.RestaurantID, order.Items[0].OrderItemID), `{"status":"voided"}`)
+
+	// Then
+	assert.Equal(t, http.StatusConflict, customer.Code)
+	assert.Equal(t, http.StatusConflict, owner.Code)
+	assert.Equal(t, http.StatusConflict, kitchen.Code)
+	var item models.OrderItem
+	assert.NoError(t, db.First(&item, order.Items[0].OrderItemID).Error)
+	assert.Equal(t, models.OrderItemStatusPending, item.Status)
+}
+
+func TestUpdateOrderStatus__paid_orders_are_voided_only_once_refunded(t *testing.T) {
+	// Given
+	db, order := orderDatabase(t, models.OrderStatusInKitchen, true, models.PaymentStatusCompleted)
+
+	// When
+	paid := orderRequest(db, orderOwnerID, http.MethodPatch, orderPath(order, "/status"), `{"status":"voided"}`)
+	assert.NoError(t, db.Model(&models.Payment{}).Where("order_id = ?", order.OrderID).Update("status", models.PaymentStatusRefunded).Error)
+	refunded := orderRequest(db, orderOwnerID, http.MethodPatch, orderPath(order, "/status"), `{"status":"voided"}`)
+
+	// Then
+	assert.Equal(t, http.StatusConflict, paid.Code)
+	assert.Equal(t, http.StatusOK, refunded.Code)
+}
+
+func TestConfirmOrderPayment__declined_intents_are_canceled(t *testing.T) {
+	// Given
+	provider := fakePayments(t)
+	db, order := orderDatabase(t, models.OrderStatusSubmitted, false, "")
+	payment := pendingPayment(t, db, provider, order)
+
+	// When
+	response := orderRequest(db, orderCustomerID, http.MethodPost, orderPath(order, fmt.Sprintf("/payments/%d/confirm", payment.PaymentID)),
+		`{"paymentMethod":"`+payments.FakePaymentMethodDeclined+`"}`)
+
+	// Then
+	assert.Equal(t, http.StatusPaymentRequired, response.Code)
+	var stored models.Payment
+	assert.NoError(t, db.First(&stored, payment.PaymentID).Error)
+	assert.Equal(t, models.PaymentStatusFailed, stored.Status)
+	intent, err := provider.GetIntent(context.Background(), payment.StripePaymentKey)
+	assert.NoError(t, err)
+	assert.Equal(t, payments.IntentCanceled, intent.Status)
+	_, err = provider.Confirm(context.Background(), payment.StripePaymentKey, "pm_card_visa")
+	assert.ErrorIs(t, err, payments.ErrInvalidState)
+}
+
+func TestHandlePaymentWebhook__payments_of_paid_orders_are_flagged_and_refunded(t *testing.T) {
+	// Given
+	provider := fakePayments(t)
+	db, order := orderDatabase(t, models.OrderStatusSubmitted, true, models.PaymentStatusCompleted)
+	late := pendingPayment(t, db, provider, order)
+	_, err := provider.Confirm(context.Background(), late.StripePaymentKey, "pm_card_visa")
+	assert.NoError(t, err)
+	payload, err := provider.EventPayload("evt_late", "payment_intent.succeeded", late.StripePaymentKey)
+	assert.NoError(t, err)
+
+	// When
+	response := postWebhook(db, provider, payload)
+
+	// Then
+	assert.Equal(t, http.StatusOK, response.Code)
+	var stored models.Payment
+	assert.NoError(t, db.First(&stored, late.PaymentID).Error)
+	assert.Equal(t, models.PaymentStatusRefunded, stored.Status)
+	assert.NotNil(t, stored.FlaggedAt)
+	var refunds []models.Refund
+	assert.NoError(t, db.Where("payment_id = ?", late.PaymentID).Find(&refunds).Error)
+	if assert.Len(t, refunds, 1) {
+		assert.Equal(t, models.RefundStatusSucceeded, refunds[0].Status)
+		assert.Equal(t, late.Amount, refunds[0].Amount)
+		assert.Equal(t, uint(0), refunds[0].IssuedBy)
+	}
+	made, err := provider.ListRefunds(context.Background(), late.StripePaymentKey)
+	assert.NoError(t, err)
+	assert.Len(t, made, 1)
+}
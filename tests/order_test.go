@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"testing"
+	"time"
+	"waitress-backend/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransitionOrder__follows_the_order_lifecycle(t *testing.T) {
+	// Given
+	lifecycle := []string{
+		models.OrderStatusOpen,
+		models.OrderStatusSubmitted,
+		models.OrderStatusInKitchen,
+		models.OrderStatusServed,
+		models.OrderStatusClosed,
+	}
+
+	// When / Then
+	for i := 0; i < len(lifecycle)-1; i++ {
+		assert.True(t, models.CanTransitionOrder(lifecycle[i], lifecycle[i+1]), "%s -> %s", lifecycle[i], lifecycle[i+1])
+	}
+	assert.False(t, models.CanTransitionOrder(models.OrderStatusOpen, models.OrderStatusServed))
+	assert.False(t, models.CanTransitionOrder(models.OrderStatusInKitchen, models.OrderStatusOpen))
+	assert.False(t, models.CanTransitionOrder(models.OrderStatusClosed, models.OrderStatusVoided))
+	assert.False(t, models.CanTransitionOrder(models.OrderStatusVoided, models.OrderStatusOpen))
+}
+
+func TestTransitionTo__records_timestamps_and_rejects_invalid_moves(t *testing.T) {
+	// Given
+	order := &models.Order{Status: models.OrderStatusOpen}
+	now := time.Date(2030, 5, 4, 19, 0, 0, 0, time.UTC)
+
+	// When
+	submitErr := order.TransitionTo(models.OrderStatusSubmitted, now)
+	closeErr := order.TransitionTo(models.OrderStatusClosed, now)
+
+	// Then
+	assert.NoError(t, submitErr)
+	assert.Error(t, closeErr)
+	assert.Equal(t, models.OrderStatusSubmitted, order.Status)
+	assert.Equal(t, &now, order.SubmittedAt)
+	assert.Nil(t, order.ClosedAt)
+}
+
+func TestRecalculateTotal__ignores_voided_items(t *testing.T) {
+	// Given
+	order := &models.Order{Items: []models.OrderItem{
+		{Quantity: 2, UnitPrice: usd("12.50"), Status: models.OrderItemStatusPending},
+		{Quantity: 1, UnitPrice: usd("4.10"), Status: models.OrderItemStatusPending},
+		{Quantity: 3, UnitPrice: usd("9.00"), Status: models.OrderItemStatusVoided},
+	}}
+
+	// When
+	err := order.RecalculateTotal()
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, usd("29.10"), order.Total)
+}
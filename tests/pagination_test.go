@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"net/http/httptest"
+	"testing"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePage__defaults_and_bounds(t *testing.T) {
+	// When
+	page, err := utilities.ParsePage("", "")
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, utilities.Page{Number: 1, Size: utilities.DefaultPageSize}, page)
+	assert.Equal(t, 0, page.Offset())
+
+	// When
+	page, err = utilities.ParsePage("3", "25")
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, 50, page.Offset())
+
+	for _, bad := range [][2]string{{"0", ""}, {"x", ""}, {"", "0"}, {"", "101"}} {
+		_, err := utilities.ParsePage(bad[0], bad[1])
+		assert.Error(t, err, "page %q pageSize %q", bad[0], bad[1])
+	}
+}
+
+func TestEscapeLike__matches_wildcards_literally(t *testing.T) {
+	assert.Equal(t, `50\% off\_now\\`, utilities.EscapeLike(`50% off_now\`))
+}
+
+func TestCanActOnUser__allows_self_or_user_managers_of_lower_ranks(t *testing.T) {
+	// Given
+	gin.SetMode(gin.TestMode)
+	caller := func(userID uint, authType string) *gin.Context {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Set(utilities.ContextUserIDKey, userID)
+		c.Set(utilities.ContextAuthTypeKey, authType)
+		return c
+	}
+
+	// Then
+	assert.True(t, utilities.CanActOnUser(caller(7, "customer"), 7, "customer"))
+	assert.False(t, utilities.CanActOnUser(caller(7, "customer"), 8, "customer"))
+	assert.False(t, utilities.CanActOnUser(caller(7, "staff_super"), 8, "customer"))
+	assert.True(t, utilities.CanActOnUser(caller(7, "admin"), 8, "customer"))
+	assert.True(t, utilities.CanActOnUser(caller(7, "admin"), 8, "admin"))
+	assert.False(t, utilities.CanActOnUser(caller(7, "admin"), 8, "admin_super"))
+	assert.False(t, utilities.CanActOnUser(caller(7, "admin"), 8, "dev"))
+}
@@ -0,0 +1,46 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+	"waitress-backend/internal/utilities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type restaurantPatch struct {
+	Name    utilities.PatchField[string] `json:"Name"`
+	Website utilities.PatchField[string] `json:"Website"`
+	Tables  utilities.PatchField[int]    `json:"NumberOfTables"`
+	IDs     utilities.PatchField[[]uint] `json:"CategoryIDs"`
+}
+
+func TestDecodeMergePatch__distinguishes_absent_null_and_set_members(t *testing.T) {
+	// Given
+	body := `{"Name": "Blue Door", "Website": null, "CategoryIDs": [2, 3]}`
+	var patch restaurantPatch
+
+	// When
+	err := utilities.DecodeMergePatch(strings.NewReader(body), &patch)
+
+	// Then
+	assert.NoError(t, err)
+	assert.True(t, patch.Name.HasValue())
+	assert.Equal(t, "Blue Door", patch.Name.Value)
+	assert.True(t, patch.Website.Set)
+	assert.True(t, patch.Website.Null)
+	assert.False(t, patch.Tables.Set)
+	assert.Equal(t, []uint{2, 3}, patch.IDs.Value)
+}
+
+func TestDecodeMergePatch__rejects_unknown_members(t *testing.T) {
+	// Given
+	body := `{"AverageRating": 5}`
+	var patch restaurantPatch
+
+	// When
+	err := utilities.DecodeMergePatch(strings.NewReader(body), &patch)
+
+	// Then
+	assert.Error(t, err)
+}
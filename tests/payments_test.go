@@ -0,0 +1,263 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"waitress-backend/internal/payments"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeProvider__settles_declines_and_refunds(t *testing.T) {
+	// Given
+	ctx := context.Background()
+	provider := payments.NewFakeProvider()
+	intent, err := provider.CreateIntent(ctx, payments.IntentParams{Amount: 2550, Currency: "USD"})
+	assert.NoError(t, err)
+	assert.Equal(t, payments.IntentRequiresPaymentMethod, intent.Status)
+	assert.NotEmpty(t, intent.ClientSecret)
+
+	// When the card is declined
+	_, err = provider.Confirm(ctx, intent.ID, payments.FakePaymentMethodInsufficientFunds)
+
+	// Then
+	var cardErr *payments.CardError
+	assert.True(t, errors.As(err, &cardErr))
+	assert.Equal(t, "insufficient_funds", cardErr.DeclineCode)
+
+	// When another card is used
+	intent, err = provider.Confirm(ctx, intent.ID, "pm_card_visa")
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, payments.IntentSucceeded, intent.Status)
+	assert.Equal(t, int64(2550), intent.AmountReceived)
+	_, err = provider.Confirm(ctx, intent.ID, "pm_card_visa")
+	assert.ErrorIs(t, err, payments.ErrInvalidState)
+
+	// When it is refunded in parts
+	refund, err := provider.Refund(ctx, payments.RefundParams{IntentID: intent.ID, Amount: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, payments.RefundSucceeded, refund.Status)
+	refund, err = provider.Refund(ctx, payments.RefundParams{IntentID: intent.ID})
+
+	// Then the second refund returns the rest and nothing is left
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1550), refund.Amount)
+	_, err = provider.Refund(ctx, payments.RefundParams{IntentID: intent.ID, Amount: 1})
+	assert.ErrorIs(t, err, payments.ErrInvalidState)
+}
+
+func TestFakeProvider__retried_refunds_are_made_once(t *testing.T) {
+	// Given
+	ctx := context.Background()
+	provider := payments.NewFakeProvider()
+	intent, _ := provider.CreateIntent(ctx, payments.IntentParams{Amount: 2000, Currency: "USD"})
+	provider.Confirm(ctx, intent.ID, "pm_card_visa")
+	params := payments.RefundParams{
+		IntentID:       intent.ID,
+		Amount:         1500,
+		Metadata:       map[string]string{"refund_id": "7"},
+		IdempotencyKey: "refund-7",
+	}
+	first, _ := provider.Refund(ctx, params)
+
+	// When
+	retry, err := provider.Refund(ctx, params)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, first, retry)
+	refunds, err := provider.ListRefunds(ctx, intent.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, refunds, 1) {
+		assert.Equal(t, "7", refunds[0].Metadata["refund_id"])
+	}
+}
+
+func TestFakeProvider__manual_capture_authorizes_first(t *testing.T) {
+	// Given
+	ctx := context.Background()
+	provider := payments.NewFakeProvider()
+	intent, _ := provider.CreateIntent(ctx, payments.IntentParams{Amount: 1000, Currency: "USD", ManualCapture: true})
+
+	// When
+	intent, err := provider.Confirm(ctx, intent.ID, "pm_card_visa")
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, payments.IntentRequiresCapture, intent.Status)
+	intent, err = provider.Capture(ctx, intent.ID, 800)
+	assert.NoError(t, err)
+	assert.Equal(t, payments.IntentSucceeded, intent.Status)
+	assert.Equal(t, int64(800), intent.AmountReceived)
+}
+
+func TestFakeProvider__canceled_intents_cannot_be_paid(t *testing.T) {
+	// Given
+	ctx := context.Background()
+	provider := payments.NewFakeProvider()
+	open, _ := provider.CreateIntent(ctx, payments.IntentParams{Amount: 1000, Currency: "USD"})
+	paid, _ := provider.CreateIntent(ctx, payments.IntentParams{Amount: 1000, Currency: "USD"})
+	provider.Confirm(ctx, paid.ID, "pm_card_visa")
+
+	// When
+	canceled, err := provider.Cancel(ctx, open.ID)
+	_, paidErr := provider.Cancel(ctx, paid.ID)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, payments.IntentCanceled, canceled.Status)
+	_, err = provider.Confirm(ctx, open.ID, "pm_card_visa")
+	assert.ErrorIs(t, err, payments.ErrInvalidState)
+	assert.ErrorIs(t, paidErr, payments.ErrInvalidState)
+}
+
+func TestStripeProvider__sends_form_requests_and_maps_errors(t *testing.T) {
+	// Given a server standing in for Stripe's API
+	var form map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, _, _ := r.BasicAuth()
+		assert.Equal(t, "sk_test_123", key)
+		r.ParseForm()
+		form = map[string]string{}
+		for name := range r.PostForm {
+			form[name] = r.PostForm.Get(name)
+		}
+		switch r.URL.Path {
+		case "/v1/payment_intents":
+			assert.Equal(t, "key-1", r.Header.Get("Idempotency-Key"))
+			w.Write([]byte(`{"id": "pi_1", "amount": 1999, "currency": "usd", "status": "requires_payment_method", "client_secret": "pi_1_secret"}`))
+		case "/v1/refunds":
+			assert.Equal(t, "refund-7", r.Header.Get("Idempotency-Key"))
+			w.Write([]byte(`{"id": "re_1", "amount": 500, "payment_intent": "pi_1", "status": "pending", "metadata": {"refund_id": "7"}}`))
+		case "/v1/payment_intents/pi_1/confirm":
+			w.WriteHeader(http.StatusPaymentRequired)
+			w.Write([]byte(`{"error": {"type": "card_error", "code": "card_declined", "decline_code": "generic_decline", "message": "Your card was declined."}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": {"type": "invalid_request_error", "code": "resource_missing", "message": "No such payment_intent"}}`))
+		}
+	}))
+	defer server.Close()
+	provider := &payments.StripeProvider{SecretKey: "sk_test_123", BaseURL: server.URL}
+	ctx := context.Background()
+
+	// When
+	intent, err := provider.CreateIntent(ctx, payments.IntentParams{
+		Amount: 1999, Currency: "USD", Metadata: map[string]string{"order_id": "7"}, IdempotencyKey: "key-1",
+	})
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, "pi_1", intent.ID)
+	assert.Equal(t, "pi_1_secret", intent.ClientSecret)
+	assert.Equal(t, "1999", form["amount"])
+	assert.Equal(t, "usd", form["currency"])
+	assert.Equal(t, "7", form["metadata[order_id]"])
+
+	_, err = provider.Confirm(ctx, "pi_1", "pm_card_visa")
+	var cardErr *payments.CardError
+	assert.True(t, errors.As(err, &cardErr))
+	assert.Equal(t, "Your card was declined.", cardErr.Message)
+	assert.Equal(t, "pm_card_visa", form["payment_method"])
+
+	refund, err := provider.Refund(ctx, payments.RefundParams{
+		IntentID: "pi_1", Amount: 500, Metadata: map[string]string{"refund_id": "7"}, IdempotencyKey: "refund-7",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "7", form["metadata[refund_id]"])
+	assert.Equal(t, payments.RefundPending, refund.Status)
+	assert.Equal(t, "7", refund.Metadata["refund_id"])
+
+	_, err = provider.GetIntent(ctx, "pi_missing")
+	assert.ErrorIs(t, err, payments.ErrNotFound)
+}
+
+func TestParseWebhook__verifies_signatures_and_decodes_intent_events(t *testing.T) {
+	// Given
+	ctx := context.Background()
+	provider := payments.NewFakeProvider()
+	provider.WebhookSecret = "whsec_test"
+	intent, _ := provider.CreateIntent(ctx, payments.IntentParams{Amount: 1200, Currency: "USD"})
+	provider.Confirm(ctx, intent.ID, "pm_card_visa")
+	payload, err := provider.EventPayload("evt_1", "payment_intent.succeeded", intent.ID)
+	assert.NoError(t, err)
+	signed := func(signature string) http.Header {
+		return http.Header{payments.SignatureHeader: {signature}}
+	}
+	now := time.Now()
+
+	// When
+	event, err := provider.ParseWebhook(payload, signed(payments.SignWebhookPayload(payload, "whsec_test", now)))
+	_, wrongSecret := provider.ParseWebhook(payload, signed(payments.SignWebhookPayload(payload, "whsec_other", now)))
+	_, stale := provider.ParseWebhook(payload, signed(payments.SignWebhookPayload(payload, "whsec_test", now.Add(-time.Hour))))
+	tampered := []byte(strings.Replace(string(payload), "1200", "1", 1))
+	_, tamperedErr := provider.ParseWebhook(tampered, signed(payments.SignWebhookPayload(payload, "whsec_test", now)))
+	_, unsigned := provider.ParseWebhook(payload, http.Header{})
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, "evt_1", event.ID)
+	assert.Equal(t, "payment_intent.succeeded", event.Type)
+	assert.Equal(t, intent.ID, event.Intent.ID)
+	assert.Equal(t, payments.IntentSucceeded, event.Intent.Status)
+	assert.Equal(t, int64(1200), event.Intent.AmountReceived)
+	for _, err := range []error{wrongSecret, stale, tamperedErr, unsigned} {
+		assert.ErrorIs(t, err, payments.ErrInvalidSignature)
+	}
+}
+
+func TestParseWebhook__decodes_refund_events(t *testing.T) {
+	// Given
+	provider := payments.NewFakeProvider()
+	provider.WebhookSecret = "whsec_test"
+	payload := []byte(`{"id":"evt_2","type":"refund.updated","created":1718000000,"data":{"object":` +
+		`{"id":"re_1","amount":500,"payment_intent":"pi_1","status":"canceled","failure_reason":"expired_or_canceled_card","metadata":{"refund_id":"7"}}}}`)
+	header := http.Header{payments.SignatureHeader: {payments.SignWebhookPayload(payload, "whsec_test", time.Now())}}
+
+	// When
+	event, err := provider.ParseWebhook(payload, header)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Nil(t, event.Intent)
+	assert.Equal(t, &payments.Refund{
+		ID:            "re_1",
+		IntentID:      "pi_1",
+		Amount:        500,
+		Status:        payments.RefundFailed,
+		FailureReason: "expired_or_canceled_card",
+		Metadata:      map[string]string{"refund_id": "7"},
+	}, event.Refund)
+}
+
+func TestNewProviderFromEnv__simulates_payments_only_when_asked(t *testing.T) {
+	// Given
+	t.Setenv("STRIPE_SECRET_KEY", "")
+	t.Setenv("STRIPE_WEBHOOK_SECRET", "whsec_test")
+
+	// When
+	t.Setenv("PAYMENTS_PROVIDER", "")
+	_, missingKey := payments.NewProviderFromEnv()
+	t.Setenv("PAYMENTS_PROVIDER", "paypal")
+	_, unknown := payments.NewProviderFromEnv()
+	t.Setenv("PAYMENTS_PROVIDER", "fake")
+	fake, fakeErr := payments.NewProviderFromEnv()
+	t.Setenv("PAYMENTS_PROVIDER", "stripe")
+	t.Setenv("STRIPE_SECRET_KEY", "sk_test_123")
+	stripe, stripeErr := payments.NewProviderFromEnv()
+
+	// Then
+	assert.Error(t, missingKey)
+	assert.Error(t, unknown)
+	assert.NoError(t, fakeErr)
+	assert.Equal(t, "fake", fake.Name())
+	assert.NoError(t, stripeErr)
+	assert.Equal(t, "stripe", stripe.Name())
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultPolicy__grants_permissions_up_the_role_hierarchy(t *testing.T) {
+	// Given
+	policy, err := utilities.LoadPolicyFromEnv()
+	assert.NoError(t, err)
+
+	// Then
+	assert.True(t, policy.Allows("customer", "reservation:book"))
+	assert.True(t, policy.Allows("dev", "reservation:book"))
+	assert.False(t, policy.Allows("customer", "menu:edit"))
+	assert.True(t, policy.Allows("staff", "menu:edit"))
+	assert.False(t, policy.Allows("admin", "restaurant:transfer"))
+	assert.True(t, policy.Allows("admin_super", "restaurant:transfer"))
+	assert.False(t, policy.Allows("admin_super", "database:seed"))
+	assert.False(t, policy.Allows("unknown", "restaurant:read"))
+}
+
+func TestParsePolicy__rejects_invalid_policies(t *testing.T) {
+	// Given
+	codePermissions := `"restaurant:administer": ["admin"], "restaurant:transfer": ["admin"], "user:manage": ["admin"]`
+	policies := map[string]string{
+		"unknown role":        `{"roles": {"owner": {}}, "permissions": {` + codePermissions + `}}`,
+		"undefined parent":    `{"roles": {"admin": {"inherits": ["staff"]}}, "permissions": {` + codePermissions + `}}`,
+		"inheritance cycle":   `{"roles": {"admin": {"inherits": ["staff"]}, "staff": {"inherits": ["admin"]}}, "permissions": {` + codePermissions + `}}`,
+		"undefined grantee":   `{"roles": {"admin": {}}, "permissions": {"menu:edit": ["staff"], ` + codePermissions + `}}`,
+		"bad permission name": `{"roles": {"admin": {}}, "permissions": {"EditMenu": ["admin"], ` + codePermissions + `}}`,
+		"missing permission":  `{"roles": {"admin": {}}, "permissions": {"restaurant:transfer": ["admin"]}}`,
+		"unknown field":       `{"roles": {"admin": {}}, "grants": {}, "permissions": {` + codePermissions + `}}`,
+	}
+
+	for name, policy := range policies {
+		// When
+		_, err := utilities.ParsePolicy([]byte(policy))
+
+		// Then
+		assert.Error(t, err, name)
+	}
+}
+
+func TestRequirePermission__fails_fast_on_unknown_permissions(t *testing.T) {
+	// Then
+	assert.Panics(t, func() { utilities.RequirePermission("reservation:raed") })
+	assert.NotPanics(t, func() { utilities.RequirePermission("reservation:read") })
+}
+
+func TestRequirePermission__forbids_users_without_the_permission(t *testing.T) {
+	// Given
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(sessions.Sessions("test", cookie.NewStore([]byte("secret"))))
+	router.GET("/menu", utilities.RequirePermission("menu:edit"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	send := func(authType string) int {
+		token, err := utilities.NewAccessToken(models.User{UserID: 7, AuthType: authType}, "family-1")
+		assert.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, "/menu", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	// When
+	customer := send("customer")
+	staff := send("staff")
+
+	// Then
+	assert.Equal(t, http.StatusForbidden, customer)
+	assert.Equal(t, http.StatusOK, staff)
+}
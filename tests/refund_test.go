@@ -0,0 +1,302 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"waitress-backend/internal/handlers"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/payments"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// unansweredRefunds is a fake provider whose refunds never get an answer, as when the connection drops
+type unansweredRefunds struct {
+	*payments.FakeProvider
+	attempts int
+}
+
+func (p *unansweredRefunds) Refund(ctx context.Context, params payments.RefundParams) (*payments.Refund, error) {
+	p.attempts++
+	return nil, errors.New("connection reset by peer")
+}
+
+// refundDatabase creates a restaurant of the order tests' owner with a completed payment of 12.50 USD,
+// paid with the provider
+func refundDatabase(t *testing.T, provider payments.Provider) (*gorm.DB, *models.Payment) {
+	db := testDatabase(t, &models.Restaurant{}, &models.Staff{}, &models.Payment{}, &models.Refund{})
+	restaurant := models.Restaurant{OwnerID: orderOwnerID, Name: "Trattoria"}
+	assert.NoError(t, db.Omit("Owner").Create(&restaurant).Error)
+
+	ctx := context.Background()
+	intent, err := provider.CreateIntent(ctx, payments.IntentParams{Amount: 1250, Currency: "USD"})
+	assert.NoError(t, err)
+	_, err = provider.Confirm(ctx, intent.ID, "pm_card_visa")
+	assert.NoError(t, err)
+	payment := &models.Payment{
+		UserID:           orderCustomerID,
+		RestaurantID:     restaurant.RestaurantId,
+		Provider:         provider.Name(),
+		StripePaymentKey: intent.ID,
+		Amount:           usd("12.50"),
+		Status:           models.PaymentStatusCompleted,
+		PaymentMethod:    "card",
+	}
+	assert.NoError(t, db.Omit("Restaurant").Create(payment).Error)
+	return db, payment
+}
+
+// refundRouter serves the refund endpoints to the restaurant's owner, as the API does
+func refundRouter(db *gorm.DB) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	utilities.IdempotencyKeys = utilities.NewMemoryIdempotencyStore()
+	router := gin.New()
+	refunds := router.Group("/api/payments", func(c *gin.Context) {
+		c.Set(utilities.ContextUserIDKey, orderOwnerID)
+		c.Set(utilities.ContextAuthTypeKey, string(utilities.Customer))
+	})
+	refunds.POST("/:paymentId/refunds", utilities.Idempotent(), handlers.CreatePaymentRefund(db, router))
+	return router
+}
+
+// postRefund asks for a refund of the payment, under the idempotency key if one is given
+func postRefund(router *gin.Engine, payment *models.Payment, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/payments/%d/refunds", payment.PaymentID), strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(utilities.IdempotencyKeyHeader, key)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// postWebhook delivers an event payload to the webhook handler, signed with the provider's secret
+func postWebhook(db *gorm.DB, provider *payments.FakeProvider, payload []byte) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/payments/webhook", handlers.HandlePaymentWebhook(db, router))
+	req := httptest.NewRequest(http.MethodPost, "/api/payments/webhook", strings.NewReader(string(payload)))
+	req.Header.Set(payments.SignatureHeader, payments.SignWebhookPayload(payload, provider.WebhookSecret, time.Now()))
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+// refundEventPayload is the webhook payload Stripe sends when a refund of the payment is updated
+func refundEventPayload(eventID string, payment *models.Payment, refund *models.Refund, status string) []byte {
+	return []byte(fmt.Sprintf(`{"id":%q,"type":"refund.updated","created":%d,"data":{"object":`+
+		`{"id":"re_%s","amount":%d,"payment_intent":%q,"status":%q,"failure_reason":"expired_or_canceled_card","metadata":{"refund_id":"%d"}}}}`,
+		eventID, time.Now().Unix(), eventID, refund.Amount.Amount, payment.StripePaymentKey, status, refund.RefundID))
+}
+
+// committedRefunds returns how much of the payment is refunded or being refunded, as stored
+func committedRefunds(t *testing.T, db *gorm.DB, payment *models.Payment) int64 {
+	var refunds []models.Refund
+	assert.NoError(t, db.Where("payment_id = ? AND status IN ?", payment.PaymentID,
+		[]string{models.RefundStatusPending, models.RefundStatusSucceeded}).Find(&refunds).Error)
+	var total int64
+	for _, refund := range refunds {
+		total += refund.Amount.Amount
+	}
+	return total
+}
+
+// paymentStatus returns the payment's stored status
+func paymentStatus(t *testing.T, db *gorm.DB, payment *models.Payment) string {
+	var stored models.Payment
+	assert.NoError(t, db.First(&stored, payment.PaymentID).Error)
+	return stored.Status
+}
+
+// pendingRefund asks for a refund that the provider does not answer, which leaves it pending
+func pendingRefund(t *testing.T, db *gorm.DB, fake *payments.FakeProvider, payment *models.Payment, body string) *models.Refund {
+	payments.SetDefault(&unansweredRefunds{FakeProvider: fake})
+	defer payments.SetDefault(fake)
+	response := postRefund(refundRouter(db), payment, "", body)
+	assert.Equal(t, http.StatusAccepted, response.Code)
+	var refund models.Refund
+	assert.NoError(t, db.Order("refund_id DESC").First(&refund).Error)
+	return &refund
+}
+
+func TestRefund__joins_the_payment_it_refunds(t *testing.T) {
+	// Given
+	db := testDatabase(t, &models.Payment{}, &models.Refund{})
+	amount := models.MustParseMoney("12.50", models.DefaultCurrency)
+	for _, key := range []string{"pi_first", "pi_second"} {
+		assert.NoError(t, db.Omit("Restaurant").Create(&models.Payment{
+			UserID: 2, RestaurantID: 1, Provider: "fake", StripePaymentKey: key, Amount: amount,
+			Status: models.PaymentStatusCompleted, PaymentMethod: "card",
+		}).Error)
+	}
+	assert.NoError(t, db.Omit("Payment").Create(&models.Refund{
+		PaymentID: 2, Amount: amount, Reason: "Cold food", Status: models.RefundStatusPending, IssuedBy: 1,
+	}).Error)
+
+	// When
+	var refund models.Refund
+	err := db.Joins("Payment").First(&refund).Error
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), refund.Payment.PaymentID)
+	assert.Equal(t, "pi_second", refund.Payment.StripePaymentKey)
+}
+
+func TestCreatePaymentRefund__retries_after_a_lost_answer_get_the_same_refund(t *testing.T) {
+	// Given
+	provider := &unansweredRefunds{FakeProvider: fakePayments(t)}
+	payments.SetDefault(provider)
+	db, payment := refundDatabase(t, provider)
+	router := refundRouter(db)
+
+	// When
+	first := postRefund(router, payment, "refund-1", `{"reason":"Cold food"}`)
+	retry := postRefund(router, payment, "refund-1", `{"reason":"Cold food"}`)
+
+	// Then
+	assert.Equal(t, http.StatusAccepted, first.Code)
+	assert.Equal(t, http.StatusAccepted, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, 1, provider.attempts)
+	var refunds []models.Refund
+	assert.NoError(t, db.Find(&refunds).Error)
+	if assert.Len(t, refunds, 1) {
+		assert.Equal(t, models.RefundStatusPending, refunds[0].Status)
+	}
+}
+
+func TestCreatePaymentRefund__moves_the_payment_to_partially_refunded_then_refunded(t *testing.T) {
+	// Given
+	provider := fakePayments(t)
+	db, payment := refundDatabase(t, provider)
+	router := refundRouter(db)
+
+	// When
+	partial := postRefund(router, payment, "", `{"reason":"Cold starter","amount":{"amount":"5.00","currency":"USD"}}`)
+	partialStatus := paymentStatus(t, db, payment)
+	rest := postRefund(router, payment, "", `{"reason":"Cold main"}`)
+	restStatus := paymentStatus(t, db, payment)
+	again := postRefund(router, payment, "", `{"reason":"Cold dessert"}`)
+
+	// Then
+	assert.Equal(t, http.StatusCreated, partial.Code)
+	assert.Equal(t, models.PaymentStatusPartiallyRefunded, partialStatus)
+	assert.Equal(t, http.StatusCreated, rest.Code)
+	assert.Contains(t, rest.Body.String(), `"amount":{"amount":"7.50","currency":"USD"}`)
+	assert.Equal(t, models.PaymentStatusRefunded, restStatus)
+	assert.Equal(t, http.StatusConflict, again.Code)
+	assert.Equal(t, int64(1250), committedRefunds(t, db, payment))
+}
+
+func TestCreatePaymentRefund__pending_and_succeeded_refunds_never_exceed_the_payment(t *testing.T) {
+	// Given
+	provider := fakePayments(t)
+	db, payment := refundDatabase(t, provider)
+	pendingRefund(t, db, provider, payment, `{"reason":"Cold starter","amount":{"amount":"5.00","currency":"USD"}}`)
+	router := refundRouter(db)
+
+	// When
+	tooMuch := postRefund(router, payment, "", `{"reason":"Cold main","amount":{"amount":"10.00","currency":"USD"}}`)
+	rest := postRefund(router, payment, "", `{"reason":"Cold main"}`)
+	beyond := postRefund(router, payment, "", `{"reason":"Cold dessert","amount":{"amount":"0.01","currency":"USD"}}`)
+
+	// Then
+	assert.Equal(t, http.StatusUnprocessableEntity, tooMuch.Code)
+	assert.Equal(t, http.StatusCreated, rest.Code)
+	assert.Contains(t, rest.Body.String(), `"amount":{"amount":"7.50","currency":"USD"}`)
+	assert.Equal(t, http.StatusConflict, beyond.Code)
+	assert.Equal(t, int64(1250), committedRefunds(t, db, payment))
+	// Only the succeeded refund counts towards the payment's status
+	assert.Equal(t, models.PaymentStatusPartiallyRefunded, paymentStatus(t, db, payment))
+}
+
+func TestCreatePaymentRefund__concurrent_refunds_are_capped(t *testing.T) {
+	// Given
+	provider := fakePayments(t)
+	db, payment := refundDatabase(t, provider)
+	router := refundRouter(db)
+
+	// When
+	codes := make([]int, 6)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = postRefund(router, payment, "", `{"reason":"Cold food","amount":{"amount":"5.00","currency":"USD"}}`).Code
+		}(i)
+	}
+	wg.Wait()
+
+	// Then
+	created := 0
+	for _, code := range codes {
+		if code == http.StatusCreated {
+			created++
+		} else {
+			assert.Contains(t, []int{http.StatusConflict, http.StatusUnprocessableEntity}, code)
+		}
+	}
+	assert.Equal(t, 2, created)
+	assert.Equal(t, int64(1000), committedRefunds(t, db, payment))
+	made, err := provider.ListRefunds(context.Background(), payment.StripePaymentKey)
+	assert.NoError(t, err)
+	assert.Len(t, made, 2)
+}
+
+func TestHandlePaymentWebhook__settles_pending_refunds(t *testing.T) {
+	// Given
+	provider := fakePayments(t)
+	db, payment := refundDatabase(t, provider)
+	assert.NoError(t, db.AutoMigrate(&models.PaymentEvent{}))
+	refund := pendingRefund(t, db, provider, payment, `{"reason":"Cold food"}`)
+
+	// When
+	response := postWebhook(db, provider, refundEventPayload("evt_made", payment, refund, "succeeded"))
+	redelivered := postWebhook(db, provider, refundEventPayload("evt_made", payment, refund, "succeeded"))
+
+	// Then
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, http.StatusOK, redelivered.Code)
+	var stored models.Refund
+	assert.NoError(t, db.First(&stored, refund.RefundID).Error)
+	assert.Equal(t, models.RefundStatusSucceeded, stored.Status)
+	if assert.NotNil(t, stored.ProviderRefundID) {
+		assert.Equal(t, "re_evt_made", *stored.ProviderRefundID)
+	}
+	assert.Equal(t, models.PaymentStatusRefunded, paymentStatus(t, db, payment))
+}
+
+func TestHandlePaymentWebhook__failed_refunds_free_their_amount(t *testing.T) {
+	// Given
+	provider := fakePayments(t)
+	db, payment := refundDatabase(t, provider)
+	assert.NoError(t, db.AutoMigrate(&models.PaymentEvent{}))
+	refund := pendingRefund(t, db, provider, payment, `{"reason":"Cold food"}`)
+
+	// When
+	response := postWebhook(db, provider, refundEventPayload("evt_refused", payment, refund, "failed"))
+	retry := postRefund(refundRouter(db), payment, "", `{"reason":"Cold food"}`)
+
+	// Then
+	assert.Equal(t, http.StatusOK, response.Code)
+	var stored models.Refund
+	assert.NoError(t, db.First(&stored, refund.RefundID).Error)
+	assert.Equal(t, models.RefundStatusFailed, stored.Status)
+	if assert.NotNil(t, stored.FailureMessage) {
+		assert.Equal(t, "expired_or_canceled_card", *stored.FailureMessage)
+	}
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, models.PaymentStatusRefunded, paymentStatus(t, db, payment))
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"testing"
+	"time"
+	"waitress-backend/internal/utilities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func window(start time.Time, minutes int) utilities.ReservationWindow {
+	return utilities.ReservationWindow{Start: start, End: start.Add(time.Duration(minutes) * time.Minute)}
+}
+
+func TestReservationWindowsOverlap__partial_overlap_conflicts(t *testing.T) {
+	// Given
+	seven := time.Date(2030, 5, 1, 19, 0, 0, 0, time.UTC)
+	existing := window(seven, 90)
+	requested := window(seven.Add(60*time.Minute), 90)
+
+	// When
+	sut := utilities.ReservationWindowsOverlap(existing, requested)
+
+	// Then
+	assert.True(t, sut)
+}
+
+func TestReservationWindowsOverlap__contained_window_conflicts(t *testing.T) {
+	// Given
+	seven := time.Date(2030, 5, 1, 19, 0, 0, 0, time.UTC)
+	existing := window(seven, 180)
+	requested := window(seven.Add(30*time.Minute), 30)
+
+	// When
+	sut := utilities.ReservationWindowsOverlap(requested, existing)
+
+	// Then
+	assert.True(t, sut)
+}
+
+func TestReservationWindowsOverlap__back_to_back_bookings_do_not_conflict(t *testing.T) {
+	// Given
+	seven := time.Date(2030, 5, 1, 19, 0, 0, 0, time.UTC)
+	existing := window(seven, 90)
+	requested := window(seven.Add(90*time.Minute), 90)
+
+	// When
+	sut := utilities.ReservationWindowsOverlap(existing, requested)
+
+	// Then
+	assert.False(t, sut)
+}
+
+func TestNextAvailableTime__free_table_is_available_at_requested_start(t *testing.T) {
+	// Given
+	seven := time.Date(2030, 5, 1, 19, 0, 0, 0, time.UTC)
+	requested := window(seven, 90)
+
+	// When
+	sut := utilities.NextAvailableTime(nil, requested, 15*time.Minute)
+
+	// Then
+	assert.Equal(t, seven, sut)
+}
+
+func TestNextAvailableTime__waits_for_booking_and_turn_time(t *testing.T) {
+	// Given
+	six := time.Date(2030, 5, 1, 18, 0, 0, 0, time.UTC)
+	bookings := []utilities.ReservationWindow{window(six, 90)}
+	requested := window(six.Add(30*time.Minute), 60)
+
+	// When
+	sut := utilities.NextAvailableTime(bookings, requested, 15*time.Minute)
+
+	// Then
+	assert.Equal(t, six.Add(105*time.Minute), sut)
+}
+
+func TestNextAvailableTime__skips_gaps_too_short_for_the_party(t *testing.T) {
+	// Given
+	six := time.Date(2030, 5, 1, 18, 0, 0, 0, time.UTC)
+	bookings := []utilities.ReservationWindow{
+		window(six, 60),                      // 18:00 - 19:00, free from 19:15
+		window(six.Add(120*time.Minute), 60), // 20:00 - 21:00, free from 21:15
+	}
+	requested := window(six, 90)
+
+	// When
+	sut := utilities.NextAvailableTime(bookings, requested, 15*time.Minute)
+
+	// Then
+	assert.Equal(t, six.Add(195*time.Minute), sut)
+}
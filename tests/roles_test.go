@@ -0,0 +1,39 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasRestaurantRole__matches_only_listed_roles(t *testing.T) {
+	// Given
+	roles := utilities.RestaurantKitchenRoles
+
+	// When / Then
+	assert.True(t, utilities.HasRestaurantRole(models.StaffRoleKitchen, roles...))
+	assert.True(t, utilities.HasRestaurantRole(models.StaffRoleOwner, roles...))
+	assert.False(t, utilities.HasRestaurantRole(models.StaffRoleHost, roles...))
+	assert.False(t, utilities.HasRestaurantRole("", utilities.RestaurantAllRoles...))
+}
+
+func TestRestaurantRoleRequired__rejects_invalid_restaurant_id(t *testing.T) {
+	// Given
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/:restaurantId/staff", utilities.RestaurantRoleRequired(nil, utilities.RestaurantManagementRoles...), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	// When
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/abc/staff", nil))
+
+	// Then
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryRevocations map[string]time.Time
+
+func (m memoryRevocations) Revoke(tokenID string, expiresAt time.Time) error {
+	m[tokenID] = expiresAt
+	return nil
+}
+
+func (m memoryRevocations) IsRevoked(tokenIDs ...string) (bool, error) {
+	for _, id := range tokenIDs {
+		if _, ok := m[id]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m memoryRevocations) UserTokensRevokedAt(userID uint) (*time.Time, error) {
+	return nil, nil
+}
+
+func TestNewAccessToken__carries_user_and_token_family(t *testing.T) {
+	// Given
+	user := models.User{UserID: 7, Email: "guest@example.com", AuthType: "customer"}
+
+	// When
+	token, err := utilities.NewAccessToken(user, "family-1")
+	assert.NoError(t, err)
+	claims, err := utilities.ParseAccessToken(token)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, uint(7), claims.UserID)
+	assert.Equal(t, "family-1", claims.SessionID)
+	assert.NotEmpty(t, claims.ID)
+	assert.WithinDuration(t, time.Now().Add(utilities.AccessTokenTTL), claims.ExpiresAt.Time, time.Minute)
+}
+
+func TestNewRefreshToken__stores_only_a_hash(t *testing.T) {
+	// When
+	token, hash, err := utilities.NewRefreshToken()
+
+	// Then
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, hash)
+	assert.Equal(t, hash, utilities.HashRefreshToken(token))
+}
+
+func TestRequirePermission__rejects_tokens_of_a_revoked_family(t *testing.T) {
+	// Given
+	gin.SetMode(gin.TestMode)
+	revocations := memoryRevocations{}
+	utilities.TokenRevocations = revocations
+	defer func() { utilities.TokenRevocations = nil }()
+
+	router := gin.New()
+	router.Use(sessions.Sessions("test", cookie.NewStore([]byte("secret"))))
+	router.GET("/me", utilities.RequirePermission("account:manage"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	token, err := utilities.NewAccessToken(models.User{UserID: 7, AuthType: "customer"}, "family-1")
+	assert.NoError(t, err)
+	send := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	// When
+	before := send()
+	revocations.Revoke("family-1", time.Now().Add(time.Hour))
+	after := send()
+
+	// Then
+	assert.Equal(t, http.StatusOK, before)
+	assert.Equal(t, http.StatusUnauthorized, after)
+}
+
+// signedOutEverywhere is a revocation store in which the user signed out on every device at a cutoff
+type signedOutEverywhere struct {
+	memoryRevocations
+	cutoff time.Time
+}
+
+func (s signedOutEverywhere) UserTokensRevokedAt(userID uint) (*time.Time, error) {
+	return &s.cutoff, nil
+}
+
+func TestRequirePermission__rejects_tokens_issued_before_signing_out_everywhere(t *testing.T) {
+	// Given
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(sessions.Sessions("test", cookie.NewStore([]byte("secret"))))
+	router.GET("/me", utilities.RequirePermission("account:manage"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	token, err := utilities.NewAccessToken(models.User{UserID: 7, AuthType: "customer"}, "family-1")
+	assert.NoError(t, err)
+	send := func(cutoff time.Time) int {
+		utilities.TokenRevocations = signedOutEverywhere{memoryRevocations{}, cutoff}
+		defer func() { utilities.TokenRevocations = nil }()
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	// When
+	signedOutBefore := send(time.Now().Add(-time.Hour))
+	signedOutAfter := send(time.Now().Add(2 * time.Second))
+
+	// Then
+	assert.Equal(t, http.StatusOK, signedOutBefore)
+	assert.Equal(t, http.StatusUnauthorized, signedOutAfter)
+}
@@ -0,0 +1,58 @@
+package tests
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"waitress-backend/internal/utilities"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTPCode__matches_the_rfc_6238_test_vectors(t *testing.T) {
+	// Given the RFC's SHA1 secret "12345678901234567890"
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	// When
+	codes := []string{}
+	for _, unix := range []int64{59, 1111111109, 1234567890, 2000000000} {
+		code, err := utilities.TOTPCode(secret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		codes = append(codes, code)
+	}
+
+	// Then the last six digits of the RFC's eight digit codes
+	assert.Equal(t, []string{"287082", "081804", "005924", "279037"}, codes)
+}
+
+func TestTOTPURI__can_be_imported_by_authenticator_apps(t *testing.T) {
+	// Given
+	secret, err := utilities.NewTOTPSecret()
+	assert.NoError(t, err)
+
+	// When
+	uri := utilities.TOTPURI("admin@example.com", secret)
+
+	// Then
+	parsed, err := url.Parse(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, "otpauth", parsed.Scheme)
+	assert.Equal(t, "totp", parsed.Host)
+	assert.Equal(t, "/Waitress:admin@example.com", parsed.Path)
+	assert.Equal(t, secret, parsed.Query().Get("secret"))
+	assert.Equal(t, "Waitress", parsed.Query().Get("issuer"))
+	assert.False(t, strings.Contains(secret, "="))
+}
+
+func TestParseUserTypes__rejects_unknown_auth_types(t *testing.T) {
+	// When
+	roles, err := utilities.ParseUserTypes("admin, dev")
+	_, unknownErr := utilities.ParseUserTypes("admin,owner")
+
+	// Then
+	assert.NoError(t, err)
+	assert.Len(t, roles, 2)
+	assert.Contains(t, roles, utilities.Admin)
+	assert.Error(t, unknownErr)
+}
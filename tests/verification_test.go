@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"waitress-backend/internal/database"
+	"waitress-backend/internal/models"
+	"waitress-backend/internal/utilities"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestEmailVerificationRequired__lets_only_verified_users_through(t *testing.T) {
+	// Given
+	gin.SetMode(gin.TestMode)
+	db := testDatabase(t, &models.Entity{}, &models.User{})
+	verifiedAt := time.Now()
+	verified := models.User{Email: "verified@example.com", PasswordHash: "x", EmailVerifiedAt: &verifiedAt}
+	unverified := models.User{Email: "unverified@example.com", PasswordHash: "x"}
+	assert.NoError(t, db.Create(&verified).Error)
+	assert.NoError(t, db.Create(&unverified).Error)
+
+	order := func(userID uint) int {
+		router := gin.New()
+		router.POST("/orders", func(c *gin.Context) {
+			c.Set(utilities.ContextUserIDKey, userID)
+		}, utilities.EmailVerificationRequired(db), func(c *gin.Context) {
+			c.Status(http.StatusCreated)
+		})
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/orders", nil))
+		return recorder.Code
+	}
+
+	// When
+	verifiedStatus := order(verified.UserID)
+	unverifiedStatus := order(unverified.UserID)
+
+	// Then
+	assert.Equal(t, http.StatusCreated, verifiedStatus)
+	assert.Equal(t, http.StatusForbidden, unverifiedStatus)
+}
+
+// userBeforeVerification is the users table as it was before email addresses were verified
+type userBeforeVerification struct {
+	UserID    uint `gorm:"primaryKey"`
+	Email     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt
+}
+
+func TestMigrateEmailVerification__counts_existing_users_as_verified(t *testing.T) {
+	// Given
+	db := testDatabase(t)
+	users := db.Table(utilities.TableName(db, &models.User{}))
+	assert.NoError(t, users.AutoMigrate(&userBeforeVerification{}))
+	assert.NoError(t, users.Create(&userBeforeVerification{Email: "existing@example.com"}).Error)
+
+	// When
+	err := database.MigrateEmailVerification(db)
+
+	// Then
+	assert.NoError(t, err)
+	var user models.User
+	assert.NoError(t, db.Select("user_id", "email_verified_at").First(&user).Error)
+	assert.NotNil(t, user.EmailVerifiedAt)
+}